@@ -1,22 +1,36 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/tobibamidele/minra/internal/app"
+	"github.com/tobibamidele/minra/internal/editor"
 )
 
 func main() {
+	configPath := flag.String("config", editor.DefaultUserConfigPath(), "user config file")
+	var overrides []string
+	flag.Func("set", "override a config option, as key=value (repeatable)", func(s string) error {
+		overrides = append(overrides, s)
+		return nil
+	})
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: minra [flags] [directory]\n\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
 	// Get the starting directory
 	startDir := "."
-	if len(os.Args) > 1 {
-		startDir = os.Args[1]
+	if flag.NArg() > 0 {
+		startDir = flag.Arg(0)
 	}
 
 	// Create app
-	application, err := app.New(startDir)
+	application, err := app.New(startDir, *configPath, overrides)
 	if err != nil {
 		fmt.Printf("Error initializing application: %v\n", err)
 		os.Exit(1)
@@ -0,0 +1,28 @@
+// Package configs embeds the configuration files bundled with the editor
+package configs
+
+import "embed"
+
+// Default is the bundled configs/default.yaml, applied on top of the
+// built-in defaults before any user or project config
+//
+//go:embed default.yaml
+var Default []byte
+
+// Keybindings is the bundled configs/keybindings.yaml, the default keymap
+// that the user's keybindings.yaml is layered over
+//
+//go:embed keybindings.yaml
+var Keybindings []byte
+
+// Syntax holds the bundled grammar files in configs/syntax, registered
+// before the user's grammars in ~/.config/minra/syntax
+//
+//go:embed syntax
+var Syntax embed.FS
+
+// Themes holds the bundled color themes in configs/themes, which the
+// user's themes in ~/.config/minra/themes are added to
+//
+//go:embed themes
+var Themes embed.FS
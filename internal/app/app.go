@@ -1,6 +1,8 @@
 package app
 
 import (
+	"path/filepath"
+
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/tobibamidele/minra/internal/editor"
 )
@@ -11,14 +13,28 @@ type App struct {
 	config *editor.Config
 }
 
-// New creates a new application
-func New(rootDir string) (*App, error) {
-	config := editor.DefaultConfig()
+// New creates a new application. The config is layered from the bundled
+// defaults, userConfig, the project's .minra.yaml in rootDir and the
+// key=value overrides given on the command line. The user keymap is read
+// from keybindings.yaml next to userConfig and user grammars from the
+// syntax directory beside it, and user themes from the themes directory.
+func New(rootDir, userConfig string, overrides []string) (*App, error) {
+	// Themes first, so the config can name a user theme
+	themeErrs := editor.LoadThemes(editor.DefaultUserThemeDir(userConfig))
+	config, configErrs := editor.LoadConfig(editor.ConfigSources{
+		UserFile:    userConfig,
+		ProjectFile: filepath.Join(rootDir, editor.ProjectConfigName),
+		Overrides:   overrides,
+	})
 
 	ed, err := editor.New(rootDir, config)
 	if err != nil {
 		return nil, err
 	}
+	configErrs = append(themeErrs, configErrs...)
+	configErrs = append(configErrs, ed.LoadKeybindings(editor.DefaultUserKeymapPath(userConfig))...)
+	configErrs = append(configErrs, ed.LoadGrammars(editor.DefaultUserSyntaxDir(userConfig))...)
+	ed.ReportConfigErrors(configErrs)
 
 	return &App{
 		editor: ed,
@@ -9,19 +9,25 @@ import (
 // Buffer represents a text buffer
 type Buffer struct {
 	id       string
-	lines    []string
+	text     *rope
 	filepath string
 	modified bool
 	cursor   *cursor.Cursor
 	history  *History
 	language string
 	tabSize  int
+	lexCache lineStates
+	version  int
+	edits    []edit
+	editBase int // the version before edits[0]
+
+	closedFolds map[int]bool
 }
 
 // New creates an empty buffer
 func New() *Buffer {
 	return &Buffer{
-		lines:    []string{""},
+		text:     newRope(nil),
 		filepath: "",
 		modified: false,
 		cursor:   cursor.New(),
@@ -33,12 +39,9 @@ func New() *Buffer {
 // NewFromContent creates a buffer from content
 func NewFromContent(content string, filepath string) *Buffer {
 	lines := strings.Split(content, "\n")
-	if len(lines) == 0 {
-		lines = []string{""}
-	}
 
 	return &Buffer{
-		lines:    lines,
+		text:     newRope(lines),
 		filepath: filepath,
 		modified: false,
 		cursor:   cursor.New(),
@@ -58,34 +61,33 @@ func (b *Buffer) SetID(id string) {
 
 // LineCount returns the number of lines in the buffer
 func (b *Buffer) LineCount() int {
-	return len(b.lines)
+	return b.text.Len()
 }
 
 // Line returns a specific line
 func (b *Buffer) Line(n int) string {
-	if n < 0 || n > len(b.lines) {
+	if n < 0 || n >= b.text.Len() {
 		return ""
 	}
 
-	return b.lines[n]
+	return b.text.Line(n)
 }
 
-// Lines returns all lines
+// Lines returns a copy of all lines
 func (b *Buffer) Lines() []string {
-	return b.lines
+	return b.text.Slice(0, b.text.Len())
 }
 
 // SetLines sets a specific line
 func (b *Buffer) SetLine(n int, content string) {
-	if n >= 0 && n < len(b.lines) {
-		b.lines[n] = content
-		b.modified = true
+	if n >= 0 && n < b.text.Len() {
+		b.setLine(n, content)
 	}
 }
 
 // Content returns full buffer content
 func (b *Buffer) Content() string {
-	return strings.Join(b.lines, "\n")
+	return b.text.String()
 }
 
 // Filepath returns the file path
@@ -103,9 +105,19 @@ func (b *Buffer) Modified() bool {
 	return b.modified
 }
 
-// SetModified sets te modified flag
+// Version counts the edits made to the buffer, so caches of its contents
+// can tell when they are stale
+func (b *Buffer) Version() int {
+	return b.version
+}
+
+// SetModified sets te modified flag. Clearing it marks the current undo
+// state as the saved one.
 func (b *Buffer) SetModified(modified bool) {
 	b.modified = modified
+	if !modified {
+		b.history.MarkSaved()
+	}
 }
 
 // Cursor returns the buffer's cursor
@@ -113,6 +125,16 @@ func (b *Buffer) Cursor() *cursor.Cursor {
 	return b.cursor
 }
 
+// TabSize returns the indent width used when editing
+func (b *Buffer) TabSize() int {
+	return b.indentWidth()
+}
+
+// SetTabSize sets the indent width used when editing
+func (b *Buffer) SetTabSize(size int) {
+	b.tabSize = size
+}
+
 // Language returns the detected language
 func (b *Buffer) Language() string {
 	return b.language
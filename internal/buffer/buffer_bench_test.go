@@ -0,0 +1,75 @@
+package buffer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// largeContent builds a document of n short lines
+func largeContent(n int) string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("2025-01-01T00:00:00Z INFO request %d handled", i)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// positions are the places in the file each benchmark edits at
+var positions = []struct {
+	name string
+	frac float64
+}{
+	{"top", 0},
+	{"middle", 0.5},
+	{"bottom", 1},
+}
+
+func benchmarkEdit(b *testing.B, size int, edit func(buf *Buffer, line int)) {
+	for _, pos := range positions {
+		b.Run(fmt.Sprintf("%s/%d", pos.name, size), func(b *testing.B) {
+			buf := NewFromContent(largeContent(size), "bench.log")
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				line := int(pos.frac * float64(buf.LineCount()-2))
+				edit(buf, line)
+			}
+		})
+	}
+}
+
+func BenchmarkInsertNewline(b *testing.B) {
+	for _, size := range []int{1_000, 200_000} {
+		benchmarkEdit(b, size, func(buf *Buffer, line int) {
+			buf.InsertNewline(line, 10)
+		})
+	}
+}
+
+func BenchmarkInsertAndDeleteLine(b *testing.B) {
+	for _, size := range []int{1_000, 200_000} {
+		benchmarkEdit(b, size, func(buf *Buffer, line int) {
+			buf.InsertNewline(line, 10)
+			buf.DeleteLine(line + 1)
+		})
+	}
+}
+
+func BenchmarkDeleteRuneMerge(b *testing.B) {
+	for _, size := range []int{1_000, 200_000} {
+		benchmarkEdit(b, size, func(buf *Buffer, line int) {
+			// Split then join the line again so the document size stays constant
+			buf.InsertNewline(line, 10)
+			buf.DeleteRune(line+1, 0)
+		})
+	}
+}
+
+func BenchmarkInsertRune(b *testing.B) {
+	for _, size := range []int{1_000, 200_000} {
+		benchmarkEdit(b, size, func(buf *Buffer, line int) {
+			buf.InsertRune(line, 0, 'x')
+			buf.DeleteRune(line, 1)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package buffer
+
+// maxEdits is how many edits the buffer remembers for ChangedLines
+const maxEdits = 1024
+
+// edit is one splice of the text: removed lines at line were replaced by
+// inserted lines. edits[i] took the buffer to version editBase+i+1.
+type edit struct {
+	line     int
+	removed  int
+	inserted int
+}
+
+// recordEdit remembers a splice for ChangedLines, dropping the oldest half
+// of the log when it is full
+func (b *Buffer) recordEdit(line, removed, inserted int) {
+	if len(b.edits) == maxEdits {
+		b.editBase += maxEdits / 2
+		b.edits = append(b.edits[:0], b.edits[maxEdits/2:]...)
+	}
+	b.edits = append(b.edits, edit{line: line, removed: removed, inserted: inserted})
+}
+
+// ChangedLines reports which lines were edited since version: lines
+// [start, end) of the buffer replace lines [start, end-delta) of the text
+// at that version, and everything else only moved by delta. ok is false
+// when the edits are too old to be known.
+func (b *Buffer) ChangedLines(since int) (start, end, delta int, ok bool) {
+	first := since - b.editBase
+	if first < 0 || since > b.version {
+		return 0, 0, 0, false
+	}
+
+	for i, e := range b.edits[first:] {
+		if i == 0 {
+			start, end, delta = e.line, e.line+e.inserted, e.inserted-e.removed
+			continue
+		}
+
+		// Where the end of the changed lines is after this edit
+		switch {
+		case end <= e.line:
+		case end <= e.line+e.removed:
+			end = e.line + e.inserted
+		default:
+			end += e.inserted - e.removed
+		}
+		start = min(start, e.line)
+		end = max(end, e.line+e.inserted)
+		delta += e.inserted - e.removed
+	}
+	return start, end, delta, true
+}
@@ -0,0 +1,55 @@
+package buffer
+
+import "sort"
+
+// Closed folds are kept by the line they start on. Which lines a fold
+// covers is worked out by the editor; the buffer only moves the starts
+// along as lines are inserted and deleted above them.
+
+// FoldClosed reports whether the fold starting at line is closed
+func (b *Buffer) FoldClosed(line int) bool {
+	return b.closedFolds[line]
+}
+
+// SetFoldClosed closes or opens the fold starting at line
+func (b *Buffer) SetFoldClosed(line int, closed bool) {
+	if !closed {
+		delete(b.closedFolds, line)
+		return
+	}
+	if b.closedFolds == nil {
+		b.closedFolds = make(map[int]bool)
+	}
+	b.closedFolds[line] = true
+}
+
+// ClosedFolds returns the start lines of the closed folds, sorted
+func (b *Buffer) ClosedFolds() []int {
+	lines := make([]int, 0, len(b.closedFolds))
+	for line := range b.closedFolds {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// shiftFolds moves the closed folds for count lines at line being
+// replaced by inserted lines. A fold whose first line was rewritten in
+// place stays; one whose first line was removed goes.
+func (b *Buffer) shiftFolds(line, count, inserted int) {
+	if len(b.closedFolds) == 0 {
+		return
+	}
+	shifted := make(map[int]bool, len(b.closedFolds))
+	for start := range b.closedFolds {
+		switch {
+		case start < line:
+			shifted[start] = true
+		case start >= line+count:
+			shifted[start+inserted-count] = true
+		case start == line && inserted > 0:
+			shifted[start] = true
+		}
+	}
+	b.closedFolds = shifted
+}
@@ -1,73 +1,443 @@
 package buffer
 
-// Change represents a buffer change for undo/redo
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// defaultHistorySize is the number of states kept when no limit is configured
+const defaultHistorySize = 100
+
+// rootSeq is the sequence number of the state the buffer was loaded in
+const rootSeq = 0
+
+// Change represents a buffer change for undo/redo. Every edit is stored as
+// a splice: the lines starting at Line that were Removed and the lines that
+// were Inserted in their place.
 type Change struct {
-	Line     int
-	Col      int
-	Text     string
-	IsInsert bool
+	Line     int      `json:"line"`
+	Removed  []string `json:"removed"`
+	Inserted []string `json:"inserted"`
+}
+
+// Position is a cursor position saved alongside a transaction
+type Position struct {
+	Line int `json:"line"`
+	Col  int `json:"col"`
+}
+
+// Transaction groups changes that are undone and redone as one step
+type Transaction struct {
+	Changes []Change `json:"changes"`
+	Before  Position `json:"before"` // cursor before the first change
+	After   Position `json:"after"`  // cursor after the last change
+}
+
+// Diff renders the transaction as unified-diff style lines
+func (tx *Transaction) Diff() []string {
+	var lines []string
+	for _, ch := range tx.Changes {
+		lines = append(lines, fmt.Sprintf("@@ line %d @@", ch.Line+1))
+		for _, l := range ch.Removed {
+			lines = append(lines, "-"+l)
+		}
+		for _, l := range ch.Inserted {
+			lines = append(lines, "+"+l)
+		}
+	}
+	return lines
+}
+
+// UndoNode is one state in the undo tree. Tx turns the parent's state into
+// this one. Seq numbers increase in the order states were created.
+type UndoNode struct {
+	Seq       int         `json:"seq"`
+	Parent    int         `json:"parent"` // -1 for the root
+	Tx        Transaction `json:"tx"`
+	Time      time.Time   `json:"time"`
+	children  []int
+	redoChild int // child that redo follows, the most recently visited
 }
 
-// History manages undo/redo
+// Children returns the sequence numbers of the states branching from n
+func (n *UndoNode) Children() []int {
+	return n.children
+}
+
+// HistoryState is a snapshot of a History that can be persisted and restored
+type HistoryState struct {
+	Nodes   []UndoNode `json:"nodes"`
+	Current int        `json:"current"`
+}
+
+// Step is one transaction to apply while moving through the tree
+type Step struct {
+	Tx   *Transaction
+	Undo bool // revert Tx instead of applying it
+}
+
+// History manages undo/redo as a tree. Making a change after an undo starts
+// a new branch instead of discarding the undone changes.
 type History struct {
-	changes []Change
-	current int
-	maxSize int
+	nodes     map[int]*UndoNode
+	root      int
+	current   int
+	nextSeq   int
+	maxSize   int
+	pending   *Transaction
+	depth     int
+	savePoint int // state the buffer was last saved in, -1 if unreachable
 }
 
 // NewHistory creates a new history
 func NewHistory() *History {
-	return &History{
-		changes: make([]Change, 0),
-		current: -1,
-		maxSize: 100,
+	h := &History{maxSize: defaultHistorySize}
+	h.reset()
+	return h
+}
+
+func (h *History) reset() {
+	h.nodes = map[int]*UndoNode{
+		rootSeq: {Seq: rootSeq, Parent: -1, Time: time.Now(), redoChild: -1},
+	}
+	h.root = rootSeq
+	h.current = rootSeq
+	h.nextSeq = rootSeq + 1
+	h.pending = nil
+	h.depth = 0
+	h.savePoint = rootSeq
+}
+
+// SetMaxSize sets the number of states kept
+func (h *History) SetMaxSize(size int) {
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	h.maxSize = size
+	h.trim()
+}
+
+// MaxSize returns the number of states kept
+func (h *History) MaxSize() int {
+	return h.maxSize
+}
+
+// Begin opens a transaction. Calls nest; only the outermost Commit
+// closes it.
+func (h *History) Begin(before Position) {
+	h.depth++
+	if h.depth == 1 {
+		h.pending = &Transaction{Before: before}
+	}
+}
+
+// Commit closes the transaction opened by Begin
+func (h *History) Commit(after Position) {
+	if h.depth == 0 {
+		return
+	}
+	h.depth--
+	if h.depth > 0 {
+		return
+	}
+
+	tx := h.pending
+	h.pending = nil
+	if tx == nil || len(tx.Changes) == 0 {
+		return
 	}
+	tx.After = after
+	h.push(*tx)
+}
+
+// InTransaction reports whether a transaction is open
+func (h *History) InTransaction() bool {
+	return h.depth > 0
 }
 
-// Record adds a change to history
+// Record adds a change to the open transaction
 func (h *History) Record(change Change) {
-	// Remove any changes after current position
-	if h.current < len(h.changes)-1 {
-		h.changes = h.changes[:h.current+1]
+	if h.pending == nil {
+		return
 	}
+	h.pending.Changes = append(h.pending.Changes, change)
+}
+
+// push adds tx as a new child of the current state and moves to it
+func (h *History) push(tx Transaction) {
+	node := &UndoNode{
+		Seq:       h.nextSeq,
+		Parent:    h.current,
+		Tx:        tx,
+		Time:      time.Now(),
+		redoChild: -1,
+	}
+	h.nextSeq++
+
+	parent := h.nodes[h.current]
+	parent.children = append(parent.children, node.Seq)
+	parent.redoChild = node.Seq
+
+	h.nodes[node.Seq] = node
+	h.current = node.Seq
+	h.trim()
+}
+
+// trim drops the oldest states past the size limit. Branches off the
+// current path go first; after that the root moves forward along it.
+func (h *History) trim() {
+	for len(h.nodes) > h.maxSize+1 {
+		onPath := h.pathFromRoot(h.current)
+
+		oldest := -1
+		for seq, node := range h.nodes {
+			if len(node.children) == 0 && !onPath[seq] && (oldest == -1 || seq < oldest) {
+				oldest = seq
+			}
+		}
+
+		if oldest != -1 {
+			h.removeNode(oldest)
+			continue
+		}
+
+		// Everything left is on the current path, so advance the root
+		oldRoot := h.nodes[h.root]
+		next := oldRoot.redoChild
+		for _, child := range oldRoot.children {
+			if onPath[child] {
+				next = child
+			}
+		}
+		delete(h.nodes, h.root)
+		if h.savePoint == h.root {
+			h.savePoint = -1
+		}
+		h.root = next
+		h.nodes[next].Parent = -1
+	}
+}
 
-	h.changes = append(h.changes, change)
-	h.current++
+func (h *History) removeNode(seq int) {
+	node := h.nodes[seq]
+	parent := h.nodes[node.Parent]
+	for i, child := range parent.children {
+		if child == seq {
+			parent.children = append(parent.children[:i], parent.children[i+1:]...)
+			break
+		}
+	}
+	if parent.redoChild == seq {
+		parent.redoChild = -1
+		if n := len(parent.children); n > 0 {
+			parent.redoChild = parent.children[n-1]
+		}
+	}
+	if h.savePoint == seq {
+		h.savePoint = -1
+	}
+	delete(h.nodes, seq)
+}
 
-	// Limit history size
-	if len(h.changes) > h.maxSize {
-		h.changes = h.changes[1:]
-		h.current--
+// pathFromRoot returns the set of states between the root and seq
+func (h *History) pathFromRoot(seq int) map[int]bool {
+	path := make(map[int]bool)
+	for seq != -1 {
+		path[seq] = true
+		seq = h.nodes[seq].Parent
 	}
+	return path
 }
 
-// Undo returns the change to undo
-func (h *History) Undo() *Change {
-	if h.current < 0 {
+// Undo returns the transaction to undo and moves to the parent state
+func (h *History) Undo() *Transaction {
+	node := h.nodes[h.current]
+	if node.Parent == -1 {
 		return nil
 	}
 
-	change := h.changes[h.current]
-	h.current--
-	return &change
+	h.nodes[node.Parent].redoChild = node.Seq
+	h.current = node.Parent
+	return &node.Tx
 }
 
-// Redo returns the change to redo
-func (h *History) Redo() *Change {
-	if h.current >= len(h.changes)-1 {
+// Redo returns the transaction to redo and moves to the child state last
+// visited
+func (h *History) Redo() *Transaction {
+	node := h.nodes[h.current]
+	if node.redoChild == -1 {
 		return nil
 	}
 
-	h.current++
-	return &h.changes[h.current]
+	h.current = node.redoChild
+	return &h.nodes[h.current].Tx
 }
 
 // CanUndo returns if undo is possible
 func (h *History) CanUndo() bool {
-	return h.current >= 0
+	return h.nodes[h.current].Parent != -1
 }
 
 // CanRedo returns if redo is possible
 func (h *History) CanRedo() bool {
-	return h.current < len(h.changes)-1
+	return h.nodes[h.current].redoChild != -1
+}
+
+// Current returns the sequence number of the current state
+func (h *History) Current() int {
+	return h.current
+}
+
+// Node returns the state with the given sequence number, or nil
+func (h *History) Node(seq int) *UndoNode {
+	return h.nodes[seq]
+}
+
+// Nodes returns every state, newest first
+func (h *History) Nodes() []*UndoNode {
+	nodes := make([]*UndoNode, 0, len(h.nodes))
+	for _, node := range h.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Seq > nodes[j].Seq
+	})
+	return nodes
+}
+
+// OnCurrentBranch reports whether seq is an ancestor of the current state
+// or the state itself
+func (h *History) OnCurrentBranch(seq int) bool {
+	return h.pathFromRoot(h.current)[seq]
+}
+
+// Jump moves the current state to target and returns the transactions to
+// undo and redo to get there, in order
+func (h *History) Jump(target int) []Step {
+	if _, ok := h.nodes[target]; !ok || target == h.current {
+		return nil
+	}
+
+	targetPath := h.pathFromRoot(target)
+
+	// Walk up from the current state to the common ancestor
+	var steps []Step
+	seq := h.current
+	for !targetPath[seq] {
+		node := h.nodes[seq]
+		steps = append(steps, Step{Tx: &node.Tx, Undo: true})
+		h.nodes[node.Parent].redoChild = seq
+		seq = node.Parent
+	}
+
+	// Then down to the target
+	var down []int
+	for s := target; s != seq; s = h.nodes[s].Parent {
+		down = append(down, s)
+	}
+	for i := len(down) - 1; i >= 0; i-- {
+		node := h.nodes[down[i]]
+		h.nodes[node.Parent].redoChild = node.Seq
+		steps = append(steps, Step{Tx: &node.Tx})
+	}
+
+	h.current = target
+	return steps
+}
+
+// SeqAt returns the newest state created at or before t
+func (h *History) SeqAt(t time.Time) int {
+	best := h.root
+	for seq, node := range h.nodes {
+		if !node.Time.After(t) && seq > best {
+			best = seq
+		}
+	}
+	return best
+}
+
+// SeqSteps returns the state n steps away from the current one in creation
+// order. Negative n goes back in time.
+func (h *History) SeqSteps(n int) int {
+	seqs := make([]int, 0, len(h.nodes))
+	for seq := range h.nodes {
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+
+	idx := sort.SearchInts(seqs, h.current) + n
+	idx = min(max(idx, 0), len(seqs)-1)
+	return seqs[idx]
+}
+
+// MarkSaved records the current state as the one on disk
+func (h *History) MarkSaved() {
+	h.savePoint = h.current
+}
+
+// AtSavePoint reports whether the buffer matches the last saved state
+func (h *History) AtSavePoint() bool {
+	return h.current == h.savePoint
+}
+
+// State returns a snapshot of the committed states
+func (h *History) State() HistoryState {
+	state := HistoryState{Current: h.current}
+	for _, node := range h.Nodes() {
+		state.Nodes = append(state.Nodes, *node)
+	}
+	return state
+}
+
+// Restore replaces the history with a snapshot taken by State. The restored
+// current state is treated as the saved state.
+func (h *History) Restore(state HistoryState) {
+	nodes := make(map[int]*UndoNode, len(state.Nodes))
+	root, nextSeq := -1, 0
+	for i := range state.Nodes {
+		node := state.Nodes[i]
+		node.children = nil
+		node.redoChild = -1
+		nodes[node.Seq] = &node
+		if node.Parent == -1 {
+			root = node.Seq
+		}
+		nextSeq = max(nextSeq, node.Seq+1)
+	}
+	if root == -1 || nodes[state.Current] == nil {
+		return
+	}
+
+	// Rebuild child links in creation order so redo follows the newest branch
+	for _, node := range state.Nodes {
+		if node.Parent == -1 {
+			continue
+		}
+		parent, ok := nodes[node.Parent]
+		if !ok {
+			return
+		}
+		parent.children = append(parent.children, node.Seq)
+	}
+	for _, node := range nodes {
+		sort.Ints(node.children)
+		if n := len(node.children); n > 0 {
+			node.redoChild = node.children[n-1]
+		}
+	}
+
+	h.nodes = nodes
+	h.root = root
+	h.current = state.Current
+	h.nextSeq = nextSeq
+	h.pending = nil
+	h.depth = 0
+	h.savePoint = h.current
+
+	// Make redo from the restored state follow the path it was reached by
+	for seq := h.current; nodes[seq].Parent != -1; seq = nodes[seq].Parent {
+		nodes[nodes[seq].Parent].redoChild = seq
+	}
+	h.trim()
 }
@@ -0,0 +1,51 @@
+package buffer
+
+// lineStates caches the lexer state at the start of each line, so
+// highlighting can resume partway through the file instead of lexing it
+// from the top. The states form a prefix of the buffer: states[i] is valid
+// for line i, and an edit drops everything below the edited line.
+type lineStates struct {
+	key    string // what the states were computed for, e.g. the language
+	states []int
+}
+
+// LineState returns the cached lexer state at the start of line, if the
+// cache was filled for key that far
+func (b *Buffer) LineState(key string, line int) (int, bool) {
+	if key != b.lexCache.key || line < 0 || line >= len(b.lexCache.states) {
+		return 0, false
+	}
+	return b.lexCache.states[line], true
+}
+
+// CachedLineStates returns how many lines from the top have a cached state
+// for key
+func (b *Buffer) CachedLineStates(key string) int {
+	if key != b.lexCache.key {
+		return 0
+	}
+	return len(b.lexCache.states)
+}
+
+// SetLineState caches the lexer state at the start of line. States are
+// filled in from the top, so line must be at most one past the cached
+// prefix; a different key starts the cache over.
+func (b *Buffer) SetLineState(key string, line, state int) {
+	if key != b.lexCache.key {
+		b.lexCache = lineStates{key: key}
+	}
+	switch {
+	case line == len(b.lexCache.states):
+		b.lexCache.states = append(b.lexCache.states, state)
+	case line >= 0 && line < len(b.lexCache.states):
+		b.lexCache.states[line] = state
+	}
+}
+
+// invalidateLineStates drops the cached states below line. The state at
+// the start of line only depends on the lines above it, so it stays.
+func (b *Buffer) invalidateLineStates(line int) {
+	if line+1 < len(b.lexCache.states) {
+		b.lexCache.states = b.lexCache.states[:max(line+1, 0)]
+	}
+}
@@ -11,6 +11,8 @@ type Manager struct {
 	buffers      map[string]*Buffer
 	activeBuffer string
 	bufferOrder  []string
+	historySize  int
+	tabSize      int
 }
 
 // NewManager creates a new buffer manager
@@ -18,6 +20,24 @@ func NewManager() *Manager {
 	return &Manager{
 		buffers:     make(map[string]*Buffer),
 		bufferOrder: make([]string, 0),
+		historySize: defaultHistorySize,
+		tabSize:     tabSize,
+	}
+}
+
+// SetHistoryLimit sets the number of undo steps kept by every buffer
+func (m *Manager) SetHistoryLimit(size int) {
+	m.historySize = size
+	for _, b := range m.buffers {
+		b.History().SetMaxSize(size)
+	}
+}
+
+// SetTabSize sets the indent width of every buffer
+func (m *Manager) SetTabSize(size int) {
+	m.tabSize = size
+	for _, b := range m.buffers {
+		b.SetTabSize(size)
 	}
 }
 
@@ -26,6 +46,8 @@ func (m *Manager) NewBuffer() *Buffer {
 	id := uuid.New().String()
 	buffer := New()
 	buffer.SetID(id)
+	buffer.History().SetMaxSize(m.historySize)
+	buffer.SetTabSize(m.tabSize)
 
 	m.buffers[id] = buffer
 	m.bufferOrder = append(m.bufferOrder, id)
@@ -47,6 +69,8 @@ func (m *Manager) OpenBuffer(filepath, content string) (*Buffer, error) {
 	id := uuid.New().String()
 	buffer := NewFromContent(content, filepath)
 	buffer.SetID(id)
+	buffer.History().SetMaxSize(m.historySize)
+	buffer.SetTabSize(m.tabSize)
 
 	m.buffers[id] = buffer
 	m.bufferOrder = append(m.bufferOrder, id)
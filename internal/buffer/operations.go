@@ -22,11 +22,11 @@ const tabSize = 4
 
 // InsertRune inserts a rune at cursor position
 func (b *Buffer) InsertRune(line, col int, r rune) {
-	if line < 0 || line >= len(b.lines) {
+	if line < 0 || line >= b.text.Len() {
 		return
 	}
 
-	currentLine := b.lines[line]
+	currentLine := b.text.Line(line)
 	if col < 0 {
 		col = 0
 	}
@@ -39,8 +39,7 @@ func (b *Buffer) InsertRune(line, col int, r rune) {
 		for i := range len(autoPairTagExt) {
 			if autoPairTagExt[i] == filepath.Ext(b.Filepath()) {
 				insert := b.autoCloseTags(currentLine, col)
-				b.lines[line] = currentLine[:col] + insert + currentLine[col:]
-				b.modified = true
+				b.setLine(line, currentLine[:col]+insert+currentLine[col:])
 				return
 			}
 		}
@@ -48,49 +47,44 @@ func (b *Buffer) InsertRune(line, col int, r rune) {
 
 	// Regular auto-pairing for brackets and quotes
 	if closing, ok := autoPairMap[r]; ok {
-		b.lines[line] = currentLine[:col] + string(r) + string(closing) + currentLine[col:]
-		b.modified = true
+		b.setLine(line, currentLine[:col]+string(r)+string(closing)+currentLine[col:])
 		return
 	}
 
 	// Normal character insertion
-	b.lines[line] = currentLine[:col] + string(r) + currentLine[col:]
-	b.modified = true
+	b.setLine(line, currentLine[:col]+string(r)+currentLine[col:])
 }
 
 // DeleteRune deletes a rune at a position (backspace)
 func (b *Buffer) DeleteRune(line, col int) {
-	if line < 0 || line >= len(b.lines) {
+	if line < 0 || line >= b.text.Len() {
 		return
 	}
 
-	currentLine := b.lines[line]
+	currentLine := b.text.Line(line)
 
 	// Merge with previous line if at start
 	if col == 0 {
 		if line > 0 {
-			prevLine := b.lines[line-1]
-			b.lines[line-1] = prevLine + currentLine
-			b.lines = append(b.lines[:line], b.lines[line+1:]...)
-			b.modified = true
+			prevLine := b.text.Line(line - 1)
+			b.replaceLines(line-1, 2, []string{prevLine + currentLine})
 		}
 		return
 	}
 
 	// Delete the character before cursor
 	if col > 0 && col <= len(currentLine) {
-		b.lines[line] = currentLine[:col-1] + currentLine[col:]
-		b.modified = true
+		b.setLine(line, currentLine[:col-1]+currentLine[col:])
 	}
 }
 
 // InsertNewline inserts a newline at position with auto-indentation
 func (b *Buffer) InsertNewline(line, col int) {
-	if line < 0 || line >= len(b.lines) {
+	if line < 0 || line >= b.text.Len() {
 		return
 	}
 
-	currentLine := b.lines[line]
+	currentLine := b.text.Line(line)
 	if col < 0 {
 		col = 0
 	}
@@ -101,7 +95,6 @@ func (b *Buffer) InsertNewline(line, col int) {
 	leftPart := currentLine[:col]
 	rightPart := currentLine[col:]
 
-
 	// --- Detect current indentation ---
 	currentIndent := countLeadingTabsOrSpaces(leftPart)
 
@@ -109,7 +102,7 @@ func (b *Buffer) InsertNewline(line, col int) {
 	trimmedLeft := strings.TrimSpace(leftPart)
 	shouldIncrease := strings.HasSuffix(trimmedLeft, "{") ||
 		strings.HasSuffix(trimmedLeft, "[") ||
-		strings.HasSuffix(trimmedLeft, "(") || 
+		strings.HasSuffix(trimmedLeft, "(") ||
 		strings.HasSuffix(trimmedLeft, ":")
 
 	// --- Create the indentation strings ---
@@ -117,29 +110,13 @@ func (b *Buffer) InsertNewline(line, col int) {
 	increasedIndent := makeIndent(currentIndent + b.indentWidth())
 
 	if shouldIncrease {
-		// Split line into two
-		b.lines[line] = leftPart
-
-		// Construct the new lines properly
-		newLines := make([]string, 0, len(b.lines)+2)
-		newLines = append(newLines, b.lines[:line+1]...)
-		newLines = append(newLines, increasedIndent)      // middle indented line
-		newLines = append(newLines, baseIndent+rightPart) // next line with closing brace or continuation
-		newLines = append(newLines, b.lines[line+1:]...)  // rest of document
-
-		b.lines = newLines
+		// Split line into two: an indented middle line and the closing brace or continuation
+		b.replaceLines(line, 1, []string{leftPart, increasedIndent, baseIndent + rightPart})
 		b.cursor.SetPosition(line, len(increasedIndent))
 	} else {
 		// Normal newline
-		b.lines[line] = leftPart
-		newLines := make([]string, 0, len(b.lines)+1)
-		newLines = append(newLines, b.lines[:line+1]...)
-		newLines = append(newLines, baseIndent+rightPart)
-		newLines = append(newLines, b.lines[line+1:]...)
-		b.lines = newLines
+		b.replaceLines(line, 1, []string{leftPart, baseIndent + rightPart})
 	}
-
-	b.modified = true
 }
 
 // makeIndent builds a string of tabs/spaces matching indentation width
@@ -149,44 +126,118 @@ func makeIndent(width int) string {
 
 // DeleteLine deletes an entire line
 func (b *Buffer) DeleteLine(line int) {
-	if line < 0 || line >= len(b.lines) {
+	if line < 0 || line >= b.text.Len() {
 		return
 	}
 
-	b.lines = append(b.lines[:line], b.lines[line+1:]...)
-	if len(b.lines) == 0 {
-		b.lines = []string{""}
-	}
-	b.modified = true
+	b.replaceLines(line, 1, nil)
 }
 
-// InsertText inserts text at position
+// InsertText inserts text at position as a single change. Unlike typing,
+// the text is inserted verbatim without auto-pairing or auto-indentation.
 func (b *Buffer) InsertText(line, col int, text string) {
+	if line < 0 || line >= b.text.Len() {
+		return
+	}
+
+	currentLine := b.text.Line(line)
+	col = min(max(col, 0), len(currentLine))
+
 	lines := strings.Split(text, "\n")
+	lines[0] = currentLine[:col] + lines[0]
+	lines[len(lines)-1] += currentLine[col:]
 
-	if len(lines) == 1 {
-		// Single line insert
-		for _, r := range text {
-			b.InsertRune(line, col, r)
-			col++
-		}
+	b.replaceLines(line, 1, lines)
+}
+
+// InsertLines inserts whole lines before line. line may equal LineCount to
+// append at the end of the buffer.
+func (b *Buffer) InsertLines(line int, lines []string) {
+	if line < 0 || line > b.text.Len() || len(lines) == 0 {
+		return
+	}
+
+	b.replaceLines(line, 0, lines)
+}
+
+// DeleteLines deletes lines start through end inclusive
+func (b *Buffer) DeleteLines(start, end int) {
+	start = max(start, 0)
+	end = min(end, b.text.Len()-1)
+	if start > end {
+		return
+	}
+
+	b.replaceLines(start, end-start+1, nil)
+}
+
+// DeleteRange deletes the text from (startLine, startCol) up to but not
+// including (endLine, endCol), joining the remaining ends
+func (b *Buffer) DeleteRange(startLine, startCol, endLine, endCol int) {
+	if startLine > endLine || (startLine == endLine && startCol > endCol) {
+		startLine, endLine = endLine, startLine
+		startCol, endCol = endCol, startCol
+	}
+	if startLine < 0 || endLine >= b.text.Len() {
 		return
 	}
 
-	// Multi-line insert
-	currentLine := b.Line(line)
-	before := currentLine[:col]
-	after := currentLine[col:]
+	first := b.text.Line(startLine)
+	last := b.text.Line(endLine)
+	startCol = min(max(startCol, 0), len(first))
+	endCol = min(max(endCol, 0), len(last))
 
-	b.SetLine(line, before+lines[0])
+	b.replaceLines(startLine, endLine-startLine+1, []string{first[:startCol] + last[endCol:]})
+}
+
+// setLine replaces the content of a single line
+func (b *Buffer) setLine(line int, content string) {
+	b.replaceLines(line, 1, []string{content})
+}
 
-	for i := 1; i < len(lines)-1; i++ {
-		b.InsertNewline(line+i-1, len(b.Line(line+i-1)))
-		b.SetLine(line+i, lines[i])
+// replaceLines replaces count lines starting at line with lines, recording
+// the change in the undo history. Every mutation goes through here.
+func (b *Buffer) replaceLines(line, count int, lines []string) {
+	// The buffer always keeps at least one line
+	if count == b.text.Len() && len(lines) == 0 {
+		lines = []string{""}
 	}
 
-	b.InsertNewline(line+len(lines)-2, len(b.Line(line+len(lines)-2)))
-	b.SetLine(line+len(lines)-1, lines[len(lines)-1]+after)
+	change := Change{
+		Line:     line,
+		Removed:  b.text.Slice(line, line+count),
+		Inserted: lines,
+	}
+
+	if b.history.InTransaction() {
+		b.history.Record(change)
+		b.splice(line, count, lines)
+	} else {
+		b.history.Begin(b.cursorPosition())
+		b.history.Record(change)
+		b.splice(line, count, lines)
+		b.history.Commit(b.cursorPosition())
+	}
+
+	b.modified = true
+}
+
+// splice applies a change to the text without recording it
+func (b *Buffer) splice(line, count int, lines []string) {
+	b.invalidateLineStates(line)
+	b.shiftFolds(line, count, len(lines))
+	b.recordEdit(line, count, len(lines))
+	b.version++
+
+	switch {
+	case count == b.text.Len():
+		b.text = newRope(lines)
+	case count == 1 && len(lines) == 1:
+		b.text.Set(line, lines[0])
+	default:
+		b.text.Delete(line, count)
+		b.text.Insert(line, lines...)
+	}
 }
 
 // countLeadingTabsOrSpaces counts indentation width
@@ -0,0 +1,280 @@
+package buffer
+
+import (
+	"math/bits"
+	"strings"
+)
+
+// maxLeafLines is the most lines a rope leaf holds before it is split
+const maxLeafLines = 128
+
+// rope stores the lines of a document in a balanced tree of line chunks.
+// Lookups, inserts and deletes cost O(log n) in the number of lines, so an
+// edit at the top of a large file is as cheap as one at the bottom.
+type rope struct {
+	root *ropeNode
+}
+
+// ropeNode is either a leaf holding a chunk of lines or an inner node
+// joining two subtrees
+type ropeNode struct {
+	left   *ropeNode
+	right  *ropeNode
+	lines  []string
+	count  int // total lines in this subtree
+	height int
+}
+
+func (n *ropeNode) isLeaf() bool {
+	return n.left == nil
+}
+
+func newLeaf(lines []string) *ropeNode {
+	return &ropeNode{lines: lines, count: len(lines), height: 1}
+}
+
+func newInner(left, right *ropeNode) *ropeNode {
+	n := &ropeNode{left: left, right: right}
+	n.update()
+	return n
+}
+
+func (n *ropeNode) update() {
+	n.count = n.left.count + n.right.count
+	n.height = max(n.left.height, n.right.height) + 1
+}
+
+// newRope builds a balanced rope from lines
+func newRope(lines []string) *rope {
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+	return &rope{root: build(lines)}
+}
+
+// build creates a balanced subtree over lines, copying them into leaves
+func build(lines []string) *ropeNode {
+	if len(lines) <= maxLeafLines {
+		leaf := make([]string, len(lines), maxLeafLines)
+		copy(leaf, lines)
+		return newLeaf(leaf)
+	}
+	// Split on a leaf boundary so leaves stay full
+	leaves := (len(lines) + maxLeafLines - 1) / maxLeafLines
+	mid := (leaves / 2) * maxLeafLines
+	return newInner(build(lines[:mid]), build(lines[mid:]))
+}
+
+// Len returns the number of lines
+func (r *rope) Len() int {
+	return r.root.count
+}
+
+// Line returns line n
+func (r *rope) Line(n int) string {
+	node := r.root
+	for !node.isLeaf() {
+		if n < node.left.count {
+			node = node.left
+		} else {
+			n -= node.left.count
+			node = node.right
+		}
+	}
+	return node.lines[n]
+}
+
+// Set replaces line n
+func (r *rope) Set(n int, s string) {
+	node := r.root
+	for !node.isLeaf() {
+		if n < node.left.count {
+			node = node.left
+		} else {
+			n -= node.left.count
+			node = node.right
+		}
+	}
+	node.lines[n] = s
+}
+
+// Insert inserts lines before line n. n may equal Len to append.
+func (r *rope) Insert(n int, lines ...string) {
+	if len(lines) == 0 {
+		return
+	}
+	r.root = insert(r.root, n, lines)
+}
+
+func insert(node *ropeNode, n int, lines []string) *ropeNode {
+	if node.isLeaf() {
+		if len(node.lines)+len(lines) <= maxLeafLines {
+			node.lines = append(node.lines, lines...)
+			copy(node.lines[n+len(lines):], node.lines[n:])
+			copy(node.lines[n:], lines)
+			node.count = len(node.lines)
+			return node
+		}
+		joined := make([]string, 0, len(node.lines)+len(lines))
+		joined = append(joined, node.lines[:n]...)
+		joined = append(joined, lines...)
+		joined = append(joined, node.lines[n:]...)
+		if len(joined) > 2*maxLeafLines {
+			return build(joined)
+		}
+		// Split into two half-full leaves so repeated inserts at the same
+		// spot don't overflow straight away
+		half := len(joined) / 2
+		return newInner(build(joined[:half]), build(joined[half:]))
+	}
+
+	if n <= node.left.count {
+		node.left = insert(node.left, n, lines)
+	} else {
+		node.right = insert(node.right, n-node.left.count, lines)
+	}
+	return balance(node)
+}
+
+// Delete removes count lines starting at line n
+func (r *rope) Delete(n, count int) {
+	if count <= 0 {
+		return
+	}
+	r.root = remove(r.root, n, count)
+	if r.root == nil {
+		r.root = newLeaf(make([]string, 1, maxLeafLines))
+		return
+	}
+
+	// Large range deletes can drop a subtree by several levels at once,
+	// which single rotations don't fully repair. Rebuild if it got lopsided.
+	if r.root.height > 2*bits.Len(uint(r.root.count/maxLeafLines+1))+2 {
+		r.root = build(r.Slice(0, r.Len()))
+	}
+}
+
+// remove deletes lines [n, n+count) and returns the new subtree, or nil if
+// the subtree became empty
+func remove(node *ropeNode, n, count int) *ropeNode {
+	if node.isLeaf() {
+		end := min(n+count, len(node.lines))
+		node.lines = append(node.lines[:n], node.lines[end:]...)
+		node.count = len(node.lines)
+		if node.count == 0 {
+			return nil
+		}
+		return node
+	}
+
+	leftCount := node.left.count
+	if n < leftCount {
+		take := min(count, leftCount-n)
+		node.left = remove(node.left, n, take)
+		count -= take
+		n = leftCount
+	}
+	if count > 0 {
+		node.right = remove(node.right, n-leftCount, count)
+	}
+
+	switch {
+	case node.left == nil:
+		return node.right
+	case node.right == nil:
+		return node.left
+	}
+	return balance(node)
+}
+
+// balance restores the AVL invariant on node after one of its children changed
+func balance(node *ropeNode) *ropeNode {
+	node.update()
+	diff := node.left.height - node.right.height
+	switch {
+	case diff > 1:
+		if node.left.right.height > node.left.left.height {
+			node.left = rotateLeft(node.left)
+		}
+		return rotateRight(node)
+	case diff < -1:
+		if node.right.left.height > node.right.right.height {
+			node.right = rotateRight(node.right)
+		}
+		return rotateLeft(node)
+	}
+	return mergeSmall(node)
+}
+
+// mergeSmall folds two small sibling leaves back into one so deletes don't
+// leave the tree full of tiny chunks
+func mergeSmall(node *ropeNode) *ropeNode {
+	if node.left.isLeaf() && node.right.isLeaf() && node.count <= maxLeafLines/2 {
+		lines := make([]string, 0, maxLeafLines)
+		lines = append(lines, node.left.lines...)
+		lines = append(lines, node.right.lines...)
+		return newLeaf(lines)
+	}
+	return node
+}
+
+func rotateLeft(node *ropeNode) *ropeNode {
+	r := node.right
+	node.right = r.left
+	node.update()
+	r.left = node
+	r.update()
+	return r
+}
+
+func rotateRight(node *ropeNode) *ropeNode {
+	l := node.left
+	node.left = l.right
+	node.update()
+	l.right = node
+	l.update()
+	return l
+}
+
+// Slice returns a copy of lines [start, end)
+func (r *rope) Slice(start, end int) []string {
+	out := make([]string, 0, max(end-start, 0))
+	r.walk(r.root, start, end, func(s string) {
+		out = append(out, s)
+	})
+	return out
+}
+
+// walk calls fn for each line in [start, end) of node, in order
+func (r *rope) walk(node *ropeNode, start, end int, fn func(string)) {
+	if start >= end || node == nil {
+		return
+	}
+	if node.isLeaf() {
+		for _, s := range node.lines[max(start, 0):min(end, len(node.lines))] {
+			fn(s)
+		}
+		return
+	}
+	lc := node.left.count
+	if start < lc {
+		r.walk(node.left, start, min(end, lc), fn)
+	}
+	if end > lc {
+		r.walk(node.right, max(start-lc, 0), end-lc, fn)
+	}
+}
+
+// String joins every line with newlines
+func (r *rope) String() string {
+	var b strings.Builder
+	first := true
+	r.walk(r.root, 0, r.Len(), func(s string) {
+		if !first {
+			b.WriteByte('\n')
+		}
+		first = false
+		b.WriteString(s)
+	})
+	return b.String()
+}
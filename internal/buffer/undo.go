@@ -0,0 +1,136 @@
+package buffer
+
+import "time"
+
+// History returns the buffer's undo history
+func (b *Buffer) History() *History {
+	return b.history
+}
+
+// BeginTransaction groups every change until the matching
+// CommitTransaction into a single undo step
+func (b *Buffer) BeginTransaction() {
+	b.history.Begin(b.cursorPosition())
+}
+
+// CommitTransaction closes the transaction opened by BeginTransaction
+func (b *Buffer) CommitTransaction() {
+	b.history.Commit(b.cursorPosition())
+}
+
+// Undo reverts the last transaction and restores the cursor to where it
+// was before it. Returns false if there is nothing to undo.
+func (b *Buffer) Undo() bool {
+	if b.history.InTransaction() {
+		return false
+	}
+
+	tx := b.history.Undo()
+	if tx == nil {
+		return false
+	}
+
+	b.applyTransaction(tx, true)
+	b.restoreCursor(tx.Before)
+	b.modified = !b.history.AtSavePoint()
+	return true
+}
+
+// Redo reapplies the last undone transaction. Returns false if there is
+// nothing to redo.
+func (b *Buffer) Redo() bool {
+	if b.history.InTransaction() {
+		return false
+	}
+
+	tx := b.history.Redo()
+	if tx == nil {
+		return false
+	}
+
+	b.applyTransaction(tx, false)
+	b.restoreCursor(tx.After)
+	b.modified = !b.history.AtSavePoint()
+	return true
+}
+
+// UndoTo moves the buffer to any state in the undo tree, undoing and
+// redoing across branches as needed. Returns false if seq is unknown or
+// already current.
+func (b *Buffer) UndoTo(seq int) bool {
+	if b.history.InTransaction() {
+		return false
+	}
+
+	steps := b.history.Jump(seq)
+	if len(steps) == 0 {
+		return false
+	}
+
+	for _, step := range steps {
+		b.applyTransaction(step.Tx, step.Undo)
+	}
+
+	last := steps[len(steps)-1]
+	if last.Undo {
+		b.restoreCursor(last.Tx.Before)
+	} else {
+		b.restoreCursor(last.Tx.After)
+	}
+	b.modified = !b.history.AtSavePoint()
+	return true
+}
+
+// Earlier moves back to the state the buffer was in d before the current
+// one, like vim's :earlier 5m
+func (b *Buffer) Earlier(d time.Duration) bool {
+	node := b.history.Node(b.history.Current())
+	return b.UndoTo(b.history.SeqAt(node.Time.Add(-d)))
+}
+
+// Later moves forward to the state the buffer was in d after the current one
+func (b *Buffer) Later(d time.Duration) bool {
+	node := b.history.Node(b.history.Current())
+	target := b.history.SeqAt(node.Time.Add(d))
+	if target < node.Seq {
+		return false
+	}
+	return b.UndoTo(target)
+}
+
+// EarlierSteps moves back n states in the order they were created,
+// regardless of branch
+func (b *Buffer) EarlierSteps(n int) bool {
+	return b.UndoTo(b.history.SeqSteps(-n))
+}
+
+// LaterSteps moves forward n states in the order they were created
+func (b *Buffer) LaterSteps(n int) bool {
+	return b.UndoTo(b.history.SeqSteps(n))
+}
+
+// applyTransaction applies tx, or reverts it if undo is set
+func (b *Buffer) applyTransaction(tx *Transaction, undo bool) {
+	if undo {
+		for i := len(tx.Changes) - 1; i >= 0; i-- {
+			ch := tx.Changes[i]
+			b.splice(ch.Line, len(ch.Inserted), ch.Removed)
+		}
+		return
+	}
+	for _, ch := range tx.Changes {
+		b.splice(ch.Line, len(ch.Removed), ch.Inserted)
+	}
+}
+
+func (b *Buffer) cursorPosition() Position {
+	line, col := b.cursor.Position()
+	return Position{Line: line, Col: col}
+}
+
+// restoreCursor moves the cursor to pos, clamped to the buffer
+func (b *Buffer) restoreCursor(pos Position) {
+	line := min(max(pos.Line, 0), b.text.Len()-1)
+	col := min(max(pos.Col, 0), len(b.text.Line(line)))
+	b.cursor.SetPosition(line, col)
+}
@@ -47,6 +47,74 @@ func CopySelection(buf *buffer.Buffer, startLine, startCol, endLine, endCol int)
 	return result.String()
 }
 
+// CopyLines copies lines startLine through endLine inclusive
+func CopyLines(buf *buffer.Buffer, startLine, endLine int) string {
+	if startLine > endLine {
+		startLine, endLine = endLine, startLine
+	}
+
+	lines := make([]string, 0, endLine-startLine+1)
+	for i := startLine; i <= endLine; i++ {
+		lines = append(lines, buf.Line(i))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// CopyBlock copies the rectangle of columns [startCol, endCol) from lines
+// startLine through endLine. Short lines contribute what they have.
+func CopyBlock(buf *buffer.Buffer, startLine, startCol, endLine, endCol int) string {
+	if startLine > endLine {
+		startLine, endLine = endLine, startLine
+	}
+	if startCol > endCol {
+		startCol, endCol = endCol, startCol
+	}
+
+	lines := make([]string, 0, endLine-startLine+1)
+	for i := startLine; i <= endLine; i++ {
+		line := buf.Line(i)
+		from := min(startCol, len(line))
+		to := min(endCol, len(line))
+		lines = append(lines, line[from:to])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PasteLines inserts text as whole lines below the cursor line, or above it
+// if above is set, and moves the cursor to the first pasted line
+func PasteLines(buf *buffer.Buffer, cur *cursor.Cursor, text string, above bool) {
+	at := cur.Line() + 1
+	if above {
+		at = cur.Line()
+	}
+
+	buf.InsertLines(at, strings.Split(text, "\n"))
+	cur.SetPosition(at, 0)
+}
+
+// PasteBlock inserts each line of text at the cursor column on successive
+// lines, padding short lines and adding lines past the end of the buffer
+func PasteBlock(buf *buffer.Buffer, cur *cursor.Cursor, text string) {
+	col := cur.Col()
+	lines := strings.Split(text, "\n")
+
+	buf.BeginTransaction()
+	defer buf.CommitTransaction()
+
+	for i, piece := range lines {
+		lineNum := cur.Line() + i
+		if lineNum >= buf.LineCount() {
+			buf.InsertLines(buf.LineCount(), []string{""})
+		}
+
+		line := buf.Line(lineNum)
+		if len(line) < col {
+			line += strings.Repeat(" ", col-len(line))
+		}
+		buf.SetLine(lineNum, line[:col]+piece+line[col:])
+	}
+}
+
 // PasteAtCursor pastes text at cursor
 func PasteAtCursor(buf *buffer.Buffer, cur *cursor.Cursor, text string) {
 	lines := strings.Split(text, "\n")
@@ -0,0 +1,35 @@
+package clipboard
+
+// Register remembers how the last yank was made so a paste can reproduce
+// it charwise, linewise or blockwise. The text itself lives in the
+// underlying clipboard.
+type Register struct {
+	clip Clipboard
+	text string
+	mode Mode
+}
+
+// NewRegister creates a register backed by clip
+func NewRegister(clip Clipboard) *Register {
+	return &Register{clip: clip, mode: ModeChar}
+}
+
+// Yank copies text to the clipboard and records its mode
+func (r *Register) Yank(text string, mode Mode) error {
+	r.text = text
+	r.mode = mode
+	return r.clip.Copy(text)
+}
+
+// Get returns the clipboard text and the mode it was yanked in. Text that
+// was copied outside the editor is treated as charwise.
+func (r *Register) Get() (string, Mode) {
+	text, err := r.clip.Paste()
+	if err != nil {
+		return "", ModeChar
+	}
+	if text != r.text {
+		return text, ModeChar
+	}
+	return text, r.mode
+}
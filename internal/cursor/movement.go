@@ -142,3 +142,120 @@ func (c *Cursor) MoveWordBackward(buf BufferReader) {
 func isWordBoundary(r rune) bool {
 	return r == ' ' || r == '\t' || r == '\n' || r == '.' || r == ',' || r == ';' || r == ':' || r == '(' || r == ')' || r == '[' || r == ']' || r == '{' || r == '}'
 }
+
+// MoveWordEnd moves to the end of the current or next word
+func (c *Cursor) MoveWordEnd(buf BufferReader) {
+	line := buf.Line(c.line)
+	col := c.col + 1
+
+	// Skip whitespace, crossing lines if needed
+	for {
+		for col < len(line) && isWordBoundary(rune(line[col])) {
+			col++
+		}
+		if col < len(line) || c.line >= buf.LineCount()-1 {
+			break
+		}
+		c.line++
+		line = buf.Line(c.line)
+		col = 0
+	}
+
+	// Move to the last character of the word
+	for col+1 < len(line) && !isWordBoundary(rune(line[col+1])) {
+		col++
+	}
+	c.col = min(col, max(len(line)-1, 0))
+}
+
+// MoveToFirstNonBlank moves to the first non-whitespace character of the line
+func (c *Cursor) MoveToFirstNonBlank(buf BufferReader) {
+	line := buf.Line(c.line)
+	c.col = 0
+	for c.col < len(line) && (line[c.col] == ' ' || line[c.col] == '\t') {
+		c.col++
+	}
+}
+
+// MoveToLine moves to the first non-blank character of line n
+func (c *Cursor) MoveToLine(buf BufferReader, n int) {
+	c.line = min(max(n, 0), buf.LineCount()-1)
+	c.MoveToFirstNonBlank(buf)
+}
+
+// MoveParagraphForward moves to the next blank line after a paragraph
+func (c *Cursor) MoveParagraphForward(buf BufferReader) {
+	n := c.line
+	for n < buf.LineCount()-1 && isBlank(buf.Line(n)) {
+		n++
+	}
+	for n < buf.LineCount()-1 && !isBlank(buf.Line(n)) {
+		n++
+	}
+	c.line = n
+	c.col = 0
+	if n == buf.LineCount()-1 && !isBlank(buf.Line(n)) {
+		c.col = len(buf.Line(n))
+	}
+}
+
+// MoveParagraphBackward moves to the previous blank line before a paragraph
+func (c *Cursor) MoveParagraphBackward(buf BufferReader) {
+	n := c.line
+	for n > 0 && isBlank(buf.Line(n)) {
+		n--
+	}
+	for n > 0 && !isBlank(buf.Line(n)) {
+		n--
+	}
+	c.line = n
+	c.col = 0
+}
+
+// FindChar moves to the next occurrence of r on the line, or the previous
+// one if backward is set. With till set it stops one character short.
+// Returns false if r was not found.
+func (c *Cursor) FindChar(buf BufferReader, r byte, backward, till bool) bool {
+	line := buf.Line(c.line)
+
+	if backward {
+		start := c.col - 1
+		if till {
+			start--
+		}
+		for i := start; i >= 0; i-- {
+			if line[i] == r {
+				c.col = i
+				if till {
+					c.col++
+				}
+				return true
+			}
+		}
+		return false
+	}
+
+	start := c.col + 1
+	if till {
+		start++
+	}
+	for i := start; i < len(line); i++ {
+		if line[i] == r {
+			c.col = i
+			if till {
+				c.col--
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func isBlank(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] != ' ' && s[i] != '\t' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,239 @@
+package cursor
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/tobibamidele/minra/internal/syntax/matchers"
+)
+
+// Range is a span of text selected by a motion or text object. The end is
+// exclusive. Linewise ranges cover whole lines and ignore the columns.
+type Range struct {
+	StartLine int
+	StartCol  int
+	EndLine   int
+	EndCol    int
+	Linewise  bool
+}
+
+// TextObject returns the range of the text object named by kind ('w', 'W',
+// '"', '\”, '`', '(', ')', 'b', '[', ']', '{', '}', 'B', '<', '>', 'p' or
+// 't') around the cursor. around selects the "a" variant instead of "inner".
+func (c *Cursor) TextObject(buf BufferReader, kind rune, around bool) (Range, bool) {
+	switch kind {
+	case 'w':
+		return wordObject(buf, c.line, c.col, around, false)
+	case 'W':
+		return wordObject(buf, c.line, c.col, around, true)
+	case '"', '\'', '`':
+		return quoteObject(buf, c.line, c.col, byte(kind), around)
+	case '(', ')', 'b':
+		return bracketObject(buf, c.line, c.col, '(', ')', around)
+	case '[', ']':
+		return bracketObject(buf, c.line, c.col, '[', ']', around)
+	case '{', '}', 'B':
+		return bracketObject(buf, c.line, c.col, '{', '}', around)
+	case '<', '>':
+		return bracketObject(buf, c.line, c.col, '<', '>', around)
+	case 'p':
+		return paragraphObject(buf, c.line, around)
+	case 't':
+		return tagObject(buf, c.line, c.col, around)
+	}
+	return Range{}, false
+}
+
+// charClass groups characters the way word motions do: whitespace, word
+// characters and punctuation. Big words only split on whitespace.
+func charClass(b byte, bigWord bool) int {
+	switch {
+	case b == ' ' || b == '\t':
+		return 0
+	case bigWord:
+		return 1
+	case b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b >= 0x80:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func wordObject(buf BufferReader, line, col int, around, bigWord bool) (Range, bool) {
+	text := buf.Line(line)
+	if len(text) == 0 {
+		return Range{}, false
+	}
+	col = min(col, len(text)-1)
+
+	class := charClass(text[col], bigWord)
+	start, end := col, col+1
+	for start > 0 && charClass(text[start-1], bigWord) == class {
+		start--
+	}
+	for end < len(text) && charClass(text[end], bigWord) == class {
+		end++
+	}
+
+	if around && class != 0 {
+		// Take trailing whitespace, or leading whitespace if there is none
+		trail := end
+		for trail < len(text) && charClass(text[trail], bigWord) == 0 {
+			trail++
+		}
+		if trail > end {
+			end = trail
+		} else {
+			for start > 0 && charClass(text[start-1], bigWord) == 0 {
+				start--
+			}
+		}
+	}
+
+	return Range{StartLine: line, StartCol: start, EndLine: line, EndCol: end}, true
+}
+
+func quoteObject(buf BufferReader, line, col int, quote byte, around bool) (Range, bool) {
+	text := buf.Line(line)
+
+	// Pair up unescaped quotes from the start of the line
+	var quotes []int
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' {
+			i++
+			continue
+		}
+		if text[i] == quote {
+			quotes = append(quotes, i)
+		}
+	}
+
+	for i := 0; i+1 < len(quotes); i += 2 {
+		open, close := quotes[i], quotes[i+1]
+		// Use the pair under the cursor, or the first one after it
+		if col <= close {
+			if around {
+				return Range{StartLine: line, StartCol: open, EndLine: line, EndCol: close + 1}, true
+			}
+			return Range{StartLine: line, StartCol: open + 1, EndLine: line, EndCol: close}, true
+		}
+	}
+	return Range{}, false
+}
+
+func bracketObject(buf BufferReader, line, col int, open, close byte, around bool) (Range, bool) {
+	openLine, openCol, closeLine, closeCol, ok := matchers.FindEnclosingBracket(buf, line, col, open, close)
+	if !ok {
+		return Range{}, false
+	}
+
+	if around {
+		return Range{StartLine: openLine, StartCol: openCol, EndLine: closeLine, EndCol: closeCol + 1}, true
+	}
+
+	r := Range{StartLine: openLine, StartCol: openCol + 1, EndLine: closeLine, EndCol: closeCol}
+	// A block whose brackets sit on their own lines selects the lines between
+	if openCol+1 == len(buf.Line(openLine)) && isBlank(buf.Line(closeLine)[:closeCol]) && closeLine > openLine+1 {
+		return Range{StartLine: openLine + 1, EndLine: closeLine - 1, Linewise: true}, true
+	}
+	return r, true
+}
+
+func paragraphObject(buf BufferReader, line int, around bool) (Range, bool) {
+	blank := isBlank(buf.Line(line))
+	start, end := line, line
+	for start > 0 && isBlank(buf.Line(start-1)) == blank {
+		start--
+	}
+	for end < buf.LineCount()-1 && isBlank(buf.Line(end+1)) == blank {
+		end++
+	}
+
+	if around {
+		// Take the run of lines of the other kind that follows. A paragraph
+		// at the end of the file takes the blank lines before it instead.
+		trail := end
+		for trail < buf.LineCount()-1 && isBlank(buf.Line(trail+1)) != blank {
+			trail++
+		}
+		if trail > end {
+			end = trail
+		} else if !blank {
+			for start > 0 && isBlank(buf.Line(start-1)) {
+				start--
+			}
+		}
+	}
+
+	return Range{StartLine: start, EndLine: end, Linewise: true}, true
+}
+
+var tagPattern = regexp.MustCompile(`<(/?)([A-Za-z][\w:.-]*)[^<>]*?(/?)>`)
+
+// tagObject finds the innermost <tag>...</tag> pair around the cursor
+func tagObject(buf BufferReader, line, col int, around bool) (Range, bool) {
+	lines := make([]string, buf.LineCount())
+	offsets := make([]int, buf.LineCount())
+	offset := 0
+	for i := range lines {
+		lines[i] = buf.Line(i)
+		offsets[i] = offset
+		offset += len(lines[i]) + 1
+	}
+	text := strings.Join(lines, "\n")
+	pos := offsets[line] + col
+
+	type openTag struct {
+		name       string
+		start, end int
+	}
+	var stack []openTag
+	best := [4]int{-1}
+
+	for _, m := range tagPattern.FindAllStringSubmatchIndex(text, -1) {
+		isClose := m[3] > m[2]
+		selfClosing := m[7] > m[6]
+		name := text[m[4]:m[5]]
+
+		switch {
+		case selfClosing:
+			continue
+		case !isClose:
+			stack = append(stack, openTag{name: name, start: m[0], end: m[1]})
+		default:
+			// Pop back to the matching open tag
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name != name {
+					continue
+				}
+				open := stack[i]
+				stack = stack[:i]
+				if open.start <= pos && pos < m[1] && (best[0] == -1 || open.start > best[0]) {
+					best = [4]int{open.start, open.end, m[0], m[1]}
+				}
+				break
+			}
+		}
+	}
+
+	if best[0] == -1 {
+		return Range{}, false
+	}
+
+	from, to := best[1], best[2]
+	if around {
+		from, to = best[0], best[3]
+	}
+	startLine, startCol := offsetToPosition(offsets, from)
+	endLine, endCol := offsetToPosition(offsets, to)
+	return Range{StartLine: startLine, StartCol: startCol, EndLine: endLine, EndCol: endCol}, true
+}
+
+// offsetToPosition converts an offset into joined text back to a line and column
+func offsetToPosition(offsets []int, offset int) (int, int) {
+	line := 0
+	for line+1 < len(offsets) && offsets[line+1] <= offset {
+		line++
+	}
+	return line, offset - offsets[line]
+}
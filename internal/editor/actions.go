@@ -0,0 +1,380 @@
+package editor
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tobibamidele/minra/internal/buffer"
+	"github.com/tobibamidele/minra/internal/clipboard"
+	"github.com/tobibamidele/minra/internal/viewport"
+	"github.com/tobibamidele/minra/internal/widgets"
+)
+
+// maxRecentActions is the number of palette actions remembered as recent
+const maxRecentActions = 8
+
+// action is an editor command that can be run from the command palette
+type action struct {
+	name    string  // stable identifier, e.g. "view.toggleLineNumbers"
+	title   string  // shown in the palette
+	key     KeyType // built-in key, shown when the keymap has no binding
+	grammar bool    // part of a normal or visual mode command, not in the palette
+	run     func(e *Editor) tea.Cmd
+}
+
+// actions lists everything the palette and the keymap can run. It is
+// filled in init because some actions open the palette, which reads it.
+var actions []action
+
+func init() {
+	actions = []action{
+		{name: "file.save", title: "File: Save", run: (*Editor).SaveFile},
+		{name: "file.new", title: "File: New", run: (*Editor).NewFile},
+		{name: "file.close", title: "File: Close", run: (*Editor).CloseFile},
+		{name: "file.openSelected", title: "File: Open Selected in Sidebar", run: (*Editor).openSelectedFile},
+		{name: "file.find", title: "File: Go to File", run: func(e *Editor) tea.Cmd {
+			return e.showFinder("")
+		}},
+		{name: "buffer.next", title: "Buffer: Next", run: func(e *Editor) tea.Cmd {
+			e.NextBuffer()
+			return nil
+		}},
+		{name: "buffer.previous", title: "Buffer: Previous", run: func(e *Editor) tea.Cmd {
+			e.PreviousBuffer()
+			return nil
+		}},
+		{name: "tab.moveLeft", title: "Tab: Move Left", run: func(e *Editor) tea.Cmd {
+			e.tabMgr.MoveTabLeft()
+			e.bufferMgr.MoveBufferLeft()
+			return nil
+		}},
+		{name: "tab.moveRight", title: "Tab: Move Right", run: func(e *Editor) tea.Cmd {
+			e.tabMgr.MoveTabRight()
+			e.bufferMgr.MoveBufferRight()
+			return nil
+		}},
+		{name: "sidebar.toggle", title: "Sidebar: Toggle", run: func(e *Editor) tea.Cmd {
+			if e.sidebar == nil {
+				e.statusMsg = "No sidebar"
+				return nil
+			}
+			e.sidebar.Toggle()
+			return nil
+		}},
+		{name: "sidebar.focus", title: "Sidebar: Focus", key: KeySidebarMode, run: func(e *Editor) tea.Cmd {
+			if e.sidebar == nil {
+				e.statusMsg = "No sidebar"
+				return nil
+			}
+			if e.sidebar.IsVisible() {
+				e.mode = viewport.ModeSidebar
+				e.statusMsg = "-- SIDEBAR --"
+			}
+			return nil
+		}},
+		{name: "view.toggleLineNumbers", title: "View: Toggle Line Numbers", run: func(e *Editor) tea.Cmd {
+			e.viewport.ToggleLineNumbers()
+			e.config.LineNumbers = e.viewport.LineNumbers()
+			return nil
+		}},
+		{name: "view.toggleSyntax", title: "View: Toggle Syntax Highlighting", run: func(e *Editor) tea.Cmd {
+			e.config.SyntaxHighlight = !e.config.SyntaxHighlight
+			return nil
+		}},
+		{name: "view.centerCursor", title: "View: Center Cursor", run: func(e *Editor) tea.Cmd {
+			if buf := e.bufferMgr.ActiveBuffer(); buf != nil {
+				e.viewport.CenterCursor(buf.Cursor())
+			}
+			return nil
+		}},
+		{name: "edit.undo", title: "Edit: Undo", key: KeyUndo, run: func(e *Editor) tea.Cmd {
+			e.undo(1)
+			return nil
+		}},
+		{name: "edit.redo", title: "Edit: Redo", key: KeyRedo, run: func(e *Editor) tea.Cmd {
+			e.redo(1)
+			return nil
+		}},
+		{name: "edit.history", title: "Edit: Browse Undo History", key: KeyHistory, run: func(e *Editor) tea.Cmd {
+			e.showHistory()
+			return nil
+		}},
+		{name: "mode.insert", title: "Mode: Insert", key: KeyInsert, run: func(e *Editor) tea.Cmd {
+			if e.mode != viewport.ModeInsert {
+				e.enterInsertMode()
+			}
+			return nil
+		}},
+		{name: "mode.visual", title: "Mode: Visual", key: KeyVisual, run: func(e *Editor) tea.Cmd {
+			e.enterVisualMode(clipboard.ModeChar)
+			return nil
+		}},
+		{name: "mode.visualLine", title: "Mode: Visual Line", key: KeyVisualLine, run: func(e *Editor) tea.Cmd {
+			e.enterVisualMode(clipboard.ModeLine)
+			return nil
+		}},
+		{name: "mode.visualBlock", title: "Mode: Visual Block", key: KeyVisualBlock, run: func(e *Editor) tea.Cmd {
+			e.enterVisualMode(clipboard.ModeBlock)
+			return nil
+		}},
+		{name: "mode.command", title: "Mode: Command Line", key: KeyColon, run: func(e *Editor) tea.Cmd {
+			e.showCommandLine("")
+			return nil
+		}},
+		{name: "edit.pasteClipboard", title: "Edit: Paste from System Clipboard", run: func(e *Editor) tea.Cmd {
+			text, _ := e.clipboard.Paste()
+			if text != "" {
+				e.pasteText(text)
+				e.statusMsg = "Pasted"
+			}
+			return nil
+		}},
+		{name: "palette.show", title: "Show Command Palette", run: func(e *Editor) tea.Cmd {
+			e.showPalette()
+			return nil
+		}},
+		{name: "symbol.outline", title: "Go to Symbol in File", run: func(e *Editor) tea.Cmd {
+			e.showOutline()
+			return nil
+		}},
+		{name: "fold.toggle", title: "Fold: Toggle", key: KeyFoldToggle, run: foldAction((*Editor).toggleFold)},
+		{name: "fold.close", title: "Fold: Close", key: KeyFoldClose, run: foldAction((*Editor).closeFold)},
+		{name: "fold.open", title: "Fold: Open", key: KeyFoldOpen, run: foldAction((*Editor).openFold)},
+		{name: "fold.openAll", title: "Fold: Open All", key: KeyFoldOpenAll, run: foldAction(func(e *Editor, buf *buffer.Buffer) {
+			e.setAllFolds(buf, false)
+		})},
+		{name: "fold.closeAll", title: "Fold: Close All", key: KeyFoldCloseAll, run: foldAction(func(e *Editor, buf *buffer.Buffer) {
+			e.setAllFolds(buf, true)
+		})},
+		{name: "theme.change", title: "Preferences: Color Theme", run: func(e *Editor) tea.Cmd {
+			e.showThemes()
+			return nil
+		}},
+		{name: "search.find", title: "Search: Find", key: KeySlash, run: func(e *Editor) tea.Cmd {
+			e.showSearch()
+			return nil
+		}},
+		{name: "search.workspace", title: "Search: Find in Files", run: func(e *Editor) tea.Cmd {
+			return e.showGrep("")
+		}},
+		{name: "search.replaceWorkspace", title: "Search: Replace in Files", run: func(e *Editor) tea.Cmd {
+			cmd := e.showGrep("")
+			e.grepWidget.SetReplacing(true)
+			e.grepWidget.FocusReplace(e.grepWidget.GetInput() != "")
+			e.statusMsg = "-- REPLACE IN FILES --"
+			return cmd
+		}},
+		{name: "search.undoReplaceWorkspace", title: "Search: Undo Replace in Files", run: func(e *Editor) tea.Cmd {
+			e.undoFileReplace()
+			return nil
+		}},
+		{name: "search.replace", title: "Search: Replace", run: func(e *Editor) tea.Cmd {
+			e.showSearch()
+			e.searchWidget.SetReplacing(true)
+			e.statusMsg = "-- REPLACE --"
+			return nil
+		}},
+		{name: "cursor.bufferStart", title: "Cursor: Go to First Line", key: "gg", run: func(e *Editor) tea.Cmd {
+			return e.ExecuteCommand("1")
+		}},
+		{name: "cursor.bufferEnd", title: "Cursor: Go to Last Line", key: KeyBigG, run: func(e *Editor) tea.Cmd {
+			return e.ExecuteCommand("$")
+		}},
+		{name: "app.quit", title: "Quit", run: func(e *Editor) tea.Cmd {
+			e.SaveState()
+			return tea.Quit
+		}},
+	}
+	actions = append(actions, grammarActions()...)
+}
+
+// grammarActions are the motions, operators and text objects of normal
+// and visual mode. Each types its vim keys, so binding another key to one
+// moves it anywhere in a command, such as after a count or an operator.
+func grammarActions() []action {
+	type entry struct {
+		name, title string
+		key         KeyType
+	}
+	entries := []entry{
+		{"motion.left", "Motion: Left", KeyH},
+		{"motion.down", "Motion: Down", KeyJ},
+		{"motion.up", "Motion: Up", KeyK},
+		{"motion.right", "Motion: Right", KeyL},
+		{"motion.wordForward", "Motion: Next Word", KeyW},
+		{"motion.wordBackward", "Motion: Previous Word", KeyB},
+		{"motion.wordEnd", "Motion: End of Word", "e"},
+		{"motion.lineStart", "Motion: Start of Line", Key0},
+		{"motion.firstNonBlank", "Motion: First Non-Blank", "^"},
+		{"motion.lineEnd", "Motion: End of Line", KeyDollar},
+		{"motion.bufferStart", "Motion: First Line", "gg"},
+		{"motion.bufferEnd", "Motion: Last Line", KeyBigG},
+		{"motion.paragraphBackward", "Motion: Previous Paragraph", "{"},
+		{"motion.paragraphForward", "Motion: Next Paragraph", "}"},
+		{"motion.matchBracket", "Motion: Matching Bracket", "%"},
+		{"motion.functionBackward", "Motion: Previous Function", "[["},
+		{"motion.functionForward", "Motion: Next Function", "]]"},
+		{"motion.findChar", "Motion: Find Character", "f"},
+		{"motion.findCharBackward", "Motion: Find Character Backward", "F"},
+		{"motion.tillChar", "Motion: Till Character", "t"},
+		{"motion.tillCharBackward", "Motion: Till Character Backward", "T"},
+		{"motion.nextMatch", "Motion: Next Match", KeyNextMatch},
+		{"motion.previousMatch", "Motion: Previous Match", KeyPrevMatch},
+		{"motion.wordUnderCursor", "Motion: Next Match of Word", KeyWordForward},
+		{"motion.wordUnderCursorBackward", "Motion: Previous Match of Word", KeyWordBackward},
+		{"operator.delete", "Operator: Delete", KeyDeleteOp},
+		{"operator.change", "Operator: Change", KeyChange},
+		{"operator.yank", "Operator: Yank", KeyY},
+		{"operator.indent", "Operator: Indent", KeyIndent},
+		{"operator.outdent", "Operator: Outdent", KeyOutdent},
+		{"operator.reindent", "Operator: Reindent", KeyReindent},
+		{"operator.lowercase", "Operator: Lowercase", "gu"},
+		{"operator.uppercase", "Operator: Uppercase", "gU"},
+		{"operator.toggleCase", "Operator: Toggle Case", "g~"},
+		{"edit.deleteChar", "Edit: Delete Character", KeyCut},
+		{"edit.deleteToEnd", "Edit: Delete to End of Line", KeyDeleteToEnd},
+		{"edit.changeToEnd", "Edit: Change to End of Line", KeyChangeToEnd},
+		{"edit.yankLine", "Edit: Yank Line", KeyYankLine},
+		{"edit.paste", "Edit: Paste", KeyP},
+	}
+
+	result := make([]action, 0, len(entries)+2)
+	for _, en := range entries {
+		key := en.key
+		result = append(result, action{name: en.name, title: en.title, key: key, grammar: true, run: func(e *Editor) tea.Cmd {
+			return e.typeGrammarKeys(key)
+		}})
+	}
+
+	// i and a only start a text object inside a command; on their own they
+	// would enter insert mode
+	for _, obj := range []struct {
+		name, title string
+		key         KeyType
+	}{
+		{"object.inner", "Text Object: Inner", "i"},
+		{"object.around", "Text Object: Around", "a"},
+	} {
+		key := obj.key
+		result = append(result, action{name: obj.name, title: obj.title, key: key, grammar: true, run: func(e *Editor) tea.Cmd {
+			if len(e.pendingKeys) == 0 && e.mode != viewport.ModeVisual {
+				return nil
+			}
+			return e.typeGrammarKeys(key)
+		}})
+	}
+	return result
+}
+
+// typeGrammarKeys types the vim keys of a grammar action, one rune at a
+// time, in normal or visual mode
+func (e *Editor) typeGrammarKeys(keys KeyType) tea.Cmd {
+	if e.mode != viewport.ModeNormal && e.mode != viewport.ModeVisual {
+		return nil
+	}
+	var cmds []tea.Cmd
+	for _, r := range string(keys) {
+		cmds = append(cmds, e.handleModeKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}}))
+	}
+	return tea.Batch(cmds...)
+}
+
+// findAction returns the action with the given name, or nil
+func findAction(name string) *action {
+	for i := range actions {
+		if actions[i].name == name {
+			return &actions[i]
+		}
+	}
+	return nil
+}
+
+// showPalette opens the command palette with recently used actions first
+func (e *Editor) showPalette() {
+	recent := make(map[string]int, len(e.recentActions))
+	for i, name := range e.recentActions {
+		recent[name] = i + 1
+	}
+
+	items := make([]widgets.PaletteItem, 0, len(actions))
+	for _, a := range actions {
+		if a.grammar {
+			continue
+		}
+		key := e.keymap.KeysFor(a.name)
+		if key == "" {
+			key = string(a.key)
+		}
+		items = append(items, widgets.PaletteItem{
+			ID:     a.name,
+			Title:  a.title,
+			Key:    key,
+			Recent: recent[a.name],
+		})
+	}
+
+	e.paletteReturn = e.mode
+	e.paletteSelect = nil
+	e.paletteWidget.Show(items)
+	e.mode = viewport.ModePalette
+	e.statusMsg = "-- PALETTE --"
+}
+
+// hidePalette closes the palette and returns to the mode it was opened from
+func (e *Editor) hidePalette() {
+	e.paletteWidget.Hide()
+	if e.paletteReturn == viewport.ModeInsert {
+		// The insert session ended when the palette opened
+		e.enterInsertMode()
+	} else {
+		e.mode = e.paletteReturn
+	}
+	e.statusMsg = ""
+}
+
+func (e *Editor) handlePaletteMode(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		e.hidePalette()
+	case "enter":
+		item := e.paletteWidget.Selected()
+		e.hidePalette()
+		switch {
+		case item == nil:
+		case e.paletteSelect != nil:
+			return e.paletteSelect(item.ID)
+		default:
+			return e.runAction(item.ID)
+		}
+	case "up", "ctrl+p", "ctrl+k":
+		e.paletteWidget.MoveUp()
+	case "down", "ctrl+n", "ctrl+j":
+		e.paletteWidget.MoveDown()
+	case "backspace":
+		e.paletteWidget.DeleteRune()
+	default:
+		runes := []rune(msg.String())
+		if len(runes) == 1 {
+			e.paletteWidget.InsertRune(runes[0])
+		}
+	}
+
+	return nil
+}
+
+// runAction runs the named action and records it as recently used
+func (e *Editor) runAction(name string) tea.Cmd {
+	a := findAction(name)
+	if a == nil {
+		e.statusMsg = "Unknown action: " + name
+		return nil
+	}
+
+	recent := []string{name}
+	for _, r := range e.recentActions {
+		if r != name && len(recent) < maxRecentActions {
+			recent = append(recent, r)
+		}
+	}
+	e.recentActions = recent
+
+	return a.run(e)
+}
@@ -3,9 +3,15 @@ package editor
 import (
 	"fmt"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tobibamidele/minra/internal/buffer"
 	"github.com/tobibamidele/minra/internal/session"
+	"github.com/tobibamidele/minra/internal/viewport"
+	"github.com/tobibamidele/minra/internal/widgets"
 	"github.com/tobibamidele/minra/pkg/fileio"
 )
 
@@ -29,12 +35,24 @@ func (e *Editor) SaveFile() tea.Cmd {
 	}
 
 	buf.SetModified(false)
+
+	// The history is only written outside a transaction, so an insert
+	// session is split at the save, carrying on in a new one
+	if e.insertBuf == buf {
+		e.commitInsertSession()
+		defer e.resumeInsertSession(buf)
+	}
+	if err := session.SaveUndoHistory(buf, session.DefaultUndoPath(buf.Filepath())); err != nil {
+		e.statusMsg = fmt.Sprintf("Saved: %s (undo history not saved: %v)", filepath.Base(buf.Filepath()), err)
+		return nil
+	}
 	e.statusMsg = fmt.Sprintf("Saved: %s", filepath.Base(buf.Filepath()))
 	return nil
 }
 
 // OpenFile opens a file
 func (e *Editor) OpenFile(path string) tea.Cmd {
+	e.finishInsertSession()
 	content, err := fileio.ReadFile(path)
 	if err != nil {
 		e.statusMsg = fmt.Sprintf("Error opening: %v", err)
@@ -46,20 +64,39 @@ func (e *Editor) OpenFile(path string) tea.Cmd {
 		e.statusMsg = fmt.Sprintf("Error: %v", err)
 		return nil
 	}
-	// Create tab for buffer
-	e.tabMgr.NewTab(buf.ID(), filepath.Base(path))
+
+	// Restore undo history and closed folds from an earlier session for
+	// freshly opened buffers
+	history := buf.History()
+	if !history.CanUndo() && !history.CanRedo() {
+		session.LoadUndoHistory(buf, session.DefaultUndoPath(path))
+		e.restoreFolds(buf)
+	}
+
+	if abs, err := filepath.Abs(path); err == nil {
+		e.session.AddRecentFile(abs)
+	}
+
+	// Create tab for buffer, or switch to its tab if it is already open
+	if !e.tabMgr.ActivateBuffer(buf.ID()) {
+		e.tabMgr.NewTab(buf.ID(), filepath.Base(path))
+	}
 
 	// Update viewport
 	e.viewport.SetBuffer(buf)
 
-	// Detect language for syntax highlighting
+	// Detect language for syntax highlighting, once per buffer so that
+	// :set filetype sticks
+	if buf.Language() == "" {
+		e.detectLanguage(buf)
+	}
 	e.statusMsg = fmt.Sprintf("Opened: %s", filepath.Base(path))
-	e.highlighter.ForExtension(filepath.Ext(path))
 	return nil
 }
 
 // NewFile creates a new file
 func (e *Editor) NewFile() tea.Cmd {
+	e.finishInsertSession()
 	buf := e.bufferMgr.NewBuffer()
 	e.tabMgr.NewTab(buf.ID(), "untitled")
 	e.viewport.SetBuffer(buf)
@@ -69,6 +106,7 @@ func (e *Editor) NewFile() tea.Cmd {
 
 // CloseFile closes the current file
 func (e *Editor) CloseFile() tea.Cmd {
+	e.finishInsertSession()
 	buf := e.bufferMgr.ActiveBuffer()
 	if buf == nil {
 		return nil
@@ -79,27 +117,40 @@ func (e *Editor) CloseFile() tea.Cmd {
 		return nil
 	}
 
+	e.saveSession()
+	e.closeBuffer(buf)
+
+	e.statusMsg = "File closed"
+	return nil
+}
+
+// closeBuffer closes buf and its tab, showing the buffer left active
+func (e *Editor) closeBuffer(buf *buffer.Buffer) {
 	// Close the tab
-	activeTab := e.tabMgr.ActiveTab()
-	if activeTab != nil {
-		e.tabMgr.CloseTab(activeTab.ID())
+	for _, tab := range e.tabMgr.AllTabs() {
+		if tab.BufferID() == buf.ID() {
+			e.tabMgr.CloseTab(tab.ID())
+			break
+		}
 	}
 
 	// Close the buffer
 	e.bufferMgr.CloseBuffer(buf.ID())
+	delete(e.syntaxTrees, buf.ID())
+	delete(e.highlighters, buf.ID())
+	delete(e.foldCaches, buf.ID())
 
 	// Update viewport to new active buffer
 	newBuf := e.bufferMgr.ActiveBuffer()
 	if newBuf != nil {
+		e.tabMgr.ActivateBuffer(newBuf.ID())
 		e.viewport.SetBuffer(newBuf)
 	}
-
-	e.statusMsg = "File closed"
-	return nil
 }
 
 // NextBuffer swtiches to next buffer
 func (e *Editor) NextBuffer() {
+	e.finishInsertSession()
 	e.bufferMgr.NextBuffer()
 	e.tabMgr.NextTab()
 	buf := e.bufferMgr.ActiveBuffer()
@@ -110,6 +161,7 @@ func (e *Editor) NextBuffer() {
 
 // PreviousBuffer switches to previous buffer
 func (e *Editor) PreviousBuffer() {
+	e.finishInsertSession()
 	e.bufferMgr.PreviousBuffer()
 	e.tabMgr.PreviousTab()
 	buf := e.bufferMgr.ActiveBuffer()
@@ -118,9 +170,114 @@ func (e *Editor) PreviousBuffer() {
 	}
 }
 
-// SaveState saves the current ui state
+// Earlier moves the active buffer back through its undo history. arg is
+// either a step count ("3") or a duration such as "30s", "5m", "1h" or "2d".
+func (e *Editor) Earlier(arg string) tea.Cmd {
+	return e.travel(arg, -1)
+}
+
+// Later moves the active buffer forward through its undo history
+func (e *Editor) Later(arg string) tea.Cmd {
+	return e.travel(arg, 1)
+}
+
+func (e *Editor) travel(arg string, dir int) tea.Cmd {
+	buf := e.bufferMgr.ActiveBuffer()
+	if buf == nil {
+		return nil
+	}
+
+	steps, d, err := parseHistoryOffset(arg)
+	if err != nil {
+		e.statusMsg = err.Error()
+		return nil
+	}
+
+	var moved bool
+	switch {
+	case d != 0 && dir < 0:
+		moved = buf.Earlier(d)
+	case d != 0:
+		moved = buf.Later(d)
+	case dir < 0:
+		moved = buf.EarlierSteps(steps)
+	default:
+		moved = buf.LaterSteps(steps)
+	}
+
+	if !moved {
+		e.statusMsg = "Already at that change"
+		return nil
+	}
+	e.viewport.AdjustScroll(buf.Cursor())
+	e.statusMsg = fmt.Sprintf("Moved to change %d", buf.History().Current())
+	return nil
+}
+
+// parseHistoryOffset parses the argument of Earlier and Later
+func parseHistoryOffset(arg string) (int, time.Duration, error) {
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		return 1, 0, nil
+	}
+	if n, err := strconv.Atoi(arg); err == nil && n > 0 {
+		return n, 0, nil
+	}
+
+	units := map[byte]time.Duration{
+		's': time.Second,
+		'm': time.Minute,
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+	}
+	unit, ok := units[arg[len(arg)-1]]
+	n, err := strconv.Atoi(arg[:len(arg)-1])
+	if !ok || err != nil || n <= 0 {
+		return 0, 0, fmt.Errorf("invalid history offset: %s", arg)
+	}
+	return 0, time.Duration(n) * unit, nil
+}
+
+// showHistory opens the undo history browser for the active buffer
+func (e *Editor) showHistory() {
+	buf := e.bufferMgr.ActiveBuffer()
+	if buf == nil {
+		return
+	}
+
+	history := buf.History()
+	nodes := history.Nodes()
+	entries := make([]widgets.HistoryEntry, 0, len(nodes))
+	for _, node := range nodes {
+		entries = append(entries, widgets.HistoryEntry{
+			Seq:      node.Seq,
+			Time:     node.Time,
+			Current:  node.Seq == history.Current(),
+			OnBranch: history.OnCurrentBranch(node.Seq),
+			Diff:     node.Tx.Diff(),
+		})
+	}
+
+	e.historyWidget.Show(entries)
+	e.mode = viewport.ModeHistory
+	e.statusMsg = "-- HISTORY --"
+}
+
+// SaveState saves the current ui state, the session and the undo history
+// of every saved buffer
 func (e *Editor) SaveState() error {
-	return session.SaveUIState(e.sidebar, session.DefaultUIStatePath())
+	e.finishInsertSession()
+	var undoErr error
+	for _, buf := range e.bufferMgr.AllBuffers() {
+		if err := session.SaveUndoHistory(buf, session.DefaultUndoPath(buf.Filepath())); err != nil && undoErr == nil {
+			undoErr = err
+		}
+	}
+	e.saveSession()
+	if err := session.SaveUIState(e.sidebar, session.DefaultUIStatePath()); err != nil {
+		return err
+	}
+	return undoErr
 }
 
 // LoadState loads the saved ui state
@@ -7,6 +7,11 @@ type Config struct {
 	SyntaxHighlight bool   // Highlight syntax?
 	AutoSave        bool   // Auto save on doc change
 	Theme           string // Theme to use
+	ColorMode       string // auto, truecolor, 256, 16 or mono
+	FoldMethod      string // syntax, indent or bracket
+	UndoLevels      int    // Number of undo steps kept per buffer
+	ShowSidebar     bool   // Show the file tree on startup
+	SidebarWidth    int    // Width of the file tree in columns
 }
 
 // DefaultConfig returns the default editor config
@@ -17,5 +22,10 @@ func DefaultConfig() *Config {
 		SyntaxHighlight: true,
 		AutoSave:        false,
 		Theme:           "default",
+		ColorMode:       "auto",
+		FoldMethod:      "syntax",
+		UndoLevels:      100,
+		ShowSidebar:     true,
+		SidebarWidth:    35,
 	}
 }
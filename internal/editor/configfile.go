@@ -0,0 +1,276 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/tobibamidele/minra/configs"
+	"github.com/tobibamidele/minra/internal/syntax"
+	"github.com/tobibamidele/minra/internal/syntax/fold"
+	"github.com/tobibamidele/minra/internal/ui"
+	"github.com/tobibamidele/minra/internal/viewport"
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfigName is the per-project config file looked for in the root
+// directory
+const ProjectConfigName = ".minra.yaml"
+
+// ConfigError is a problem found while loading a config layer. Line is 0
+// when the source has no lines, as for command line flags.
+type ConfigError struct {
+	Source string
+	Line   int
+	Msg    string
+}
+
+func (e *ConfigError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", e.Source, e.Line, e.Msg)
+	}
+	return fmt.Sprintf("%s: %s", e.Source, e.Msg)
+}
+
+// configField is one setting that can appear in a config file or be set
+// from the command line
+type configField struct {
+	key string
+	set func(c *Config, value string) error
+}
+
+func intField(get func(c *Config) *int, lo, hi int) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("expected a whole number, got %q", value)
+		}
+		if n < lo || n > hi {
+			return fmt.Errorf("must be between %d and %d, got %d", lo, hi, n)
+		}
+		*get(c) = n
+		return nil
+	}
+}
+
+func boolField(get func(c *Config) *bool) func(*Config, string) error {
+	return func(c *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("expected true or false, got %q", value)
+		}
+		*get(c) = b
+		return nil
+	}
+}
+
+var configFields = []configField{
+	{key: "tab_size", set: intField(func(c *Config) *int { return &c.TabSize }, 1, 16)},
+	{key: "line_numbers", set: boolField(func(c *Config) *bool { return &c.LineNumbers })},
+	{key: "syntax_highlight", set: boolField(func(c *Config) *bool { return &c.SyntaxHighlight })},
+	{key: "auto_save", set: boolField(func(c *Config) *bool { return &c.AutoSave })},
+	{key: "theme", set: func(c *Config, value string) error {
+		if _, ok := syntax.ThemeByName(value); !ok {
+			return fmt.Errorf("unknown theme %q (available: %s)", value, strings.Join(syntax.ThemeNames(), ", "))
+		}
+		c.Theme = value
+		return nil
+	}},
+	{key: "color_mode", set: func(c *Config, value string) error {
+		if _, err := ui.ProfileFor(value, os.Getenv); err != nil {
+			return err
+		}
+		c.ColorMode = value
+		return nil
+	}},
+	{key: "fold_method", set: func(c *Config, value string) error {
+		for _, m := range fold.Methods {
+			if m == value {
+				c.FoldMethod = value
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown fold method %q (available: %s)", value, strings.Join(fold.Methods, ", "))
+	}},
+	{key: "undo_levels", set: intField(func(c *Config) *int { return &c.UndoLevels }, 1, 10000)},
+	{key: "show_sidebar", set: boolField(func(c *Config) *bool { return &c.ShowSidebar })},
+	{key: "sidebar_width", set: intField(func(c *Config) *int { return &c.SidebarWidth }, 10, 120)},
+}
+
+func findConfigField(key string) *configField {
+	for i := range configFields {
+		if configFields[i].key == key {
+			return &configFields[i]
+		}
+	}
+	return nil
+}
+
+// ConfigSources are the layers LoadConfig reads, lowest precedence first
+// after the built-in and bundled defaults. Empty paths are skipped, and
+// so are files that do not exist.
+type ConfigSources struct {
+	UserFile    string   // usually DefaultUserConfigPath()
+	ProjectFile string   // usually .minra.yaml in the root directory
+	Overrides   []string // key=value pairs from the command line
+}
+
+// DefaultUserConfigPath returns the user config file,
+// $XDG_CONFIG_HOME/minra/config.yaml or ~/.config/minra/config.yaml
+func DefaultUserConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "minra", "config.yaml")
+}
+
+// LoadConfig builds the config from the built-in defaults, the bundled
+// configs/default.yaml, the user file, the project file and the command
+// line overrides, each layer overriding the ones before it. Invalid
+// settings are reported and skipped; the rest still apply.
+func LoadConfig(sources ConfigSources) (*Config, []error) {
+	config := DefaultConfig()
+	var errs []error
+
+	errs = append(errs, applyConfigYAML(config, "configs/default.yaml", configs.Default)...)
+
+	for _, path := range []string{sources.UserFile, sources.ProjectFile} {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, &ConfigError{Source: path, Msg: err.Error()})
+			continue
+		}
+		errs = append(errs, applyConfigYAML(config, path, data)...)
+	}
+
+	for _, override := range sources.Overrides {
+		key, value, ok := strings.Cut(override, "=")
+		if !ok {
+			errs = append(errs, &ConfigError{Source: "command line", Msg: fmt.Sprintf("expected key=value, got %q", override)})
+			continue
+		}
+		field := findConfigField(strings.TrimSpace(key))
+		if field == nil {
+			errs = append(errs, &ConfigError{Source: "command line", Msg: fmt.Sprintf("unknown option %q", key)})
+			continue
+		}
+		if err := field.set(config, strings.TrimSpace(value)); err != nil {
+			errs = append(errs, &ConfigError{Source: "command line", Msg: key + ": " + err.Error()})
+		}
+	}
+
+	return config, errs
+}
+
+// applyConfigYAML sets the fields found in one YAML document
+func applyConfigYAML(config *Config, source string, data []byte) []error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return []error{yamlError(source, err)}
+	}
+	if len(doc.Content) == 0 {
+		return nil // empty file
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return []error{&ConfigError{Source: source, Line: root.Line, Msg: "expected a mapping of option: value"}}
+	}
+
+	var errs []error
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+
+		field := findConfigField(key.Value)
+		if field == nil {
+			errs = append(errs, &ConfigError{Source: source, Line: key.Line, Msg: fmt.Sprintf("unknown option %q", key.Value)})
+			continue
+		}
+		if value.Kind != yaml.ScalarNode {
+			errs = append(errs, &ConfigError{Source: source, Line: value.Line, Msg: key.Value + ": expected a single value"})
+			continue
+		}
+		if err := field.set(config, value.Value); err != nil {
+			errs = append(errs, &ConfigError{Source: source, Line: value.Line, Msg: key.Value + ": " + err.Error()})
+		}
+	}
+	return errs
+}
+
+// yamlError turns a YAML syntax error into a ConfigError, keeping the line
+// number the parser reports
+func yamlError(source string, err error) error {
+	msg := strings.TrimPrefix(err.Error(), "yaml: ")
+	var line int
+	if n, _ := fmt.Sscanf(msg, "line %d:", &line); n == 1 {
+		msg = strings.TrimSpace(msg[strings.Index(msg, ":")+1:])
+	}
+	return &ConfigError{Source: source, Line: line, Msg: msg}
+}
+
+// applyConfig pushes every config field to the components that use it
+func (e *Editor) applyConfig() {
+	c := e.config
+
+	e.viewport.SetTabSize(c.TabSize)
+	e.viewport.SetLineNumbers(c.LineNumbers)
+	e.bufferMgr.SetTabSize(c.TabSize)
+	e.bufferMgr.SetHistoryLimit(c.UndoLevels)
+
+	if p, err := ui.ProfileFor(c.ColorMode, os.Getenv); err == nil {
+		ui.SetProfile(p)
+	}
+	if theme, ok := syntax.ThemeByName(c.Theme); ok {
+		e.applyTheme(theme)
+	}
+
+	if e.sidebar != nil {
+		e.sidebar.SetWidth(c.SidebarWidth)
+		e.sidebar.SetVisible(c.ShowSidebar)
+	}
+}
+
+// ReportConfigErrors shows problems found while loading the config in the
+// status line
+func (e *Editor) ReportConfigErrors(errs []error) {
+	if len(errs) == 0 {
+		return
+	}
+	e.statusMsg = "Config: " + errs[0].Error()
+	if len(errs) > 1 {
+		e.statusMsg += fmt.Sprintf(" (and %d more)", len(errs)-1)
+	}
+}
+
+// autoSave writes the active buffer when auto_save is on and the editor is
+// back in normal mode with unsaved changes. The status message is kept
+// unless the save fails.
+func (e *Editor) autoSave() {
+	buf := e.bufferMgr.ActiveBuffer()
+	if !e.config.AutoSave || e.mode != viewport.ModeNormal || buf == nil {
+		return
+	}
+	if !buf.Modified() || buf.Filepath() == "" || buf.History().InTransaction() {
+		return
+	}
+
+	msg := e.statusMsg
+	e.SaveFile()
+	if !buf.Modified() {
+		e.statusMsg = msg
+	}
+}
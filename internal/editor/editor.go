@@ -1,19 +1,22 @@
 package editor
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
-	"github.com/muesli/termenv"
+	"github.com/tobibamidele/minra/configs"
 	"github.com/tobibamidele/minra/internal/buffer"
 	"github.com/tobibamidele/minra/internal/clipboard"
 	"github.com/tobibamidele/minra/internal/search"
+	"github.com/tobibamidele/minra/internal/session"
 	"github.com/tobibamidele/minra/internal/sidebar"
 	"github.com/tobibamidele/minra/internal/statusbar"
 	"github.com/tobibamidele/minra/internal/syntax"
+	"github.com/tobibamidele/minra/internal/syntax/tree"
 	"github.com/tobibamidele/minra/internal/tabs"
 	"github.com/tobibamidele/minra/internal/ui"
 	"github.com/tobibamidele/minra/internal/viewport"
@@ -23,21 +26,62 @@ import (
 
 // Editor is the main editor model
 type Editor struct {
-	bufferMgr    *buffer.Manager
-	tabMgr       *tabs.Manager
-	clipboard    clipboard.Clipboard
-	sidebar      *sidebar.Sidebar
-	statusBar    *statusbar.StatusBar
-	viewport     *viewport.Viewport
-	highlighter  *syntax.Highlighter
-	searchEngine *search.Engine
-	renameWidget *widgets.RenameWidget
-	searchWidget *widgets.SearchWidget
-	mode         viewport.Mode
-	width        int
-	height       int
-	statusMsg    string
-	rootDir      string
+	bufferMgr     *buffer.Manager
+	tabMgr        *tabs.Manager
+	clipboard     clipboard.Clipboard
+	register      *clipboard.Register
+	sidebar       *sidebar.Sidebar
+	statusBar     *statusbar.StatusBar
+	viewport      *viewport.Viewport
+	theme         *syntax.Theme
+	languages     *syntax.Registry
+	searchEngine  *search.Engine
+	renameWidget  *widgets.RenameWidget
+	createWidget  *widgets.CreateWidget
+	dialogWidget  *widgets.DialogWidget
+	searchWidget  *widgets.SearchWidget
+	grepWidget    *widgets.GrepWidget
+	patchWidget   *widgets.PatchWidget
+	finderWidget  *widgets.FinderWidget
+	historyWidget *widgets.HistoryWidget
+	commandLine   *widgets.CommandLineWidget
+	paletteWidget *widgets.CommandPaletteWidget
+	commands      *commandRegistry
+	config        *Config
+	keymap        *Keymap
+	mappedKeys    []tea.KeyMsg // keys read so far of a keymap sequence
+	mode          viewport.Mode
+	pendingKeys   []string // keys of an unfinished normal or visual command
+	width         int
+	height        int
+	statusMsg     string
+	rootDir       string
+
+	visualStart    int                            // first line of the last visual selection, -1 if none
+	visualEnd      int                            // last line of the last visual selection
+	lastSubstitute string                         // pattern of the last :s
+	searchFrom     searchOrigin                   // where the search being typed started
+	insertBuf      *buffer.Buffer                 // buffer the insert session's transaction is open on, nil if none
+	searchOptions  *search.Options                // options to go back to after a * or # search, nil if none
+	replacing      replaceState                   // the replace being confirmed
+	grepOptions    search.Options                 // options of the workspace search
+	grepID         int                            // the latest workspace search, to drop results of older ones
+	grepCancel     context.CancelFunc             // stops the running workspace search, nil if none
+	fileReplace    []fileChange                   // the last replace across the workspace, for undoing it
+	finderID       int                            // the latest index of the file finder, to drop batches of older ones
+	finderCancel   context.CancelFunc             // stops the running index, nil if none
+	finderRoot     string                         // absolute path of the workspace the finder lists
+	createDir      string                         // directory a new file is created in
+	movePath       string                         // file being moved, "" when creating one
+	dialogConfirm  func() tea.Cmd                 // runs the action the dialog asks about
+	fileClipboard  fileClipboard                  // path copied or cut in the sidebar
+	recentActions  []string                       // palette actions, most recent first
+	paletteReturn  viewport.Mode                  // mode to go back to when the palette closes
+	paletteSelect  func(id string) tea.Cmd        // picks a palette item, nil for actions
+	syntaxTrees    map[string]*tree.Parser        // by buffer ID
+	highlighters   map[string]*syntax.Highlighter // by buffer ID
+	foldCaches     map[string]foldCache           // by buffer ID
+	session        *session.Session               // state kept between runs, such as closed folds
 }
 
 // New creates a new editor
@@ -49,32 +93,64 @@ func New(rootDir string, config *Config) (*Editor, error) {
 	buf := bufferMgr.NewBuffer()
 	tabMgr.NewTab(buf.ID(), "untitled")
 
+	clip := clipboard.New()
+
 	// Create sidebar
-	sb, err := sidebar.New(rootDir, 35, 24)
+	sb, err := sidebar.New(rootDir, config.SidebarWidth, 24)
 	if err != nil {
 		sb = nil
 	}
 
-	return &Editor{
-		bufferMgr:    bufferMgr,
-		tabMgr:       tabMgr,
-		clipboard:    clipboard.New(),
-		sidebar:      sb,
-		statusBar:    statusbar.New(),
-		viewport:     viewport.New(buf, viewport.ScreenWidth(), viewport.ScreenHeight()),
-		highlighter:  syntax.New(),
-		searchEngine: search.NewEngine(),
-		renameWidget: widgets.NewRenameWidget(),
-		searchWidget: widgets.NewSearchWidget(),
-		mode:         viewport.ModeNormal,
-		statusMsg:    "Press 'i' for insert mode, 'e' for sidebar, Ctrl+S to save",
-		rootDir:      rootDir,
-	}, nil
+	e := &Editor{
+		bufferMgr:     bufferMgr,
+		tabMgr:        tabMgr,
+		clipboard:     clip,
+		register:      clipboard.NewRegister(clip),
+		sidebar:       sb,
+		statusBar:     statusbar.New(),
+		viewport:      viewport.New(buf, viewport.ScreenWidth(), viewport.ScreenHeight()),
+		theme:         syntax.DefaultTheme(),
+		languages:     syntax.NewRegistry(),
+		searchEngine:  search.NewEngine(),
+		renameWidget:  widgets.NewRenameWidget(),
+		createWidget:  widgets.NewCreateWidget(),
+		dialogWidget:  widgets.NewDialogWidget(),
+		searchWidget:  widgets.NewSearchWidget(),
+		grepWidget:    widgets.NewGrepWidget(),
+		patchWidget:   widgets.NewPatchWidget(),
+		finderWidget:  widgets.NewFinderWidget(),
+		historyWidget: widgets.NewHistoryWidget(),
+		commandLine:   widgets.NewCommandLineWidget(),
+		paletteWidget: widgets.NewCommandPaletteWidget(),
+		commands:      newCommandRegistry(),
+		config:        config,
+		keymap:        NewKeymap(),
+		mode:          viewport.ModeNormal,
+		statusMsg:     "Press 'i' for insert mode, 'e' for sidebar, Ctrl+S to save",
+		rootDir:       rootDir,
+		visualStart:   -1,
+		visualEnd:     -1,
+		syntaxTrees:   make(map[string]*tree.Parser),
+		highlighters:  make(map[string]*syntax.Highlighter),
+		foldCaches:    make(map[string]foldCache),
+		session:       session.New(rootDir),
+	}
+	if s, err := session.LoadSession(session.DefaultSessionPath(rootDir)); err == nil {
+		e.session = s
+	}
+	e.registerCommands()
+	if errs := e.keymap.Load("configs/keybindings.yaml", configs.Keybindings); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	if errs := e.languages.LoadFS(configs.Syntax, "syntax"); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	e.applyConfig()
+	return e, nil
 }
 
 // Init initializes the editor
 func (e *Editor) Init() tea.Cmd {
-	lipgloss.SetColorProfile(termenv.TrueColor)
 	return nil
 }
 
@@ -94,11 +170,38 @@ func (e *Editor) Update(msg tea.Msg) (*Editor, tea.Cmd) {
 		viewportWidth := e.getViewportWidth()
 		viewportHeight := e.getViewportHeight()
 		e.viewport.SetSize(viewportWidth, viewportHeight)
+		e.grepWidget.SetSize(e.width-6, e.height-10)
+		e.patchWidget.SetSize(e.width-6, e.height-10)
+		e.finderWidget.SetSize(e.width-6, e.height-10)
 
 		return e, nil
 
 	case tea.KeyMsg:
-		return e, e.HandleKeyPress(msg)
+		cmd := e.HandleKeyPress(msg)
+		e.syncFolds()
+		e.autoSave()
+		return e, cmd
+
+	case grepResultMsg:
+		return e, e.grepResult(msg)
+
+	case grepDoneMsg:
+		e.grepDone(msg)
+		return e, nil
+
+	case patchResultMsg:
+		return e, e.patchResult(msg)
+
+	case patchDoneMsg:
+		e.patchDone(msg)
+		return e, nil
+
+	case finderBatchMsg:
+		return e, e.finderBatch(msg)
+
+	case finderDoneMsg:
+		e.finderDone(msg)
+		return e, nil
 	}
 
 	return e, nil
@@ -119,17 +222,22 @@ func (e *Editor) View() string {
 	buf := e.bufferMgr.ActiveBuffer()
 	var viewportView string
 	if buf != nil {
-		viewportView = e.viewport.Render(e.highlighter, buf.Cursor(), e.mode)
+		var highlighter *syntax.Highlighter
+		if e.config.SyntaxHighlight {
+			highlighter = e.highlighterFor(buf)
+			highlighter.SetTree(e.syntaxTree(buf))
+		}
+		viewportView = e.viewport.Render(highlighter, buf.Cursor(), e.mode)
 	}
 
 	// // Wrap viewport in border
 	borderStyle := lipgloss.NewStyle().
 		Border(lipgloss.NormalBorder()).
-		BorderForeground(lipgloss.Color("240")).
-		BorderBackground(ui.ColorBackground).
+		BorderForeground(ui.Colors.Border).
+		BorderBackground(ui.Colors.Background).
 		Width(e.getViewportWidth() + 5).
 		Height(e.getViewportHeight()).
-		Background(ui.ColorBackground)
+		Background(ui.Colors.Background)
 	viewportView = borderStyle.Render(viewportView)
 
 	// Combine sidebar and viewport
@@ -144,9 +252,30 @@ func (e *Editor) View() string {
 	if e.renameWidget.IsVisible() {
 		mainView = e.overlayWidget(mainView, e.renameWidget.Render(e.sidebar.Width()-5))
 	}
+	if e.createWidget.IsVisible() {
+		mainView = e.overlayWidget(mainView, e.createWidget.Render())
+	}
+	if e.dialogWidget.IsVisible() {
+		mainView = e.overlayWidget(mainView, e.dialogWidget.Render())
+	}
 	if e.searchWidget.IsVisible() {
 		mainView = e.overlayWidget(mainView, e.searchWidget.Render())
 	}
+	if e.grepWidget.IsVisible() {
+		mainView = e.overlayWidget(mainView, e.grepWidget.Render())
+	}
+	if e.patchWidget.IsVisible() {
+		mainView = e.overlayWidget(mainView, e.patchWidget.Render())
+	}
+	if e.finderWidget.IsVisible() {
+		mainView = e.overlayWidget(mainView, e.finderWidget.Render())
+	}
+	if e.historyWidget.IsVisible() {
+		mainView = e.overlayWidget(mainView, e.historyWidget.Render())
+	}
+	if e.paletteWidget.IsVisible() {
+		mainView = e.overlayWidget(mainView, e.paletteWidget.Render())
+	}
 
 	// Render status bar
 	statusBarView := e.renderStatusBar()
@@ -167,7 +296,6 @@ func (e *Editor) getViewportHeight() int {
 	return e.height - 4 // tabs + status bar + borders
 }
 
-
 func (e *Editor) renderStatusBar() string {
 	buf := e.bufferMgr.ActiveBuffer()
 	leftChevron := "\ue0b0"  // Solid chevron (not \ue0b1)
@@ -175,11 +303,11 @@ func (e *Editor) renderStatusBar() string {
 	leftLineChevron := "\ue0b1"
 	rightLineChevron := "\ue0b3"
 
-	bgColor := lipgloss.Color("#252f3b")
-	modeColor := lipgloss.Color("#9c9b9a")
+	bgColor := ui.Colors.StatusBar
+	modeColor := ui.Colors.StatusMode
 
-	baseStyle := lipgloss.NewStyle().Background(bgColor)
-	modeStyle := lipgloss.NewStyle().Background(modeColor)
+	baseStyle := lipgloss.NewStyle().Foreground(ui.Colors.StatusBarForeground).Background(bgColor)
+	modeStyle := ui.Highlight(lipgloss.NewStyle().Background(modeColor))
 
 	// Mode section
 	modeStr := e.mode.String()
@@ -206,8 +334,7 @@ func (e *Editor) renderStatusBar() string {
 	left := modeStyle.Render(" "+lipgloss.NewStyle().Foreground(bgColor).Render(modeStr)) +
 		modeChevronStyle.Render(leftChevron) +
 		baseStyle.Render(gitBranchStr) +
-		modeChevronStyle.Render(leftLineChevron) 
-
+		modeChevronStyle.Render(leftLineChevron)
 
 	osIcon, _ := " "+sidebar.GetOSIcon().Glyph+" ", sidebar.GetOSIcon().Color
 	modified := ""
@@ -221,7 +348,7 @@ func (e *Editor) renderStatusBar() string {
 		}
 		if buf.Filepath() != "" {
 			filename = filepath.Base(buf.Filepath())
-			fileType = " " + strings.Replace(filepath.Ext(filename), ".", "", 1) + " "
+			fileType = " " + e.filetype() + " "
 		}
 		cur := buf.Cursor()
 		line = cur.Line() + 1
@@ -271,12 +398,21 @@ func (e *Editor) renderStatusBar() string {
 	}
 
 	var statusBar strings.Builder
-	statusBar.WriteString(lipgloss.NewStyle().
-		Background(ui.ColorBackground).
-		Width(e.width).
-		Render(left + baseStyle.Render(strings.Repeat(" ", gap)) + right))
+	if completions, _ := e.commandLine.Completions(); len(completions) > 0 {
+		// The completion menu covers the status line while cycling
+		statusBar.WriteString(e.commandLine.RenderCompletions(completionLabels(completions), e.width))
+	} else {
+		statusBar.WriteString(lipgloss.NewStyle().
+			Background(ui.Colors.Background).
+			Width(e.width).
+			Render(left + baseStyle.Render(strings.Repeat(" ", gap)) + right))
+	}
 	statusBar.WriteString("\n")
-	statusBar.WriteString(lipgloss.NewStyle().Background(ui.ColorBackground).Width(e.width).Render(e.statusMsg))
+	message := e.statusMsg
+	if e.commandLine.IsVisible() {
+		message = e.commandLine.Render()
+	}
+	statusBar.WriteString(lipgloss.NewStyle().Background(ui.Colors.Background).Width(e.width).Render(message))
 
 	return statusBar.String()
 }
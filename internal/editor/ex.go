@@ -0,0 +1,428 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tobibamidele/minra/internal/buffer"
+	"github.com/tobibamidele/minra/internal/viewport"
+)
+
+// argKind says how the argument of an ex command is completed
+type argKind int
+
+const (
+	argNone argKind = iota
+	argPath
+	argOption
+	argMapping
+	argTheme
+)
+
+// exArgs is a parsed command line handed to an ex command
+type exArgs struct {
+	start, end int  // line range, 0-based and inclusive
+	ranged     bool // a range was typed, otherwise start and end are the cursor line
+	bang       bool // the name was followed by !
+	arg        string
+}
+
+// exCommand is a command run from the : prompt
+type exCommand struct {
+	name     string
+	aliases  []string
+	usage    string
+	complete argKind
+	run      func(e *Editor, args exArgs) tea.Cmd
+}
+
+// commandRegistry holds the ex commands by name and alias
+type commandRegistry struct {
+	commands []*exCommand
+	byName   map[string]*exCommand
+}
+
+func newCommandRegistry() *commandRegistry {
+	return &commandRegistry{byName: make(map[string]*exCommand)}
+}
+
+// Register adds cmd under its name and aliases
+func (r *commandRegistry) Register(cmd *exCommand) error {
+	for _, name := range append([]string{cmd.name}, cmd.aliases...) {
+		if _, exists := r.byName[name]; exists {
+			return fmt.Errorf("command %q already registered", name)
+		}
+	}
+	for _, name := range append([]string{cmd.name}, cmd.aliases...) {
+		r.byName[name] = cmd
+	}
+	r.commands = append(r.commands, cmd)
+	return nil
+}
+
+// Lookup finds a command by name, alias or unambiguous prefix of a name
+func (r *commandRegistry) Lookup(name string) *exCommand {
+	if cmd, ok := r.byName[name]; ok {
+		return cmd
+	}
+
+	var found *exCommand
+	for _, cmd := range r.commands {
+		if strings.HasPrefix(cmd.name, name) {
+			if found != nil {
+				return nil
+			}
+			found = cmd
+		}
+	}
+	return found
+}
+
+// Names returns the command names starting with prefix, sorted
+func (r *commandRegistry) Names(prefix string) []string {
+	var names []string
+	for _, cmd := range r.commands {
+		if strings.HasPrefix(cmd.name, prefix) {
+			names = append(names, cmd.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// All returns every registered command in registration order
+func (r *commandRegistry) All() []*exCommand {
+	return r.commands
+}
+
+// showCommandLine opens the : prompt
+func (e *Editor) showCommandLine(input string) {
+	e.commandLine.Show(input)
+	e.mode = viewport.ModeCommand
+}
+
+// hideCommandLine closes the : prompt and returns to normal mode
+func (e *Editor) hideCommandLine() {
+	e.commandLine.Hide()
+	e.mode = viewport.ModeNormal
+}
+
+func (e *Editor) handleCommandMode(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		e.hideCommandLine()
+		e.statusMsg = ""
+	case "enter":
+		line := e.commandLine.GetInput()
+		e.commandLine.AddHistory(line)
+		e.hideCommandLine()
+		return e.ExecuteCommand(line)
+	case "backspace":
+		// Deleting past the start leaves the prompt, as in vim
+		if e.commandLine.GetInput() == "" {
+			e.hideCommandLine()
+			return nil
+		}
+		e.commandLine.DeleteRune()
+	case "ctrl+w":
+		e.commandLine.DeleteWord()
+	case "tab":
+		e.commandLine.Complete(e.completeCommandLine, false)
+	case "shift+tab":
+		e.commandLine.Complete(e.completeCommandLine, true)
+	case "up", "ctrl+p":
+		e.commandLine.HistoryPrev()
+	case "down", "ctrl+n":
+		e.commandLine.HistoryNext()
+	case "left":
+		e.commandLine.MoveCursorLeft()
+	case "right":
+		e.commandLine.MoveCursorRight()
+	case "home", "ctrl+a":
+		e.commandLine.MoveCursorToStart()
+	case "end", "ctrl+e":
+		e.commandLine.MoveCursorToEnd()
+	default:
+		runes := []rune(msg.String())
+		if len(runes) == 1 {
+			e.commandLine.InsertRune(runes[0])
+		}
+	}
+
+	return nil
+}
+
+// ExecuteCommand runs an ex command line such as "w", "10,20d" or
+// "%s/foo/bar/g"
+func (e *Editor) ExecuteCommand(line string) tea.Cmd {
+	buf := e.bufferMgr.ActiveBuffer()
+	if buf == nil {
+		return nil
+	}
+
+	name, args, err := e.parseCommandLine(buf, line)
+	if err != nil {
+		e.statusMsg = err.Error()
+		return nil
+	}
+
+	// A bare range jumps to its last line
+	if name == "" {
+		if args.ranged {
+			cur := buf.Cursor()
+			cur.MoveToLine(buf, args.end)
+			e.viewport.AdjustScroll(cur)
+		}
+		return nil
+	}
+
+	cmd := e.commands.Lookup(name)
+	if cmd == nil {
+		e.statusMsg = "Not an editor command: " + strings.TrimSpace(line)
+		return nil
+	}
+	return cmd.run(e, args)
+}
+
+// parseCommandLine splits a command line into its range, name, bang and
+// argument
+func (e *Editor) parseCommandLine(buf *buffer.Buffer, line string) (string, exArgs, error) {
+	s := strings.TrimLeft(line, " :")
+	cur := buf.Cursor().Line()
+	args := exArgs{start: cur, end: cur}
+
+	i := 0
+	if strings.HasPrefix(s, "%") {
+		args.start, args.end, args.ranged = 0, buf.LineCount()-1, true
+		i++
+	} else {
+		first, ok, err := e.parseAddress(buf, s, &i)
+		if err != nil {
+			return "", args, err
+		}
+		if ok {
+			args.start, args.end, args.ranged = first, first, true
+		}
+		if i < len(s) && (s[i] == ',' || s[i] == ';') {
+			i++
+			second, ok, err := e.parseAddress(buf, s, &i)
+			if err != nil {
+				return "", args, err
+			}
+			if !ok {
+				second = cur
+			}
+			if !args.ranged {
+				args.start = cur
+			}
+			args.end, args.ranged = second, true
+		}
+	}
+
+	if args.start > args.end {
+		args.start, args.end = args.end, args.start
+	}
+	if args.start < 0 || args.end >= buf.LineCount() {
+		return "", args, fmt.Errorf("Invalid range")
+	}
+
+	for i < len(s) && s[i] == ' ' {
+		i++
+	}
+	start := i
+	for i < len(s) && isLetter(s[i]) {
+		i++
+	}
+	name := s[start:i]
+	if i < len(s) && s[i] == '!' {
+		args.bang = true
+		i++
+	}
+	args.arg = strings.TrimSpace(s[i:])
+
+	if name == "" && args.arg != "" {
+		return "", args, fmt.Errorf("Not an editor command: %s", strings.TrimSpace(line))
+	}
+	return name, args, nil
+}
+
+// parseAddress reads one line address: a number, ".", "$" or the visual
+// marks '< and '>, each optionally followed by +n or -n offsets. Returns
+// false if there is no address at s[*i].
+func (e *Editor) parseAddress(buf *buffer.Buffer, s string, i *int) (int, bool, error) {
+	line := buf.Cursor().Line()
+	found := false
+
+	switch {
+	case *i < len(s) && isDigit(s[*i]):
+		start := *i
+		for *i < len(s) && isDigit(s[*i]) {
+			*i++
+		}
+		n, _ := strconv.Atoi(s[start:*i])
+		line, found = n-1, true
+	case *i < len(s) && s[*i] == '.':
+		*i++
+		found = true
+	case *i < len(s) && s[*i] == '$':
+		line, found = buf.LineCount()-1, true
+		*i++
+	case strings.HasPrefix(s[*i:], "'<") || strings.HasPrefix(s[*i:], "'>"):
+		if e.visualStart < 0 {
+			return 0, false, fmt.Errorf("Mark not set")
+		}
+		line, found = e.visualStart, true
+		if s[*i+1] == '>' {
+			line = e.visualEnd
+		}
+		*i += 2
+	}
+
+	for *i < len(s) && (s[*i] == '+' || s[*i] == '-') {
+		sign := 1
+		if s[*i] == '-' {
+			sign = -1
+		}
+		*i++
+		start := *i
+		for *i < len(s) && isDigit(s[*i]) {
+			*i++
+		}
+		n := 1
+		if *i > start {
+			n, _ = strconv.Atoi(s[start:*i])
+		}
+		line += sign * n
+		found = true
+	}
+
+	return line, found, nil
+}
+
+// completeCommandLine returns the full command lines completing input:
+// command names, then file paths or option names for the argument
+func (e *Editor) completeCommandLine(input string) []string {
+	// Keep any range in front of the name untouched
+	nameStart := 0
+	for nameStart < len(input) && !isLetter(input[nameStart]) {
+		nameStart++
+	}
+	nameEnd := nameStart
+	for nameEnd < len(input) && isLetter(input[nameEnd]) {
+		nameEnd++
+	}
+	prefix, name := input[:nameStart], input[nameStart:nameEnd]
+
+	if nameEnd == len(input) {
+		var lines []string
+		for _, n := range e.commands.Names(name) {
+			lines = append(lines, prefix+n)
+		}
+		return lines
+	}
+
+	cmd := e.commands.Lookup(name)
+	if cmd == nil {
+		return nil
+	}
+
+	rest := input[nameEnd:]
+	head := input[:nameEnd]
+	if strings.HasPrefix(rest, "!") {
+		head += "!"
+		rest = rest[1:]
+	}
+	if !strings.HasPrefix(rest, " ") {
+		return nil
+	}
+	head += " "
+	arg := strings.TrimLeft(rest, " ")
+
+	var matches []string
+	switch cmd.complete {
+	case argPath:
+		matches = e.completePath(arg)
+	case argOption:
+		matches = completeOption(arg)
+	case argMapping:
+		matches = completeMapArg(arg)
+	case argTheme:
+		matches = completeTheme(arg)
+	}
+
+	lines := make([]string, 0, len(matches))
+	for _, m := range matches {
+		lines = append(lines, head+m)
+	}
+	return lines
+}
+
+// completePath lists the files and directories starting with arg.
+// Relative paths are resolved against the root directory; directories get
+// a trailing slash.
+func (e *Editor) completePath(arg string) []string {
+	dir, base := filepath.Split(arg)
+	entries, err := os.ReadDir(e.resolvePath(dir))
+	if err != nil {
+		return nil
+	}
+
+	var matches []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) {
+			continue
+		}
+		// Hidden files only when asked for
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		if entry.IsDir() {
+			name += "/"
+		}
+		matches = append(matches, dir+name)
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// resolvePath makes a path typed at the prompt absolute. ~ is the home
+// directory; other relative paths start at the root directory.
+func (e *Editor) resolvePath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(e.rootDir, path)
+}
+
+// completionLabels shortens completed command lines to the part that
+// differs between them: the last word, or the last path element
+func completionLabels(lines []string) []string {
+	labels := make([]string, len(lines))
+	for i, line := range lines {
+		label := line[strings.LastIndex(line, " ")+1:]
+		if slash := strings.LastIndex(strings.TrimSuffix(label, "/"), "/"); slash != -1 {
+			label = label[slash+1:]
+		}
+		labels[i] = label
+	}
+	return labels
+}
+
+func isLetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
@@ -0,0 +1,524 @@
+package editor
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tobibamidele/minra/internal/clipboard"
+	"github.com/tobibamidele/minra/pkg/fileio"
+)
+
+// registerCommands adds the built-in ex commands
+func (e *Editor) registerCommands() {
+	builtins := []*exCommand{
+		{name: "write", aliases: []string{"w"}, usage: "write [file]", complete: argPath, run: (*Editor).exWrite},
+		{name: "quit", aliases: []string{"q"}, usage: "quit[!]", run: (*Editor).exQuit},
+		{name: "wq", aliases: []string{"x", "xit"}, usage: "wq[!] [file]", complete: argPath, run: (*Editor).exWriteQuit},
+		{name: "edit", aliases: []string{"e"}, usage: "edit {file}", complete: argPath, run: (*Editor).exEdit},
+		{name: "enew", aliases: []string{"new"}, usage: "enew", run: func(e *Editor, _ exArgs) tea.Cmd {
+			return e.NewFile()
+		}},
+		{name: "bdelete", aliases: []string{"bd", "close"}, usage: "bdelete[!]", run: (*Editor).exClose},
+		{name: "bnext", aliases: []string{"bn"}, usage: "bnext", run: func(e *Editor, _ exArgs) tea.Cmd {
+			e.NextBuffer()
+			return nil
+		}},
+		{name: "bprevious", aliases: []string{"bp", "bN", "bprev"}, usage: "bprevious", run: func(e *Editor, _ exArgs) tea.Cmd {
+			e.PreviousBuffer()
+			return nil
+		}},
+		{name: "set", aliases: []string{"se"}, usage: "set {option}[=value]", complete: argOption, run: (*Editor).exSet},
+		{name: "map", usage: "map [mode] [{keys} {action}]", complete: argMapping, run: (*Editor).exMap},
+		{name: "unmap", usage: "unmap [mode] {keys}", complete: argMapping, run: (*Editor).exUnmap},
+		{name: "nohlsearch", aliases: []string{"noh"}, usage: "nohlsearch", run: func(e *Editor, _ exArgs) tea.Cmd {
+			e.viewport.SetMatcher(nil)
+			return nil
+		}},
+		{name: "grep", usage: "grep [pattern]", run: func(e *Editor, args exArgs) tea.Cmd {
+			return e.showGrep(args.arg)
+		}},
+		{name: "find", aliases: []string{"fin"}, usage: "find [pattern]", run: func(e *Editor, args exArgs) tea.Cmd {
+			return e.showFinder(args.arg)
+		}},
+		{name: "undoreplace", usage: "undoreplace", run: func(e *Editor, _ exArgs) tea.Cmd {
+			e.undoFileReplace()
+			return nil
+		}},
+		{name: "outline", usage: "outline", run: func(e *Editor, _ exArgs) tea.Cmd {
+			e.showOutline()
+			return nil
+		}},
+		{name: "colorscheme", aliases: []string{"colo"}, usage: "colorscheme [name]", complete: argTheme, run: func(e *Editor, args exArgs) tea.Cmd {
+			if args.arg == "" {
+				e.statusMsg = e.theme.Name
+			} else if err := e.setColorscheme(args.arg); err != nil {
+				e.statusMsg = err.Error()
+			}
+			return nil
+		}},
+		{name: "substitute", aliases: []string{"s"}, usage: "[range]s/pattern/replacement/[flags]", run: (*Editor).exSubstitute},
+		{name: "delete", aliases: []string{"d"}, usage: "[range]delete", run: (*Editor).exDelete},
+		{name: "earlier", aliases: []string{"ea"}, usage: "earlier {count|Ns|Nm|Nh|Nd}", run: func(e *Editor, args exArgs) tea.Cmd {
+			return e.Earlier(args.arg)
+		}},
+		{name: "later", aliases: []string{"lat"}, usage: "later {count|Ns|Nm|Nh|Nd}", run: func(e *Editor, args exArgs) tea.Cmd {
+			return e.Later(args.arg)
+		}},
+	}
+
+	for _, cmd := range builtins {
+		if err := e.commands.Register(cmd); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (e *Editor) exWrite(args exArgs) tea.Cmd {
+	e.write(args.arg)
+	return nil
+}
+
+// write saves the active buffer and reports whether it was written. With a
+// file, an unnamed buffer is saved to it and takes its name; a named one
+// is written there as a copy and keeps its own, as with vim's :w.
+func (e *Editor) write(file string) bool {
+	buf := e.bufferMgr.ActiveBuffer()
+	if buf == nil {
+		e.SaveFile()
+		return false
+	}
+
+	if file != "" {
+		path := e.resolvePath(file)
+		if buf.Filepath() != "" && !samePath(buf.Filepath(), path) {
+			if err := fileio.WriteFile(path, buf.Content()); err != nil {
+				e.statusMsg = fmt.Sprintf("Error saving: %v", err)
+				return false
+			}
+			e.statusMsg = fmt.Sprintf("Written: %s", filepath.Base(path))
+			return true
+		}
+		e.setActiveFilepath(path)
+	}
+
+	e.SaveFile()
+	return !buf.Modified()
+}
+
+// samePath reports whether a and b name the same file
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+func (e *Editor) exQuit(args exArgs) tea.Cmd {
+	if !args.bang {
+		for _, buf := range e.bufferMgr.AllBuffers() {
+			if buf.Modified() {
+				e.statusMsg = "No write since last change (add ! to override)"
+				return nil
+			}
+		}
+	}
+	e.SaveState()
+	return tea.Quit
+}
+
+func (e *Editor) exWriteQuit(args exArgs) tea.Cmd {
+	if !e.write(args.arg) {
+		// The write failed and its error is in the status line
+		return nil
+	}
+	return e.exQuit(args)
+}
+
+func (e *Editor) exEdit(args exArgs) tea.Cmd {
+	if args.arg == "" {
+		e.statusMsg = "Argument required"
+		return nil
+	}
+	return e.OpenFile(e.resolvePath(args.arg))
+}
+
+func (e *Editor) exClose(args exArgs) tea.Cmd {
+	if buf := e.bufferMgr.ActiveBuffer(); buf != nil && args.bang {
+		// Discard the changes so CloseFile lets the buffer go
+		buf.SetModified(false)
+	}
+	return e.CloseFile()
+}
+
+func (e *Editor) exDelete(args exArgs) tea.Cmd {
+	buf := e.bufferMgr.ActiveBuffer()
+	e.register.Yank(clipboard.CopyLines(buf, args.start, args.end), clipboard.ModeLine)
+
+	buf.DeleteLines(args.start, args.end)
+	cur := buf.Cursor()
+	cur.MoveToLine(buf, args.start)
+	e.viewport.AdjustScroll(cur)
+	e.statusMsg = fmt.Sprintf("%d fewer lines", args.end-args.start+1)
+	return nil
+}
+
+// setActiveFilepath points the active buffer and its tab at path
+func (e *Editor) setActiveFilepath(path string) {
+	buf := e.bufferMgr.ActiveBuffer()
+	if buf == nil {
+		return
+	}
+	buf.SetFilepath(path)
+	if tab := e.tabMgr.ActiveTab(); tab != nil {
+		tab.SetTitle(filepath.Base(path))
+	}
+	e.detectLanguage(buf)
+}
+
+// option is a setting changed with :set
+type option struct {
+	name    string
+	alias   string
+	boolean bool
+	get     func(e *Editor) int
+	set     func(e *Editor, value int) error
+
+	// String options have these instead of get and set
+	getString func(e *Editor) string
+	setString func(e *Editor, value string) error
+}
+
+func boolValue(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+var options = []option{
+	{
+		name: "tabsize", alias: "ts",
+		get: func(e *Editor) int { return e.viewport.TabSize() },
+		set: func(e *Editor, n int) error {
+			if n < 1 || n > 16 {
+				return fmt.Errorf("tabsize must be between 1 and 16")
+			}
+			e.config.TabSize = n
+			e.viewport.SetTabSize(n)
+			e.bufferMgr.SetTabSize(n)
+			return nil
+		},
+	},
+	{
+		name: "number", alias: "nu", boolean: true,
+		get: func(e *Editor) int { return boolValue(e.viewport.LineNumbers()) },
+		set: func(e *Editor, n int) error {
+			e.config.LineNumbers = n != 0
+			e.viewport.SetLineNumbers(n != 0)
+			return nil
+		},
+	},
+	{
+		name: "syntax", alias: "syn", boolean: true,
+		get: func(e *Editor) int { return boolValue(e.config.SyntaxHighlight) },
+		set: func(e *Editor, n int) error {
+			e.config.SyntaxHighlight = n != 0
+			return nil
+		},
+	},
+	{
+		name: "undolevels", alias: "ul",
+		get: func(e *Editor) int { return e.config.UndoLevels },
+		set: func(e *Editor, n int) error {
+			if n < 1 {
+				return fmt.Errorf("undolevels must be at least 1")
+			}
+			e.config.UndoLevels = n
+			e.bufferMgr.SetHistoryLimit(n)
+			return nil
+		},
+	},
+	{
+		name: "filetype", alias: "ft",
+		getString: (*Editor).filetype,
+		setString: (*Editor).setFiletype,
+	},
+	{
+		name: "foldmethod", alias: "fdm",
+		getString: func(e *Editor) string { return e.config.FoldMethod },
+		setString: (*Editor).setFoldMethod,
+	},
+	{
+		name: "colormode", alias: "cm",
+		getString: (*Editor).colorMode,
+		setString: (*Editor).setColorMode,
+	},
+}
+
+func findOption(name string) *option {
+	for i := range options {
+		if options[i].name == name || options[i].alias == name {
+			return &options[i]
+		}
+	}
+	return nil
+}
+
+// completeOption completes option names, including the no- forms of
+// boolean options
+func completeOption(arg string) []string {
+	var names []string
+	for _, opt := range options {
+		names = append(names, opt.name)
+		if opt.boolean {
+			names = append(names, "no"+opt.name)
+		}
+	}
+	sort.Strings(names)
+
+	var matches []string
+	for _, name := range names {
+		if strings.HasPrefix(name, arg) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
+
+// exSet handles "set", "set ts=2", "set nu", "set nonu", "set nu!" and
+// "set ts?". Several settings can be given at once.
+func (e *Editor) exSet(args exArgs) tea.Cmd {
+	if args.arg == "" {
+		var values []string
+		for _, opt := range options {
+			values = append(values, e.formatOption(&opt))
+		}
+		e.statusMsg = strings.Join(values, "  ")
+		return nil
+	}
+
+	var shown []string
+	for _, setting := range strings.Fields(args.arg) {
+		name, value, hasValue := strings.Cut(setting, "=")
+
+		if strings.HasSuffix(name, "?") {
+			opt := findOption(strings.TrimSuffix(name, "?"))
+			if opt == nil {
+				e.statusMsg = "Unknown option: " + name
+				return nil
+			}
+			shown = append(shown, e.formatOption(opt))
+			continue
+		}
+
+		opt := findOption(name)
+		n := 1
+		switch {
+		case opt != nil && hasValue && opt.setString != nil:
+			if err := opt.setString(e, value); err != nil {
+				e.statusMsg = err.Error()
+				return nil
+			}
+			shown = append(shown, e.formatOption(opt))
+			continue
+		case opt != nil && hasValue:
+			v, err := strconv.Atoi(value)
+			if err != nil || opt.boolean {
+				e.statusMsg = "Invalid argument: " + setting
+				return nil
+			}
+			n = v
+		case opt != nil && !opt.boolean:
+			shown = append(shown, e.formatOption(opt))
+			continue
+		case strings.HasSuffix(name, "!"):
+			opt = findOption(strings.TrimSuffix(name, "!"))
+			if opt != nil && opt.boolean {
+				n = 1 - opt.get(e)
+			}
+		case strings.HasPrefix(name, "no"):
+			opt = findOption(name[2:])
+			n = 0
+		}
+
+		if opt == nil {
+			e.statusMsg = "Unknown option: " + name
+			return nil
+		}
+		// Only boolean options can be toggled with ! or turned off with no
+		if !opt.boolean && !hasValue {
+			e.statusMsg = "Invalid argument: " + setting
+			return nil
+		}
+		if err := opt.set(e, n); err != nil {
+			e.statusMsg = err.Error()
+			return nil
+		}
+		shown = append(shown, e.formatOption(opt))
+	}
+
+	e.statusMsg = strings.Join(shown, "  ")
+	return nil
+}
+
+func (e *Editor) formatOption(opt *option) string {
+	if opt.getString != nil {
+		return fmt.Sprintf("%s=%s", opt.name, opt.getString(e))
+	}
+	if opt.boolean {
+		if opt.get(e) == 0 {
+			return "no" + opt.name
+		}
+		return opt.name
+	}
+	return fmt.Sprintf("%s=%d", opt.name, opt.get(e))
+}
+
+// exSubstitute handles [range]s/pattern/replacement/[flags]. The pattern
+// is a Go regular expression; & and \1..\9 in the replacement insert the
+// match and its groups. Flags: g replaces every match on a line, i and I
+// force case-insensitive and case-sensitive matching.
+func (e *Editor) exSubstitute(args exArgs) tea.Cmd {
+	buf := e.bufferMgr.ActiveBuffer()
+
+	pattern, replacement, flags, err := splitSubstitute(args.arg)
+	if err != nil {
+		e.statusMsg = err.Error()
+		return nil
+	}
+	if pattern == "" {
+		pattern = e.lastSubstitute
+	}
+	if pattern == "" {
+		e.statusMsg = "No previous substitute pattern"
+		return nil
+	}
+	e.lastSubstitute = pattern
+
+	global := strings.Contains(flags, "g")
+	if strings.Contains(flags, "i") {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		e.statusMsg = fmt.Sprintf("Invalid pattern: %v", err)
+		return nil
+	}
+	template := expandTemplate(replacement)
+
+	buf.BeginTransaction()
+	defer buf.CommitTransaction()
+
+	count, lines, last := 0, 0, -1
+	for i := args.start; i <= args.end; i++ {
+		line := buf.Line(i)
+		replaced := 0
+		var out []byte
+		prev := 0
+		for _, m := range re.FindAllStringSubmatchIndex(line, -1) {
+			if !global && replaced == 1 {
+				break
+			}
+			out = append(out, line[prev:m[0]]...)
+			out = re.ExpandString(out, template, line, m)
+			prev = m[1]
+			replaced++
+		}
+		if replaced == 0 {
+			continue
+		}
+		out = append(out, line[prev:]...)
+
+		// Replacements may contain line breaks
+		newLines := strings.Split(string(out), "\n")
+		buf.SetLine(i, newLines[0])
+		buf.InsertLines(i+1, newLines[1:])
+		i += len(newLines) - 1
+		args.end += len(newLines) - 1
+
+		count += replaced
+		lines++
+		last = i
+	}
+
+	if count == 0 {
+		e.statusMsg = "Pattern not found: " + pattern
+		return nil
+	}
+
+	cur := buf.Cursor()
+	cur.MoveToLine(buf, last)
+	e.viewport.AdjustScroll(cur)
+	e.statusMsg = fmt.Sprintf("%d substitutions on %d lines", count, lines)
+	return nil
+}
+
+// splitSubstitute splits "/pattern/replacement/flags". Any punctuation can
+// delimit the parts, and a backslash escapes the delimiter.
+func splitSubstitute(arg string) (string, string, string, error) {
+	if arg == "" {
+		return "", "", "", fmt.Errorf("Usage: s/pattern/replacement/[flags]")
+	}
+	delim := arg[0]
+	if isLetter(delim) || isDigit(delim) || delim == '\\' || delim == ' ' {
+		return "", "", "", fmt.Errorf("Invalid delimiter: %c", delim)
+	}
+
+	var parts []string
+	var part strings.Builder
+	for i := 1; i < len(arg); i++ {
+		switch {
+		case arg[i] == '\\' && i+1 < len(arg) && arg[i+1] == delim:
+			part.WriteByte(delim)
+			i++
+		case arg[i] == delim && len(parts) < 2:
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(arg[i])
+		}
+	}
+	parts = append(parts, part.String())
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// expandTemplate turns a vim replacement string into a regexp.Expand
+// template: & and \0 become the match, \1..\9 the groups, \n a line break
+// and \& a literal &
+func expandTemplate(replacement string) string {
+	var t strings.Builder
+	for i := 0; i < len(replacement); i++ {
+		c := replacement[i]
+		switch {
+		case c == '$':
+			t.WriteString("$$")
+		case c == '&':
+			t.WriteString("${0}")
+		case c == '\\' && i+1 < len(replacement):
+			i++
+			next := replacement[i]
+			switch {
+			case isDigit(next):
+				t.WriteString("${" + string(next) + "}")
+			case next == 'n' || next == 'r':
+				t.WriteByte('\n')
+			case next == 't':
+				t.WriteByte('\t')
+			case next == '$':
+				t.WriteString("$$")
+			default:
+				t.WriteByte(next)
+			}
+		default:
+			t.WriteByte(c)
+		}
+	}
+	return t.String()
+}
@@ -0,0 +1,410 @@
+package editor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tobibamidele/minra/internal/buffer"
+	"github.com/tobibamidele/minra/internal/viewport"
+	"github.com/tobibamidele/minra/pkg/fileio"
+)
+
+// fileClipboard is a path copied or cut in the sidebar, to be pasted in
+// another directory
+type fileClipboard struct {
+	path string // "" if nothing was copied
+	cut  bool   // paste moves the file instead of copying it
+}
+
+// workspaceRoot returns the absolute path of the workspace
+func (e *Editor) workspaceRoot() string {
+	root, err := filepath.Abs(e.rootDir)
+	if err != nil {
+		return e.rootDir
+	}
+	return root
+}
+
+// relPath returns path relative to the workspace, with "/" separators
+func (e *Editor) relPath(path string) string {
+	rel, err := filepath.Rel(e.workspaceRoot(), path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// isUnder reports whether path is dir or inside it
+func isUnder(path, dir string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// sidebarDir returns the directory new files go in: the selected
+// directory, or the one of the selected file
+func (e *Editor) sidebarDir() string {
+	node := e.sidebar.SelectedNode()
+	switch {
+	case node == nil:
+		return e.workspaceRoot()
+	case node.IsDir:
+		return node.Path
+	default:
+		return filepath.Dir(node.Path)
+	}
+}
+
+// showSidebarPath refreshes the sidebar and selects path in it
+func (e *Editor) showSidebarPath(path string) {
+	e.sidebar.Refresh()
+	e.sidebar.SelectPath(path)
+}
+
+// showCreate asks for the name of a new file in the sidebar's directory
+func (e *Editor) showCreate() {
+	e.createDir = e.sidebarDir()
+	e.movePath = ""
+	e.createWidget.Show(e.relPath(e.createDir) + "/")
+	e.mode = viewport.ModeCreate
+	e.statusMsg = "-- NEW FILE --"
+}
+
+// showMove asks where to move the selected file
+func (e *Editor) showMove() {
+	node := e.sidebar.SelectedNode()
+	if node == nil {
+		return
+	}
+	if node.Path == e.workspaceRoot() {
+		e.statusMsg = "Cannot move the workspace"
+		return
+	}
+	e.movePath = node.Path
+	e.createWidget.ShowMove(e.relPath(node.Path))
+	e.mode = viewport.ModeCreate
+	e.statusMsg = "-- MOVE --"
+}
+
+// hideCreate closes the create prompt and goes back to the sidebar
+func (e *Editor) hideCreate() {
+	e.createWidget.Hide()
+	e.movePath = ""
+	e.mode = viewport.ModeSidebar
+}
+
+// createFile creates name in the directory of the prompt and opens it,
+// or creates a directory if name ends in "/"
+func (e *Editor) createFile(name string) tea.Cmd {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil
+	}
+	path := filepath.Join(e.createDir, filepath.FromSlash(name))
+	rel := e.relPath(path)
+	if fileio.FileExists(path) {
+		e.statusMsg = fmt.Sprintf("'%s' already exists", rel)
+		return nil
+	}
+
+	if strings.HasSuffix(name, "/") {
+		if err := fileio.CreateDirectory(path); err != nil {
+			e.statusMsg = fmt.Sprintf("Error creating: %v", err)
+			return nil
+		}
+		e.showSidebarPath(path)
+		e.statusMsg = fmt.Sprintf("Created %s/", rel)
+		return nil
+	}
+
+	if err := fileio.CreateFile(path); err != nil {
+		e.statusMsg = fmt.Sprintf("Error creating: %v", err)
+		return nil
+	}
+	e.showSidebarPath(path)
+	cmd := e.OpenFile(path)
+	e.mode = viewport.ModeNormal
+	e.statusMsg = fmt.Sprintf("Created %s", rel)
+	return cmd
+}
+
+// moveFile moves the file or directory at oldPath to target, a path
+// relative to the workspace. A target ending in "/" is a directory to
+// move it into.
+func (e *Editor) moveFile(oldPath, target string) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return
+	}
+	newPath := filepath.Join(e.workspaceRoot(), filepath.FromSlash(target))
+	if strings.HasSuffix(target, "/") {
+		newPath = filepath.Join(newPath, filepath.Base(oldPath))
+	}
+	if newPath == oldPath {
+		return
+	}
+	if problem := e.moveProblem(oldPath, newPath); problem != "" {
+		e.statusMsg = problem
+		return
+	}
+
+	if err := fileio.CreateDirectory(filepath.Dir(newPath)); err != nil {
+		e.statusMsg = fmt.Sprintf("Error moving: %v", err)
+		return
+	}
+	if err := fileio.RenameFile(oldPath, newPath); err != nil {
+		e.statusMsg = fmt.Sprintf("Error moving: %v", err)
+		return
+	}
+	e.followMove(oldPath, newPath)
+	e.showSidebarPath(newPath)
+	e.statusMsg = fmt.Sprintf("Moved to %s", e.relPath(newPath))
+}
+
+// moveProblem returns why oldPath cannot be moved to newPath, or "" if
+// it can
+func (e *Editor) moveProblem(oldPath, newPath string) string {
+	if fileio.FileExists(newPath) {
+		return fmt.Sprintf("'%s' already exists", e.relPath(newPath))
+	}
+	if isUnder(newPath, oldPath) {
+		return "Cannot move a folder into itself"
+	}
+	return ""
+}
+
+// followMove points the buffers of files at or under oldPath, which was
+// moved to newPath, and their tabs at where they are now
+func (e *Editor) followMove(oldPath, newPath string) {
+	for _, buf := range e.buffersUnder(oldPath) {
+		abs, _ := filepath.Abs(buf.Filepath())
+		path := newPath + strings.TrimPrefix(abs, oldPath)
+		ext := filepath.Ext(buf.Filepath())
+		buf.SetFilepath(path)
+
+		for _, tab := range e.tabMgr.AllTabs() {
+			if tab.BufferID() == buf.ID() {
+				tab.SetTitle(filepath.Base(path))
+			}
+		}
+		// A new extension can mean another language
+		if filepath.Ext(path) != ext {
+			e.detectLanguage(buf)
+		}
+	}
+
+	if isUnder(e.fileClipboard.path, oldPath) {
+		e.fileClipboard.path = newPath + strings.TrimPrefix(e.fileClipboard.path, oldPath)
+	}
+}
+
+// buffersUnder returns the buffers of files at or under path
+func (e *Editor) buffersUnder(path string) []*buffer.Buffer {
+	var bufs []*buffer.Buffer
+	for _, buf := range e.bufferMgr.AllBuffers() {
+		if buf.Filepath() == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(buf.Filepath()); err == nil && isUnder(abs, path) {
+			bufs = append(bufs, buf)
+		}
+	}
+	return bufs
+}
+
+// confirmDelete asks whether to delete the selected file
+func (e *Editor) confirmDelete() {
+	node := e.sidebar.SelectedNode()
+	if node == nil {
+		return
+	}
+	if node.Path == e.workspaceRoot() {
+		e.statusMsg = "Cannot delete the workspace"
+		return
+	}
+	path, isDir := node.Path, node.IsDir
+	for _, buf := range e.buffersUnder(path) {
+		if buf.Modified() {
+			e.statusMsg = fmt.Sprintf("%s has unsaved changes", filepath.Base(buf.Filepath()))
+			return
+		}
+	}
+
+	title, message := "Delete File", fmt.Sprintf("Delete '%s'?", e.relPath(path))
+	if isDir {
+		title = "Delete Folder"
+		message = fmt.Sprintf("Delete '%s' and everything in it?", e.relPath(path))
+	}
+	e.dialogWidget.Show(title, message)
+	e.dialogConfirm = func() tea.Cmd {
+		e.deleteFile(path, isDir)
+		return nil
+	}
+	e.mode = viewport.ModeDialog
+	e.statusMsg = "-- DELETE --"
+}
+
+// hideDialog closes the dialog and goes back to the sidebar
+func (e *Editor) hideDialog() {
+	e.dialogWidget.Hide()
+	e.dialogConfirm = nil
+	e.mode = viewport.ModeSidebar
+}
+
+// deleteFile deletes the file or directory at path, closing the buffers
+// of the files deleted
+func (e *Editor) deleteFile(path string, isDir bool) {
+	var err error
+	if isDir {
+		err = fileio.DeleteDirectory(path)
+	} else {
+		err = fileio.DeleteFile(path)
+	}
+	if err != nil {
+		e.statusMsg = fmt.Sprintf("Error deleting: %v", err)
+		return
+	}
+
+	for _, buf := range e.buffersUnder(path) {
+		e.closeBuffer(buf)
+	}
+	if isUnder(e.fileClipboard.path, path) {
+		e.fileClipboard = fileClipboard{}
+	}
+	e.sidebar.Refresh()
+	e.statusMsg = fmt.Sprintf("Deleted %s", e.relPath(path))
+}
+
+// copyFile copies the selected path, or cuts it, for pasting it
+func (e *Editor) copyFile(cut bool) {
+	node := e.sidebar.SelectedNode()
+	if node == nil {
+		return
+	}
+	if node.Path == e.workspaceRoot() {
+		e.statusMsg = "Cannot copy the workspace"
+		return
+	}
+	e.fileClipboard = fileClipboard{path: node.Path, cut: cut}
+	if cut {
+		e.statusMsg = fmt.Sprintf("Cut %s", e.relPath(node.Path))
+	} else {
+		e.statusMsg = fmt.Sprintf("Copied %s", e.relPath(node.Path))
+	}
+}
+
+// pasteFile copies the path in the clipboard to the sidebar's directory,
+// or moves it there if it was cut
+func (e *Editor) pasteFile() {
+	src := e.fileClipboard.path
+	if src == "" {
+		e.statusMsg = "Nothing to paste"
+		return
+	}
+	if !fileio.FileExists(src) {
+		e.fileClipboard = fileClipboard{}
+		e.statusMsg = fmt.Sprintf("%s no longer exists", e.relPath(src))
+		return
+	}
+	dst := filepath.Join(e.sidebarDir(), filepath.Base(src))
+
+	if e.fileClipboard.cut {
+		if dst == src {
+			return
+		}
+		if problem := e.moveProblem(src, dst); problem != "" {
+			e.statusMsg = problem
+			return
+		}
+		if err := fileio.RenameFile(src, dst); err != nil {
+			e.statusMsg = fmt.Sprintf("Error moving: %v", err)
+			return
+		}
+		e.followMove(src, dst)
+		e.fileClipboard = fileClipboard{}
+		e.showSidebarPath(dst)
+		e.statusMsg = fmt.Sprintf("Moved to %s", e.relPath(dst))
+		return
+	}
+
+	dst = copyName(dst)
+	if err := fileio.Copy(src, dst); err != nil {
+		e.statusMsg = fmt.Sprintf("Error copying: %v", err)
+		return
+	}
+	e.showSidebarPath(dst)
+	e.statusMsg = fmt.Sprintf("Copied to %s", e.relPath(dst))
+}
+
+// copyName returns path, or if it exists the first free name of the form
+// "name copy.ext", "name copy 2.ext", ...
+func copyName(path string) string {
+	if !fileio.FileExists(path) {
+		return path
+	}
+	dir, base := filepath.Split(path)
+	ext := ""
+	if !fileio.IsDirectory(path) {
+		ext = filepath.Ext(base)
+	}
+	stem := strings.TrimSuffix(base, ext)
+
+	for n := 1; ; n++ {
+		name := stem + " copy" + ext
+		if n > 1 {
+			name = fmt.Sprintf("%s copy %d%s", stem, n, ext)
+		}
+		if candidate := filepath.Join(dir, name); !fileio.FileExists(candidate) {
+			return candidate
+		}
+	}
+}
+
+func (e *Editor) handleCreateMode(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		e.hideCreate()
+		e.statusMsg = "Cancelled"
+	case "enter":
+		input, movePath := e.createWidget.GetInput(), e.movePath
+		e.hideCreate()
+		if movePath != "" {
+			e.moveFile(movePath, input)
+			return nil
+		}
+		return e.createFile(input)
+	case "backspace":
+		e.createWidget.DeleteRune()
+	case "left":
+		e.createWidget.MoveCursorLeft()
+	case "right":
+		e.createWidget.MoveCursorRight()
+	case "home":
+		e.createWidget.MoveCursorToStart()
+	case "end":
+		e.createWidget.MoveCursorToEnd()
+	default:
+		runes := []rune(msg.String())
+		if len(runes) == 1 {
+			e.createWidget.InsertRune(runes[0])
+		}
+	}
+
+	return nil
+}
+
+func (e *Editor) handleDialogMode(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "y", "Y":
+		confirm := e.dialogConfirm
+		e.hideDialog()
+		if confirm != nil {
+			return confirm()
+		}
+	case "n", "N", "esc":
+		e.hideDialog()
+		e.statusMsg = "Cancelled"
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package editor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tobibamidele/minra/internal/search"
+	"github.com/tobibamidele/minra/internal/viewport"
+)
+
+// previewBytes is how much of a file the finder reads for its preview
+const previewBytes = 64 << 10
+
+// finderBatchMsg carries files found by the finder's index
+type finderBatchMsg struct {
+	id      int // the index they belong to
+	paths   []string
+	batches <-chan []string
+}
+
+// finderDoneMsg ends the indexing of the workspace
+type finderDoneMsg struct {
+	id int
+}
+
+// waitFinder waits for the next batch of files of index id
+func waitFinder(id int, batches <-chan []string) tea.Cmd {
+	return func() tea.Msg {
+		paths, ok := <-batches
+		if !ok {
+			return finderDoneMsg{id: id}
+		}
+		return finderBatchMsg{id: id, paths: paths, batches: batches}
+	}
+}
+
+// showFinder opens the file finder and indexes the workspace for it in
+// the background, listing files as they are found
+func (e *Editor) showFinder(query string) tea.Cmd {
+	e.finishInsertSession()
+	e.stopFinder()
+	e.finderID++
+
+	root, err := filepath.Abs(e.rootDir)
+	if err != nil {
+		e.statusMsg = fmt.Sprintf("Error: %v", err)
+		return nil
+	}
+	e.finderRoot = root
+
+	// Rank files by how recently they were opened
+	recent := make(map[string]int)
+	for i, path := range e.session.RecentFiles() {
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			recent[filepath.ToSlash(rel)] = i + 1
+		}
+	}
+
+	e.finderWidget.SetSize(e.width-6, e.height-10)
+	e.finderWidget.Show(recent)
+	for _, r := range query {
+		e.finderWidget.InsertRune(r)
+	}
+	e.finderWidget.SetStatus("Indexing…")
+	e.mode = viewport.ModeFinder
+	e.statusMsg = "-- GO TO FILE --"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.finderCancel = cancel
+	return waitFinder(e.finderID, search.NewWorkspace(root).Files(ctx))
+}
+
+// stopFinder cancels the running index, if any
+func (e *Editor) stopFinder() {
+	if e.finderCancel != nil {
+		e.finderCancel()
+		e.finderCancel = nil
+	}
+}
+
+// finderBatch adds indexed files to the finder and waits for the next
+func (e *Editor) finderBatch(msg finderBatchMsg) tea.Cmd {
+	if msg.id != e.finderID {
+		return nil
+	}
+	e.finderWidget.Add(msg.paths)
+	e.updateFinderPreview()
+	return waitFinder(msg.id, msg.batches)
+}
+
+// finderDone reports the end of the index
+func (e *Editor) finderDone(msg finderDoneMsg) {
+	if msg.id != e.finderID || e.finderCancel == nil {
+		return
+	}
+	e.stopFinder()
+	e.finderWidget.SetStatus("")
+}
+
+// hideFinder closes the finder, stopping the index
+func (e *Editor) hideFinder() {
+	e.stopFinder()
+	e.finderWidget.Hide()
+	e.mode = viewport.ModeNormal
+}
+
+// updateFinderPreview shows the selected file in the preview, from its
+// buffer if it is open
+func (e *Editor) updateFinderPreview() {
+	rel := e.finderWidget.Selected()
+	if rel == e.finderWidget.PreviewPath() {
+		return
+	}
+	if rel == "" {
+		e.finderWidget.SetPreview("", nil, "")
+		return
+	}
+
+	path := filepath.Join(e.finderRoot, filepath.FromSlash(rel))
+	if buf := e.bufferForPath(path); buf != nil {
+		e.finderWidget.SetPreview(rel, buf.Lines(), "")
+		return
+	}
+	lines, err := readPreview(path)
+	if err != nil {
+		e.finderWidget.SetPreview(rel, nil, err.Error())
+		return
+	}
+	e.finderWidget.SetPreview(rel, lines, "")
+}
+
+// readPreview reads the first lines of the file at path
+func readPreview(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, previewBytes))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return nil, fmt.Errorf("binary file")
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines, nil
+}
+
+// openFinderFile opens the selected file
+func (e *Editor) openFinderFile() tea.Cmd {
+	rel := e.finderWidget.Selected()
+	if rel == "" {
+		return nil
+	}
+	e.hideFinder()
+	return e.OpenFile(filepath.Join(e.finderRoot, filepath.FromSlash(rel)))
+}
+
+func (e *Editor) handleFinderMode(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		e.hideFinder()
+		e.statusMsg = "-- NORMAL --"
+		return nil
+	case "enter":
+		return e.openFinderFile()
+	case "up", "ctrl+p", "ctrl+k":
+		e.finderWidget.MoveUp()
+	case "down", "ctrl+n", "ctrl+j":
+		e.finderWidget.MoveDown()
+	case "pgup":
+		e.finderWidget.PageUp()
+	case "pgdown":
+		e.finderWidget.PageDown()
+	case "backspace":
+		e.finderWidget.DeleteRune()
+	default:
+		runes := []rune(msg.String())
+		if len(runes) == 1 {
+			e.finderWidget.InsertRune(runes[0])
+		}
+	}
+
+	e.updateFinderPreview()
+	return nil
+}
@@ -0,0 +1,209 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tobibamidele/minra/internal/buffer"
+	"github.com/tobibamidele/minra/internal/cursor"
+	"github.com/tobibamidele/minra/internal/session"
+	"github.com/tobibamidele/minra/internal/syntax/fold"
+	"github.com/tobibamidele/minra/internal/viewport"
+)
+
+// foldCache holds the folds of a buffer and what they were found for
+type foldCache struct {
+	version  int
+	language string
+	method   string
+	ranges   []fold.Range
+}
+
+// foldRanges returns the foldable blocks of buf, found by the fold
+// method. The syntax method falls back to indentation for languages the
+// parser finds no blocks in.
+func (e *Editor) foldRanges(buf *buffer.Buffer) []fold.Range {
+	c, ok := e.foldCaches[buf.ID()]
+	if ok && c.version == buf.Version() && c.language == buf.Language() && c.method == e.config.FoldMethod {
+		return c.ranges
+	}
+
+	var ranges []fold.Range
+	switch e.config.FoldMethod {
+	case "indent":
+		ranges = fold.Indent(buf, buf.TabSize())
+	case "bracket":
+		ranges = fold.Brackets(buf)
+	default:
+		ranges = fold.Syntax(e.syntaxTree(buf))
+		if len(ranges) == 0 {
+			ranges = fold.Indent(buf, buf.TabSize())
+		}
+	}
+
+	e.foldCaches[buf.ID()] = foldCache{
+		version:  buf.Version(),
+		language: buf.Language(),
+		method:   e.config.FoldMethod,
+		ranges:   ranges,
+	}
+	return ranges
+}
+
+// syncFolds hands the folds of the active buffer to the viewport and
+// scrolls to the cursor. A cursor that was moved into a closed fold, by a
+// search or a jump, opens it.
+func (e *Editor) syncFolds() {
+	buf := e.bufferMgr.ActiveBuffer()
+	if buf == nil {
+		return
+	}
+
+	ranges := e.foldRanges(buf)
+	line := buf.Cursor().Line()
+	for _, r := range ranges {
+		if r.Start < line && line <= r.End {
+			buf.SetFoldClosed(r.Start, false)
+		}
+	}
+
+	folds := make([]viewport.Fold, len(ranges))
+	for i, r := range ranges {
+		folds[i] = viewport.Fold{Start: r.Start, End: r.End, Closed: buf.FoldClosed(r.Start)}
+	}
+	e.viewport.SetFolds(folds)
+	e.viewport.AdjustScroll(buf.Cursor())
+}
+
+// closeFold is zc: close the innermost open fold around the cursor
+func (e *Editor) closeFold(buf *buffer.Buffer) {
+	line := buf.Cursor().Line()
+	var target *fold.Range
+	for _, r := range e.foldRanges(buf) {
+		if r.Start <= line && line <= r.End && !buf.FoldClosed(r.Start) {
+			target = &r
+		}
+	}
+	if target == nil {
+		e.statusMsg = "No fold found"
+		return
+	}
+	buf.SetFoldClosed(target.Start, true)
+	e.moveOutOfFolds(buf)
+}
+
+// openFold is zo: open the closed fold the cursor is on
+func (e *Editor) openFold(buf *buffer.Buffer) {
+	line := buf.Cursor().Line()
+	for _, r := range e.foldRanges(buf) {
+		if r.Start <= line && line <= r.End && buf.FoldClosed(r.Start) {
+			buf.SetFoldClosed(r.Start, false)
+			return
+		}
+	}
+	e.statusMsg = "No fold found"
+}
+
+// toggleFold is za: open the fold the cursor is on if it is closed,
+// otherwise close the innermost fold around it
+func (e *Editor) toggleFold(buf *buffer.Buffer) {
+	if buf.FoldClosed(buf.Cursor().Line()) {
+		e.openFold(buf)
+		return
+	}
+	e.closeFold(buf)
+}
+
+// setAllFolds is zR and zM: open or close every fold
+func (e *Editor) setAllFolds(buf *buffer.Buffer, closed bool) {
+	if !closed {
+		for _, line := range buf.ClosedFolds() {
+			buf.SetFoldClosed(line, false)
+		}
+		return
+	}
+	for _, r := range e.foldRanges(buf) {
+		buf.SetFoldClosed(r.Start, true)
+	}
+	e.moveOutOfFolds(buf)
+}
+
+// moveOutOfFolds puts the cursor on the first line of the outermost
+// closed fold hiding it, where it is shown
+func (e *Editor) moveOutOfFolds(buf *buffer.Buffer) {
+	cur := buf.Cursor()
+	for _, r := range e.foldRanges(buf) {
+		if r.Start < cur.Line() && cur.Line() <= r.End && buf.FoldClosed(r.Start) {
+			cur.SetPosition(r.Start, min(cur.Col(), len(buf.Line(r.Start))))
+			return
+		}
+	}
+}
+
+// foldAction runs a fold command on the active buffer from the palette
+func foldAction(fn func(e *Editor, buf *buffer.Buffer)) func(e *Editor) tea.Cmd {
+	return func(e *Editor) tea.Cmd {
+		if buf := e.bufferMgr.ActiveBuffer(); buf != nil {
+			fn(e, buf)
+		}
+		return nil
+	}
+}
+
+// verticalMotion is j and k: count lines down or up, stepping over closed
+// folds as one line
+func verticalMotion(up bool) motion {
+	return motion{kind: linewise, move: func(e *Editor, buf *buffer.Buffer, c *cursor.Cursor, cmd command) bool {
+		line := c.Line()
+		for n := cmd.countOr(1); n > 0; n-- {
+			next := e.viewport.NextVisibleLine(line)
+			if up {
+				next = e.viewport.PrevVisibleLine(line)
+			}
+			if next < 0 || next >= buf.LineCount() {
+				break
+			}
+			line = next
+		}
+		c.SetPosition(line, min(c.Col(), len(buf.Line(line))))
+		return true
+	}}
+}
+
+// setFoldMethod is :set foldmethod
+func (e *Editor) setFoldMethod(method string) error {
+	for _, m := range fold.Methods {
+		if m == method {
+			e.config.FoldMethod = method
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown fold method %q (available: %s)", method, strings.Join(fold.Methods, ", "))
+}
+
+// restoreFolds closes the folds of buf that were closed when the file was
+// last closed
+func (e *Editor) restoreFolds(buf *buffer.Buffer) {
+	for _, line := range e.session.ClosedFolds(buf.Filepath()) {
+		buf.SetFoldClosed(line, true)
+	}
+}
+
+// saveSession records the closed folds of the saved buffers in the
+// session file of the root directory
+func (e *Editor) saveSession() error {
+	for _, buf := range e.bufferMgr.AllBuffers() {
+		if buf.Filepath() == "" || buf.Modified() {
+			continue
+		}
+		var closed []int
+		for _, r := range e.foldRanges(buf) {
+			if buf.FoldClosed(r.Start) {
+				closed = append(closed, r.Start)
+			}
+		}
+		e.session.SetClosedFolds(buf.Filepath(), closed)
+	}
+	return session.SaveSession(e.session, session.DefaultSessionPath(e.rootDir))
+}
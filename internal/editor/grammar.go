@@ -0,0 +1,400 @@
+package editor
+
+import (
+	"strings"
+
+	"github.com/tobibamidele/minra/internal/buffer"
+	"github.com/tobibamidele/minra/internal/cursor"
+	"github.com/tobibamidele/minra/internal/syntax/matchers"
+)
+
+// parseState is the outcome of parsing the keys typed so far
+type parseState int
+
+const (
+	parseIncomplete parseState = iota // wait for more keys
+	parseDone                         // cmd is ready to run
+	parseInvalid                      // discard the keys
+)
+
+// command is a parsed normal or visual mode key sequence:
+// [count] operator [count] (motion | text object | operator again), or
+// [count] motion, or [count] action
+type command struct {
+	count    int    // 0 when no count was typed
+	operator string // d, c, y, >, <, =, gu, gU or g~
+	motion   string // key of an entry in motions
+	char     byte   // argument of f, t, F and T
+	object   rune   // text object kind after i or a
+	around   bool   // a rather than i before the object
+	linewise bool   // the operator was doubled, as in dd
+	action   string // any other key, handled by the mode
+}
+
+// countOr returns the count typed, or def if there was none
+func (c command) countOr(def int) int {
+	if c.count == 0 {
+		return def
+	}
+	return c.count
+}
+
+// operators are the keys that wait for a motion or text object
+var operators = map[string]bool{
+	"d": true, "c": true, "y": true, ">": true, "<": true, "=": true,
+	"gu": true, "gU": true, "g~": true,
+}
+
+// normalActions are motion keys that normal mode binds to something else
+// when no operator is pending. e opens the sidebar, so the word-end motion
+// is only available as de, ce, ye and in visual mode.
+var normalActions = map[string]bool{
+	string(KeySidebarMode): true,
+}
+
+// parseCommand parses keys as a command. In visual mode operators act on
+// the selection straight away, so they are returned as actions, and i/a
+// start a text object that extends the selection.
+func parseCommand(keys []string, visual bool) (command, parseState) {
+	var cmd command
+	i := 0
+
+	readCount := func() int {
+		n := 0
+		for i < len(keys) && isCountKey(keys[i], n) {
+			n = n*10 + int(keys[i][0]-'0')
+			i++
+		}
+		return n
+	}
+
+	cmd.count = readCount()
+	if i == len(keys) {
+		return cmd, parseIncomplete
+	}
+
+	key, ok := readPrefixed(keys, &i)
+	if !ok {
+		return cmd, parseIncomplete
+	}
+
+	if visual && (key == "i" || key == "a") {
+		return parseObject(cmd, keys, i, key == "a")
+	}
+	if visual || !operators[key] {
+		if _, isMotion := motions[key]; isMotion && (visual || !normalActions[key]) {
+			return parseMotion(cmd, key, keys, i)
+		}
+		if len(key) > 1 && isPrefixKey(key[:1]) && !operators[key] && !prefixedActions[key] {
+			// g, [ or ] followed by something that is not a command
+			return cmd, parseInvalid
+		}
+		cmd.action = key
+		return cmd, doneIfLast(keys, i)
+	}
+
+	cmd.operator = key
+	if n := readCount(); n > 0 {
+		cmd.count = cmd.countOr(1) * n
+	}
+	if i == len(keys) {
+		return cmd, parseIncomplete
+	}
+
+	next, ok := readPrefixed(keys, &i)
+	if !ok {
+		return cmd, parseIncomplete
+	}
+
+	switch {
+	case next == key || len(key) == 2 && next == key[1:]:
+		// dd, yy, gUU, gUgU
+		cmd.linewise = true
+		return cmd, doneIfLast(keys, i)
+	case next == "i" || next == "a":
+		return parseObject(cmd, keys, i, next == "a")
+	}
+	if _, isMotion := motions[next]; isMotion {
+		return parseMotion(cmd, next, keys, i)
+	}
+	return cmd, parseInvalid
+}
+
+// awaitsMotion reports whether the unfinished command in keys waits for a
+// count, motion or operator, rather than the character of f or the kind
+// of a text object, or the key after g
+func awaitsMotion(keys []string, visual bool) bool {
+	cmd, state := parseCommand(keys, visual)
+	if state != parseIncomplete || cmd.motion != "" {
+		return false
+	}
+	last := keys[len(keys)-1]
+	if isPrefixKey(last) {
+		return false
+	}
+	return !((cmd.operator != "" || visual) && (last == "i" || last == "a"))
+}
+
+// isPrefixKey reports whether key only starts a command, as g does in gg,
+// [ in [[ and z in za
+func isPrefixKey(key string) bool {
+	return key == "g" || key == "[" || key == "]" || key == "z"
+}
+
+// prefixedActions are the commands of a prefix key that are neither
+// motions nor operators
+var prefixedActions = map[string]bool{
+	string(KeyFoldToggle): true, string(KeyFoldClose): true, string(KeyFoldOpen): true,
+	string(KeyFoldOpenAll): true, string(KeyFoldCloseAll): true,
+}
+
+// readPrefixed reads one key, joining a prefix key with the key after it
+func readPrefixed(keys []string, i *int) (string, bool) {
+	key := keys[*i]
+	*i++
+	if !isPrefixKey(key) {
+		return key, true
+	}
+	if *i == len(keys) {
+		return "", false
+	}
+	key += keys[*i]
+	*i++
+	return key, true
+}
+
+// parseMotion reads the character argument of f, t, F and T
+func parseMotion(cmd command, key string, keys []string, i int) (command, parseState) {
+	cmd.motion = key
+	if !motions[key].takesChar {
+		return cmd, doneIfLast(keys, i)
+	}
+	if i == len(keys) {
+		return cmd, parseIncomplete
+	}
+	if len(keys[i]) != 1 {
+		return cmd, parseInvalid
+	}
+	cmd.char = keys[i][0]
+	return cmd, doneIfLast(keys, i+1)
+}
+
+// parseObject reads the text object kind after i or a
+func parseObject(cmd command, keys []string, i int, around bool) (command, parseState) {
+	if i == len(keys) {
+		return cmd, parseIncomplete
+	}
+	if len(keys[i]) != 1 {
+		return cmd, parseInvalid
+	}
+	cmd.object = rune(keys[i][0])
+	cmd.around = around
+	return cmd, doneIfLast(keys, i+1)
+}
+
+func doneIfLast(keys []string, i int) parseState {
+	if i == len(keys) {
+		return parseDone
+	}
+	return parseInvalid
+}
+
+// isCountKey reports whether key continues a count. 0 only counts after
+// another digit; on its own it is a motion.
+func isCountKey(key string, count int) bool {
+	if len(key) != 1 || key[0] < '0' || key[0] > '9' {
+		return false
+	}
+	return key[0] != '0' || count > 0
+}
+
+// motionKind says how much of the text between the cursor and the end of
+// a motion an operator acts on
+type motionKind int
+
+const (
+	exclusive motionKind = iota // up to the end of the motion
+	inclusive                   // including the character at the end
+	linewise                    // every line touched
+)
+
+// motion moves c for a command. Returns false if the motion failed, as
+// when f finds no match, which cancels any operator.
+type motion struct {
+	kind      motionKind
+	takesChar bool
+	move      func(e *Editor, buf *buffer.Buffer, c *cursor.Cursor, cmd command) bool
+}
+
+// repeat wraps a single step motion so it runs count times
+func repeat(step func(buf *buffer.Buffer, c *cursor.Cursor)) func(*Editor, *buffer.Buffer, *cursor.Cursor, command) bool {
+	return func(_ *Editor, buf *buffer.Buffer, c *cursor.Cursor, cmd command) bool {
+		for n := cmd.countOr(1); n > 0; n-- {
+			step(buf, c)
+		}
+		return true
+	}
+}
+
+var motions map[string]motion
+
+func init() {
+	left := motion{kind: exclusive, move: repeat(func(buf *buffer.Buffer, c *cursor.Cursor) { c.MoveLeft(buf) })}
+	right := motion{kind: exclusive, move: repeat(func(buf *buffer.Buffer, c *cursor.Cursor) { c.MoveRight(buf) })}
+	up := verticalMotion(true)
+	down := verticalMotion(false)
+	lineStart := motion{kind: exclusive, move: func(_ *Editor, _ *buffer.Buffer, c *cursor.Cursor, _ command) bool {
+		c.MoveToLineStart()
+		return true
+	}}
+	lineEnd := motion{kind: inclusive, move: func(_ *Editor, buf *buffer.Buffer, c *cursor.Cursor, cmd command) bool {
+		for n := cmd.countOr(1); n > 1; n-- {
+			c.MoveDown(buf)
+		}
+		c.MoveToLineEnd(buf)
+		return true
+	}}
+	pageUp := motion{kind: linewise, move: func(e *Editor, buf *buffer.Buffer, c *cursor.Cursor, cmd command) bool {
+		c.MovePageUp(buf, e.getViewportHeight()*cmd.countOr(1))
+		return true
+	}}
+	pageDown := motion{kind: linewise, move: func(e *Editor, buf *buffer.Buffer, c *cursor.Cursor, cmd command) bool {
+		c.MovePageDown(buf, e.getViewportHeight()*cmd.countOr(1))
+		return true
+	}}
+	findChar := func(backward, till bool) motion {
+		kind := inclusive
+		if backward {
+			kind = exclusive
+		}
+		return motion{kind: kind, takesChar: true, move: func(_ *Editor, buf *buffer.Buffer, c *cursor.Cursor, cmd command) bool {
+			for n := cmd.countOr(1); n > 0; n-- {
+				if !c.FindChar(buf, cmd.char, backward, till) {
+					return false
+				}
+			}
+			return true
+		}}
+	}
+
+	motions = map[string]motion{
+		string(KeyH): left, string(KeyLeft): left, string(KeyBackspace): left,
+		string(KeyL): right, string(KeyRight): right,
+		string(KeyK): up, string(KeyUp): up,
+		string(KeyJ): down, string(KeyDown): down,
+		string(Key0): lineStart, string(KeyHome): lineStart,
+		string(KeyDollar): lineEnd, string(KeyEnd): lineEnd,
+		string(KeyPageUp): pageUp, string(KeyPageDown): pageDown,
+		"w": {kind: exclusive, move: repeat(func(buf *buffer.Buffer, c *cursor.Cursor) { c.MoveWordForward(buf) })},
+		"b": {kind: exclusive, move: repeat(func(buf *buffer.Buffer, c *cursor.Cursor) { c.MoveWordBackward(buf) })},
+		"e": {kind: inclusive, move: repeat(func(buf *buffer.Buffer, c *cursor.Cursor) { c.MoveWordEnd(buf) })},
+		"^": {kind: exclusive, move: func(_ *Editor, buf *buffer.Buffer, c *cursor.Cursor, _ command) bool {
+			c.MoveToFirstNonBlank(buf)
+			return true
+		}},
+		"gg": {kind: linewise, move: func(_ *Editor, buf *buffer.Buffer, c *cursor.Cursor, cmd command) bool {
+			c.MoveToLine(buf, cmd.countOr(1)-1)
+			return true
+		}},
+		"G": {kind: linewise, move: func(_ *Editor, buf *buffer.Buffer, c *cursor.Cursor, cmd command) bool {
+			c.MoveToLine(buf, cmd.countOr(buf.LineCount())-1)
+			return true
+		}},
+		"{": {kind: exclusive, move: repeat(func(buf *buffer.Buffer, c *cursor.Cursor) { c.MoveParagraphBackward(buf) })},
+		"}": {kind: exclusive, move: repeat(func(buf *buffer.Buffer, c *cursor.Cursor) { c.MoveParagraphForward(buf) })},
+		"%": {kind: inclusive, move: func(_ *Editor, buf *buffer.Buffer, c *cursor.Cursor, _ command) bool {
+			line, col, ok := matchers.FindMatchingBracketAcross(buf, c.Line(), c.Col())
+			if ok {
+				c.SetPosition(line, col)
+			}
+			return ok
+		}},
+		"[[": functionStart(true),
+		"]]": functionStart(false),
+		"f":  findChar(false, false),
+		"t":  findChar(false, true),
+		"F":  findChar(true, false),
+		"T":  findChar(true, true),
+
+		string(KeyNextMatch):    searchMotion(false),
+		string(KeyPrevMatch):    searchMotion(true),
+		string(KeyWordForward):  wordSearchMotion(false),
+		string(KeyWordBackward): wordSearchMotion(true),
+	}
+}
+
+// runMotion moves the cursor by cmd's motion
+func (e *Editor) runMotion(buf *buffer.Buffer, cmd command) {
+	m := motions[cmd.motion]
+	cur := buf.Cursor()
+	// Motions such as n report on their own; the rest keep the message
+	status := e.statusMsg
+	e.statusMsg = ""
+	ok := m.move(e, buf, cur, cmd)
+	switch {
+	case !ok && e.statusMsg == "":
+		e.statusMsg = "Motion failed: " + cmd.motion + string(cmd.char)
+	case ok && e.statusMsg == "":
+		e.statusMsg = status
+	}
+	e.viewport.AdjustScroll(cur)
+}
+
+// motionRange returns the text cmd's motion covers from the cursor without
+// moving it
+func (e *Editor) motionRange(buf *buffer.Buffer, cmd command) (cursor.Range, bool) {
+	cur := buf.Cursor()
+	target := *cur
+	m := motions[cmd.motion]
+
+	// cw changes to the end of the word, like ce, unless on whitespace
+	if cmd.operator == "c" && cmd.motion == "w" {
+		line := buf.Line(cur.Line())
+		if cur.Col() < len(line) && line[cur.Col()] != ' ' && line[cur.Col()] != '\t' {
+			m = motions["e"]
+			// Start one column back so a word ending under the cursor counts
+			target.SetPosition(cur.Line(), cur.Col()-1)
+		}
+	}
+
+	if !m.move(e, buf, &target, cmd) {
+		return cursor.Range{}, false
+	}
+
+	startLine, startCol := cur.Position()
+	endLine, endCol := target.Position()
+	if endLine < startLine || (endLine == startLine && endCol < startCol) {
+		startLine, startCol, endLine, endCol = endLine, endCol, startLine, startCol
+	}
+
+	switch m.kind {
+	case linewise:
+		return cursor.Range{StartLine: startLine, EndLine: endLine, Linewise: true}, true
+	case inclusive:
+		endCol++
+	}
+
+	// A word motion that ends on a later line stops at the end of the line
+	// before it instead of taking the line break
+	if cmd.motion == "w" && endLine > startLine && endCol <= firstNonBlank(buf.Line(endLine)) {
+		endLine--
+		endCol = len(buf.Line(endLine))
+	}
+
+	return cursor.Range{StartLine: startLine, StartCol: startCol, EndLine: endLine, EndCol: endCol}, true
+}
+
+// firstNonBlank returns the column of the first non-whitespace character
+func firstNonBlank(line string) int {
+	for i := 0; i < len(line); i++ {
+		if line[i] != ' ' && line[i] != '\t' {
+			return i
+		}
+	}
+	return len(line)
+}
+
+// showPendingKeys echoes an unfinished command in the status line
+func (e *Editor) showPendingKeys() {
+	e.statusMsg = strings.Join(e.pendingKeys, "")
+}
@@ -0,0 +1,249 @@
+package editor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tobibamidele/minra/internal/search"
+	"github.com/tobibamidele/minra/internal/viewport"
+)
+
+// maxGrepMatches stops a workspace search that has found this many
+// matches; a narrower query is more use than more results
+const maxGrepMatches = 5000
+
+// grepResultMsg carries the matches of one file from a workspace search
+type grepResultMsg struct {
+	id      int // the search they belong to
+	file    search.FileResult
+	results <-chan search.FileResult
+}
+
+// grepDoneMsg ends a workspace search
+type grepDoneMsg struct {
+	id int
+}
+
+// waitGrep waits for the next file of workspace search id
+func waitGrep(id int, results <-chan search.FileResult) tea.Cmd {
+	return func() tea.Msg {
+		file, ok := <-results
+		if !ok {
+			return grepDoneMsg{id: id}
+		}
+		return grepResultMsg{id: id, file: file, results: results}
+	}
+}
+
+// showGrep opens the workspace search panel. A query replaces the last
+// one and starts searching; without one the last results are shown.
+func (e *Editor) showGrep(query string) tea.Cmd {
+	e.finishInsertSession()
+	e.grepWidget.SetSize(e.width-6, e.height-10)
+	e.grepWidget.SetOptions(e.grepOptions)
+	e.grepWidget.Show()
+	e.mode = viewport.ModeGrep
+	e.statusMsg = "-- FIND IN FILES --"
+
+	if query != "" {
+		e.grepWidget.SetInput(query)
+		return e.runGrep()
+	}
+	return nil
+}
+
+// runGrep starts searching the workspace for the panel's query,
+// cancelling the search before it
+func (e *Editor) runGrep() tea.Cmd {
+	e.stopGrep()
+	e.grepID++
+	e.grepWidget.Clear()
+	e.grepWidget.SetError("")
+	e.grepWidget.SetStatus("")
+
+	query := e.grepWidget.GetInput()
+	if query == "" {
+		return nil
+	}
+
+	root, err := filepath.Abs(e.rootDir)
+	if err != nil {
+		e.grepWidget.SetError(err.Error())
+		return nil
+	}
+	ws := search.NewWorkspace(root)
+	ws.Buffers = e.openBufferLines()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results, err := ws.Search(ctx, query, e.grepOptions)
+	if err != nil {
+		cancel()
+		e.grepWidget.SetError(err.Error())
+		return nil
+	}
+	e.grepCancel = cancel
+	e.grepWidget.SetStatus("Searching…")
+	return waitGrep(e.grepID, results)
+}
+
+// stopGrep cancels the running workspace search, if any
+func (e *Editor) stopGrep() {
+	if e.grepCancel != nil {
+		e.grepCancel()
+		e.grepCancel = nil
+	}
+}
+
+// openBufferLines returns the lines of every open file by absolute path,
+// so searches see unsaved changes
+func (e *Editor) openBufferLines() map[string][]string {
+	lines := make(map[string][]string)
+	for _, buf := range e.bufferMgr.AllBuffers() {
+		if buf.Filepath() == "" {
+			continue
+		}
+		if path, err := filepath.Abs(buf.Filepath()); err == nil {
+			lines[path] = buf.Lines()
+		}
+	}
+	return lines
+}
+
+// grepResult adds a file's matches to the panel and waits for the next
+func (e *Editor) grepResult(msg grepResultMsg) tea.Cmd {
+	if msg.id != e.grepID {
+		// From a search that was cancelled
+		return nil
+	}
+
+	e.grepWidget.Add(msg.file)
+	matches, files := e.grepWidget.Counts()
+	if matches >= maxGrepMatches {
+		e.stopGrep()
+		e.grepWidget.SetStatus(fmt.Sprintf("Stopped at %d matches in %d files", matches, files))
+		return nil
+	}
+	e.grepWidget.SetStatus(fmt.Sprintf("Searching… %d matches in %d files", matches, files))
+	return waitGrep(msg.id, msg.results)
+}
+
+// grepDone reports the end of a workspace search
+func (e *Editor) grepDone(msg grepDoneMsg) {
+	if msg.id != e.grepID || e.grepCancel == nil {
+		return
+	}
+	e.stopGrep()
+
+	matches, files := e.grepWidget.Counts()
+	if matches == 0 {
+		e.grepWidget.SetStatus("No results")
+		return
+	}
+	e.grepWidget.SetStatus(fmt.Sprintf("%d matches in %d files", matches, files))
+}
+
+// hideGrep closes the panel. A search still running carries on, so its
+// results are there when the panel is opened again.
+func (e *Editor) hideGrep() {
+	e.grepWidget.Hide()
+	e.mode = viewport.ModeNormal
+}
+
+// openGrepMatch opens the file of the selected match at the match
+func (e *Editor) openGrepMatch() tea.Cmd {
+	file, match := e.grepWidget.Selected()
+	if file == nil {
+		return nil
+	}
+
+	cmd := e.OpenFile(file.Path)
+	buf := e.bufferMgr.ActiveBuffer()
+	if buf == nil {
+		return cmd
+	}
+	if path, _ := filepath.Abs(buf.Filepath()); path != file.Path {
+		// OpenFile said why
+		return cmd
+	}
+	e.hideGrep()
+	e.setCursor(buf, match.Line, match.Column)
+	e.viewport.CenterCursor(buf.Cursor())
+	return cmd
+}
+
+// toggleGrepOption flips the option bound to key and searches again
+func (e *Editor) toggleGrepOption(key KeyType) tea.Cmd {
+	opts := &e.grepOptions
+	switch key {
+	case KeySearchCase:
+		opts.CaseSensitive = !opts.CaseSensitive
+	case KeySearchSmartCase:
+		opts.SmartCase = !opts.SmartCase
+	case KeySearchWord:
+		opts.WholeWord = !opts.WholeWord
+	case KeySearchRegex:
+		opts.Regex = !opts.Regex
+	case KeySearchMultiline:
+		opts.Multiline = !opts.Multiline
+	}
+	e.grepWidget.SetOptions(e.grepOptions)
+	return e.runGrep()
+}
+
+func (e *Editor) handleGrepMode(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		e.hideGrep()
+		e.statusMsg = "-- NORMAL --"
+	case "enter":
+		if e.grepWidget.ReplaceFocused() {
+			return e.previewReplace()
+		}
+		return e.openGrepMatch()
+	case "ctrl+r":
+		if e.grepWidget.IsReplacing() {
+			return e.previewReplace()
+		}
+	case "tab":
+		// Open the replace field, then switch between the fields
+		if !e.grepWidget.IsReplacing() {
+			e.grepWidget.SetReplacing(true)
+			e.grepWidget.FocusReplace(true)
+			e.statusMsg = "-- REPLACE IN FILES --"
+		} else {
+			e.grepWidget.FocusReplace(!e.grepWidget.ReplaceFocused())
+		}
+	case "up", "ctrl+p", "ctrl+k":
+		e.grepWidget.MoveUp()
+	case "down", "ctrl+n", "ctrl+j":
+		e.grepWidget.MoveDown()
+	case "pgup":
+		e.grepWidget.PageUp()
+	case "pgdown":
+		e.grepWidget.PageDown()
+	case "backspace":
+		e.grepWidget.DeleteRune()
+		if !e.grepWidget.ReplaceFocused() {
+			return e.runGrep()
+		}
+	case KeySearchCase.String(), KeySearchSmartCase.String(), KeySearchWord.String(),
+		KeySearchRegex.String(), KeySearchMultiline.String():
+		return e.toggleGrepOption(KeyType(msg.String()))
+	case KeyPreserveCase.String():
+		// Only replacing cares, so there is nothing to search again
+		e.grepOptions.PreserveCase = !e.grepOptions.PreserveCase
+		e.grepWidget.SetOptions(e.grepOptions)
+	default:
+		runes := []rune(msg.String())
+		if len(runes) == 1 {
+			e.grepWidget.InsertRune(runes[0])
+			if !e.grepWidget.ReplaceFocused() {
+				return e.runGrep()
+			}
+		}
+	}
+
+	return nil
+}
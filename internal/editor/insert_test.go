@@ -0,0 +1,41 @@
+package editor
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tobibamidele/minra/internal/viewport"
+)
+
+// press sends keys to e as the terminal reports them
+func press(e *Editor, keys ...tea.KeyMsg) {
+	for _, k := range keys {
+		e.HandleKeyPress(k)
+	}
+}
+
+func runes(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func TestInsertSessionEndsWhenAWidgetOpens(t *testing.T) {
+	e, err := New(t.TempDir(), DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := e.bufferMgr.NewBuffer()
+	e.viewport.SetBuffer(buf)
+
+	press(e, runes("i"), runes("a"), runes("b"), tea.KeyMsg{Type: tea.KeyCtrlP}, tea.KeyMsg{Type: tea.KeyEsc})
+	if e.mode != viewport.ModeNormal {
+		t.Fatalf("mode = %v, want normal", e.mode)
+	}
+	if buf.History().InTransaction() {
+		t.Fatal("insert session still open after leaving insert mode")
+	}
+
+	press(e, runes("u"))
+	if got := buf.Content(); got != "" {
+		t.Errorf("content after undo = %q, want it empty", got)
+	}
+}
@@ -5,13 +5,26 @@ import (
 	"path/filepath"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tobibamidele/minra/internal/buffer"
+	"github.com/tobibamidele/minra/internal/clipboard"
+	"github.com/tobibamidele/minra/internal/cursor"
 	"github.com/tobibamidele/minra/internal/viewport"
 	"github.com/tobibamidele/minra/pkg/fileio"
 )
 
-// HandleKeyPress handles keyboard input
+// HandleKeyPress handles keyboard input. A key that leaves insert mode
+// other than by Esc, such as a global binding opening a widget, still
+// ends the insert session.
 func (e *Editor) HandleKeyPress(msg tea.KeyMsg) tea.Cmd {
-	// Global shortcuts
+	cmd := e.handleKey(msg)
+	if e.mode != viewport.ModeInsert {
+		e.commitInsertSession()
+	}
+	return cmd
+}
+
+func (e *Editor) handleKey(msg tea.KeyMsg) tea.Cmd {
+	// Quitting keys cancel an open prompt instead
 	switch KeyType(msg.String()) {
 	case KeyQuit, KeyInterrupt:
 		switch e.mode {
@@ -20,34 +33,60 @@ func (e *Editor) HandleKeyPress(msg tea.KeyMsg) tea.Cmd {
 			e.mode = viewport.ModeSidebar
 			e.statusMsg = "Cancelled"
 			return nil
+		case viewport.ModeCreate:
+			e.hideCreate()
+			e.statusMsg = "Cancelled"
+			return nil
+		case viewport.ModeDialog:
+			e.hideDialog()
+			e.statusMsg = "Cancelled"
+			return nil
 		case viewport.ModeSearch:
-			e.searchWidget.Hide()
-			e.mode = viewport.ModeSidebar
+			e.cancelSearch()
+			e.statusMsg = "Cancelled"
+			return nil
+		case viewport.ModeReplace:
+			e.endReplace()
+			return nil
+		case viewport.ModeGrep:
+			e.hideGrep()
+			e.statusMsg = "Cancelled"
+			return nil
+		case viewport.ModePreview:
+			e.hidePreview()
+			e.hideGrep()
+			e.statusMsg = "Cancelled"
+			return nil
+		case viewport.ModeFinder:
+			e.hideFinder()
+			e.statusMsg = "Cancelled"
+			return nil
+		case viewport.ModeHistory:
+			e.historyWidget.Hide()
+			e.mode = viewport.ModeNormal
+			e.statusMsg = "Cancelled"
+			return nil
+		case viewport.ModeCommand:
+			e.hideCommandLine()
+			e.statusMsg = "Cancelled"
+			return nil
+		case viewport.ModePalette:
+			e.hidePalette()
 			e.statusMsg = "Cancelled"
 			return nil
-		default:
-			// Attempt to save state
-			e.SaveState()
-			return tea.Quit
 		}
-	case KeySave:
-		return e.SaveFile()
-	case KeyOpen:
-		return e.openSelectedFile()
-	case KeySidebar:
-		e.sidebar.Toggle()
-		return nil
-	case KeyNew:
-		return e.NewFile()
-	case KeyNextBuf, "alt+.":
-		e.NextBuffer()
-		return nil
-	case KeyPrevBuf, "alt+,":
-		e.PreviousBuffer()
-		return nil
 	}
 
-	// viewport.Mode specific handling
+	// Keymap bindings come before the built-in keys of the mode
+	if cmd, ok := e.resolveMapping(msg); ok {
+		return cmd
+	}
+
+	return e.handleModeKey(msg)
+}
+
+// handleModeKey gives a key its built-in meaning in the current mode
+func (e *Editor) handleModeKey(msg tea.KeyMsg) tea.Cmd {
 	switch e.mode {
 	case viewport.ModeSidebar:
 		return e.handleSidebarMode(msg)
@@ -55,10 +94,30 @@ func (e *Editor) HandleKeyPress(msg tea.KeyMsg) tea.Cmd {
 		return e.handleInsertMode(msg)
 	case viewport.ModeNormal:
 		return e.handleNormalMode(msg)
+	case viewport.ModeVisual:
+		return e.handleVisualMode(msg)
 	case viewport.ModeRename:
 		return e.handleRenameMode(msg)
+	case viewport.ModeCreate:
+		return e.handleCreateMode(msg)
+	case viewport.ModeDialog:
+		return e.handleDialogMode(msg)
 	case viewport.ModeSearch:
 		return e.handleSearchMode(msg)
+	case viewport.ModeReplace:
+		return e.handleReplaceMode(msg)
+	case viewport.ModeGrep:
+		return e.handleGrepMode(msg)
+	case viewport.ModePreview:
+		return e.handlePreviewMode(msg)
+	case viewport.ModeFinder:
+		return e.handleFinderMode(msg)
+	case viewport.ModeHistory:
+		return e.handleHistoryMode(msg)
+	case viewport.ModeCommand:
+		return e.handleCommandMode(msg)
+	case viewport.ModePalette:
+		return e.handlePaletteMode(msg)
 	}
 
 	return nil
@@ -70,77 +129,176 @@ func (e *Editor) handleNormalMode(msg tea.KeyMsg) tea.Cmd {
 		return nil
 	}
 
+	cmd, ok := e.readCommand(msg, false)
+	if !ok {
+		return nil
+	}
+
 	cur := buf.Cursor()
 
-	switch KeyType(msg.String()) {
+	switch {
+	case cmd.operator != "":
+		r, ok := e.operatorRange(buf, cmd)
+		if !ok {
+			e.statusMsg = "Nothing to " + cmd.operator
+			return nil
+		}
+		e.applyOperator(buf, cmd.operator, r)
+		return nil
+	case cmd.motion != "":
+		e.runMotion(buf, cmd)
+		return nil
+	}
+
+	count := cmd.countOr(1)
+
+	switch KeyType(cmd.action) {
 	case KeyInsert:
-		e.mode = viewport.ModeInsert
-		e.statusMsg = "-- INSERT --"
+		e.enterInsertMode()
 	case KeySidebarMode:
 		if e.sidebar.IsVisible() {
 			e.mode = viewport.ModeSidebar
 			e.statusMsg = "-- SIDEBAR --"
 		}
-	case KeyH, KeyLeft:
-		cur.MoveLeft(buf)
-		e.viewport.AdjustScroll(cur)
-	case KeyL, KeyRight:
-		cur.MoveRight(buf)
-		e.viewport.AdjustScroll(cur)
-	case KeyK, KeyUp:
-		cur.MoveUp(buf)
-		e.viewport.AdjustScroll(cur)
-	case KeyJ, KeyDown:
-		cur.MoveDown(buf)
-		e.viewport.AdjustScroll(cur)
-	case Key0, KeyHome:
-		cur.MoveToLineStart()
-		e.viewport.AdjustScroll(cur)
-	case KeyDollar, KeyEnd:
-		cur.MoveToLineEnd(buf)
-		e.viewport.AdjustScroll(cur)
-	case KeyBackspace:
-		cur.MoveLeft(buf)
-		e.viewport.AdjustScroll(cur)
-	case KeyG:
-		cur.MoveToBufferStart()
-		e.viewport.AdjustScroll(cur)
-	case KeyBigG:
-		cur.MoveToBufferEnd(buf)
-		e.viewport.AdjustScroll(cur)
-	case KeyW:
-		cur.MoveWordForward(buf)
-		e.viewport.AdjustScroll(cur)
-	case KeyB:
-		cur.MoveWordBackward(buf)
-		e.viewport.AdjustScroll(cur)
-	case KeyPageDown:
-		cur.MovePageDown(buf, e.getViewportHeight())
-		e.viewport.AdjustScroll(cur)
-	case KeyPageUp:
-		cur.MovePageUp(buf, e.getViewportHeight())
-		e.viewport.AdjustScroll(cur)
-	case KeyY:
-		// Copy line
+	case KeyVisual:
+		e.enterVisualMode(clipboard.ModeChar)
+	case KeyVisualLine:
+		e.enterVisualMode(clipboard.ModeLine)
+	case KeyVisualBlock:
+		e.enterVisualMode(clipboard.ModeBlock)
+	case KeyCut:
+		// x deletes count characters without leaving the line
 		line := buf.Line(cur.Line())
-		e.clipboard.Copy(line)
-		e.statusMsg = "Copied line"
+		if end := min(cur.Col()+count, len(line)); end > cur.Col() {
+			e.applyOperator(buf, "d", cursor.Range{StartLine: cur.Line(), StartCol: cur.Col(), EndLine: cur.Line(), EndCol: end})
+		}
+	case KeyDeleteToEnd, KeyChangeToEnd:
+		op := map[KeyType]string{KeyDeleteToEnd: "d", KeyChangeToEnd: "c"}[KeyType(cmd.action)]
+		if r, ok := e.motionRange(buf, command{count: cmd.count, operator: op, motion: string(KeyDollar)}); ok {
+			e.applyOperator(buf, op, r)
+		}
+	case KeyYankLine:
+		if r, ok := e.operatorRange(buf, command{count: cmd.count, operator: "y", linewise: true}); ok {
+			e.applyOperator(buf, "y", r)
+		}
 	case KeyP:
-		// Paste
-		text, _ := e.clipboard.Paste()
+		// Paste the way the text was yanked
+		text, mode := e.register.Get()
 		if text != "" {
-			e.pasteText(text)
+			buf.BeginTransaction()
+			for i := 0; i < count; i++ {
+				switch mode {
+				case clipboard.ModeLine:
+					clipboard.PasteLines(buf, cur, text, false)
+				case clipboard.ModeBlock:
+					clipboard.PasteBlock(buf, cur, text)
+				default:
+					e.pasteText(text)
+				}
+			}
+			buf.CommitTransaction()
+			e.viewport.AdjustScroll(cur)
 			e.statusMsg = "Pasted"
 		}
+	case KeyUndo:
+		e.undo(count)
+	case KeyRedo:
+		e.redo(count)
+	case KeyHistory:
+		e.showHistory()
+	case KeyColon:
+		// A count turns into a range covering that many lines
+		if cmd.count > 1 {
+			e.showCommandLine(fmt.Sprintf(".,.+%d", cmd.count-1))
+		} else {
+			e.showCommandLine("")
+		}
 	case KeySlash:
-		e.mode = viewport.ModeSearch
-		e.searchWidget.Show()
-		e.statusMsg = "-- SEARCH --"
+		e.showSearch()
+	case KeyFoldToggle:
+		e.toggleFold(buf)
+	case KeyFoldClose:
+		e.closeFold(buf)
+	case KeyFoldOpen:
+		e.openFold(buf)
+	case KeyFoldOpenAll:
+		e.setAllFolds(buf, false)
+	case KeyFoldCloseAll:
+		e.setAllFolds(buf, true)
 	}
 
 	return nil
 }
 
+// undo reverts up to count changes in the active buffer
+func (e *Editor) undo(count int) {
+	buf := e.bufferMgr.ActiveBuffer()
+	if buf == nil {
+		return
+	}
+
+	undone := 0
+	for undone < count && buf.Undo() {
+		undone++
+	}
+	if undone > 0 {
+		e.viewport.AdjustScroll(buf.Cursor())
+		e.statusMsg = "Undo"
+	} else {
+		e.statusMsg = "Already at oldest change"
+	}
+}
+
+// redo reapplies up to count undone changes in the active buffer
+func (e *Editor) redo(count int) {
+	buf := e.bufferMgr.ActiveBuffer()
+	if buf == nil {
+		return
+	}
+
+	redone := 0
+	for redone < count && buf.Redo() {
+		redone++
+	}
+	if redone > 0 {
+		e.viewport.AdjustScroll(buf.Cursor())
+		e.statusMsg = "Redo"
+	} else {
+		e.statusMsg = "Already at newest change"
+	}
+}
+
+// readCommand adds the key in msg to the pending keys and returns the
+// command once they parse as one. Escape discards a pending command.
+func (e *Editor) readCommand(msg tea.KeyMsg, visual bool) (command, bool) {
+	key := msg.String()
+	if KeyType(key) == KeyEscape && len(e.pendingKeys) > 0 {
+		e.pendingKeys = nil
+		e.statusMsg = ""
+		return command{}, false
+	}
+
+	e.pendingKeys = append(e.pendingKeys, key)
+	cmd, state := parseCommand(e.pendingKeys, visual)
+
+	switch state {
+	case parseIncomplete:
+		e.showPendingKeys()
+		return cmd, false
+	case parseInvalid:
+		e.pendingKeys = nil
+		e.statusMsg = ""
+		return cmd, false
+	}
+
+	// Clear the echo of a multi-key command before it runs
+	if len(e.pendingKeys) > 1 {
+		e.statusMsg = ""
+	}
+	e.pendingKeys = nil
+	return cmd, true
+}
+
 func (e *Editor) handleInsertMode(msg tea.KeyMsg) tea.Cmd {
 	buf := e.bufferMgr.ActiveBuffer()
 	if buf == nil {
@@ -151,8 +309,7 @@ func (e *Editor) handleInsertMode(msg tea.KeyMsg) tea.Cmd {
 
 	switch KeyType(msg.String()) {
 	case KeyEscape:
-		e.mode = viewport.ModeNormal
-		e.statusMsg = "-- NORMAL --"
+		e.exitInsertMode()
 	case KeyBackspace:
 		buf.DeleteRune(cur.Line(), cur.Col())
 		if cur.Col() > 0 {
@@ -193,13 +350,6 @@ func (e *Editor) handleInsertMode(msg tea.KeyMsg) tea.Cmd {
 		e.viewport.AdjustScroll(cur)
 	case KeyDelete:
 		buf.DeleteLine(cur.Line()) // TODO: Move th cursor to the previous line
-	case KeyPaste:
-		// Paste from clipboard
-		text, _ := e.clipboard.Paste()
-		if text != "" {
-			e.pasteText(text)
-			e.statusMsg = "Pasted"
-		}
 	case "tab":
 		// Insert spaces for tab
 		for i := 0; i < e.viewport.TabSize(); i++ {
@@ -233,6 +383,18 @@ func (e *Editor) handleSidebarMode(msg tea.KeyMsg) tea.Cmd {
 			e.renameWidget.Show(node.Name)
 			e.statusMsg = "-- RENAME --"
 		}
+	case "a":
+		e.showCreate()
+	case "d":
+		e.confirmDelete()
+	case "m":
+		e.showMove()
+	case "y":
+		e.copyFile(false)
+	case "x":
+		e.copyFile(true)
+	case "p":
+		e.pasteFile()
 	case "up", "k":
 		e.sidebar.MoveUp()
 	case "down", "j":
@@ -290,24 +452,68 @@ func (e *Editor) handleRenameMode(msg tea.KeyMsg) tea.Cmd {
 func (e *Editor) handleSearchMode(msg tea.KeyMsg) tea.Cmd {
 	switch msg.String() {
 	case "esc":
-		e.searchWidget.Hide()
-		e.mode = viewport.ModeNormal
+		e.cancelSearch()
 		e.statusMsg = "-- NORMAL --"
 	case "enter":
-		query := e.searchWidget.GetInput()
-		e.performSearch(query)
+		if e.searchWidget.IsReplacing() {
+			e.startReplace()
+		} else {
+			e.finishSearch()
+		}
+	case "tab":
+		// The first tab opens the replace field, later ones switch fields
+		if !e.searchWidget.IsReplacing() {
+			e.searchWidget.SetReplacing(true)
+			e.searchWidget.FocusReplace(true)
+		} else {
+			e.searchWidget.FocusReplace(!e.searchWidget.ReplaceFocused())
+		}
 	case "backspace":
 		e.searchWidget.DeleteRune()
+		if !e.searchWidget.ReplaceFocused() {
+			e.updateSearch()
+		}
+	case KeySearchCase.String(), KeySearchSmartCase.String(), KeySearchWord.String(),
+		KeySearchRegex.String(), KeySearchMultiline.String(), KeyPreserveCase.String():
+		e.toggleSearchOption(KeyType(msg.String()))
 	default:
 		runes := []rune(msg.String())
 		if len(runes) == 1 {
 			e.searchWidget.InsertRune(runes[0])
+			if !e.searchWidget.ReplaceFocused() {
+				e.updateSearch()
+			}
 		}
 	}
 
 	return nil
 }
 
+func (e *Editor) handleHistoryMode(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc", "q":
+		e.historyWidget.Hide()
+		e.mode = viewport.ModeNormal
+		e.statusMsg = "-- NORMAL --"
+	case "up", "k":
+		e.historyWidget.MoveUp()
+	case "down", "j":
+		e.historyWidget.MoveDown()
+	case "enter":
+		entry := e.historyWidget.Selected()
+		buf := e.bufferMgr.ActiveBuffer()
+		if entry != nil && buf != nil {
+			buf.UndoTo(entry.Seq)
+			e.viewport.AdjustScroll(buf.Cursor())
+			e.statusMsg = fmt.Sprintf("Restored change %d", entry.Seq)
+		}
+		e.historyWidget.Hide()
+		e.mode = viewport.ModeNormal
+	}
+
+	return nil
+}
+
 func (e *Editor) openSelectedFile() tea.Cmd {
 	if e.sidebar == nil {
 		return nil
@@ -342,39 +548,65 @@ func (e *Editor) performRename(newName string) {
 		return
 	}
 
-	e.sidebar.Refresh()
+	e.followMove(oldPath, newPath)
+	e.showSidebarPath(newPath)
 	e.statusMsg = fmt.Sprintf("Renamed to %s", newName)
 }
 
-func (e *Editor) performSearch(query string) {
+func (e *Editor) pasteText(text string) {
 	buf := e.bufferMgr.ActiveBuffer()
 	if buf == nil {
 		return
 	}
 
-	e.searchEngine.SetQuery(query)
-	results := e.searchEngine.Search(buf)
+	cur := buf.Cursor()
+	buf.BeginTransaction()
+	buf.InsertText(cur.Line(), cur.Col(), text)
+	buf.CommitTransaction()
+}
 
-	if len(results) > 0 {
-		// Jump to first result
-		cur := buf.Cursor()
-		cur.SetPosition(results[0].Line, results[0].Column)
-		e.viewport.AdjustScroll(cur)
-		e.statusMsg = fmt.Sprintf("Found %d matches", len(results))
-	} else {
-		e.statusMsg = "No matches found"
+// enterInsertMode switches to insert mode and opens an undo transaction so
+// the whole insert session undoes in one step
+func (e *Editor) enterInsertMode() {
+	if buf := e.bufferMgr.ActiveBuffer(); buf != nil && e.insertBuf == nil {
+		buf.BeginTransaction()
+		e.insertBuf = buf
 	}
+	e.mode = viewport.ModeInsert
+	e.statusMsg = "-- INSERT --"
+}
 
-	e.searchWidget.Hide()
+// exitInsertMode closes the insert session's transaction and returns to
+// normal mode
+func (e *Editor) exitInsertMode() {
+	e.commitInsertSession()
 	e.mode = viewport.ModeNormal
+	e.statusMsg = "-- NORMAL --"
 }
 
-func (e *Editor) pasteText(text string) {
-	buf := e.bufferMgr.ActiveBuffer()
-	if buf == nil {
-		return
+// finishInsertSession leaves insert mode before the active buffer changes
+// so the transaction is committed on the buffer it belongs to
+func (e *Editor) finishInsertSession() {
+	if e.mode == viewport.ModeInsert {
+		e.exitInsertMode()
 	}
+	e.commitInsertSession()
+}
 
-	cur := buf.Cursor()
-	buf.InsertText(cur.Line(), cur.Col(), text)
+// resumeInsertSession opens a new insert session transaction on buf, for
+// insert mode going on after its session was committed
+func (e *Editor) resumeInsertSession(buf *buffer.Buffer) {
+	if e.mode == viewport.ModeInsert && e.insertBuf == nil {
+		buf.BeginTransaction()
+		e.insertBuf = buf
+	}
+}
+
+// commitInsertSession commits the insert session's transaction, if one is
+// open, without changing the mode
+func (e *Editor) commitInsertSession() {
+	if e.insertBuf != nil {
+		e.insertBuf.CommitTransaction()
+		e.insertBuf = nil
+	}
 }
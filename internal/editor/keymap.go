@@ -0,0 +1,537 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tobibamidele/minra/internal/viewport"
+	"gopkg.in/yaml.v3"
+)
+
+// Keymap modes. Bindings in the global mode apply in every mode that has
+// a keymap unless the mode binds the same keys itself.
+const (
+	keymapGlobal  = "global"
+	keymapNormal  = "normal"
+	keymapInsert  = "insert"
+	keymapVisual  = "visual"
+	keymapSidebar = "sidebar"
+)
+
+// keymapModes lists the modes that can have bindings, in the order they
+// are shown
+var keymapModes = []string{keymapGlobal, keymapNormal, keymapInsert, keymapVisual, keymapSidebar}
+
+// keymapModeFor returns the keymap mode used for an editor mode, or "" for
+// prompts and widgets, which read keys themselves
+func keymapModeFor(mode viewport.Mode) string {
+	switch mode {
+	case viewport.ModeNormal:
+		return keymapNormal
+	case viewport.ModeInsert:
+		return keymapInsert
+	case viewport.ModeVisual:
+		return keymapVisual
+	case viewport.ModeSidebar:
+		return keymapSidebar
+	}
+	return ""
+}
+
+// Keymap maps key sequences to action names, per mode. A sequence is
+// stored as its key names joined by single spaces, with the space bar
+// written as "space", e.g. "space f f".
+type Keymap struct {
+	bindings map[string]map[string]string
+}
+
+// Binding is one entry of a keymap
+type Binding struct {
+	Mode   string
+	Keys   string
+	Action string
+}
+
+// NewKeymap returns an empty keymap
+func NewKeymap() *Keymap {
+	km := &Keymap{bindings: make(map[string]map[string]string)}
+	for _, mode := range keymapModes {
+		km.bindings[mode] = make(map[string]string)
+	}
+	return km
+}
+
+// keyName is the keymap name of a key as bubbletea reports it
+func keyName(key string) string {
+	if key == " " {
+		return "space"
+	}
+	return key
+}
+
+// parseKeys normalizes a key sequence written in a keymap file or typed
+// at the prompt
+func parseKeys(keys string) (string, error) {
+	fields := strings.Fields(keys)
+	if len(fields) == 0 {
+		return "", errors.New("empty key sequence")
+	}
+	for i, f := range fields {
+		if f == " " {
+			fields[i] = "space"
+		}
+	}
+	return strings.Join(fields, " "), nil
+}
+
+func validKeymapMode(mode string) bool {
+	for _, m := range keymapModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// set binds keys to action without checking for conflicts
+func (km *Keymap) set(mode, keys, action string) error {
+	if !validKeymapMode(mode) {
+		return fmt.Errorf("unknown mode %q (modes: %s)", mode, strings.Join(keymapModes, ", "))
+	}
+	if findAction(action) == nil {
+		return fmt.Errorf("unknown action %q", action)
+	}
+	seq, err := parseKeys(keys)
+	if err != nil {
+		return err
+	}
+	km.bindings[mode][seq] = action
+	return nil
+}
+
+// Bind maps keys to action in mode. The binding is refused if it would
+// make another binding unreachable or be unreachable itself.
+func (km *Keymap) Bind(mode, keys, action string) error {
+	seq, err := parseKeys(keys)
+	if err != nil {
+		return err
+	}
+	old, had := km.bindings[mode][seq]
+	if err := km.set(mode, seq, action); err != nil {
+		return err
+	}
+
+	if conflicts := km.conflictsWith(mode, seq); len(conflicts) > 0 {
+		if had {
+			km.bindings[mode][seq] = old
+		} else {
+			delete(km.bindings[mode], seq)
+		}
+		return errors.New(conflicts[0])
+	}
+	return nil
+}
+
+// Unbind removes the binding of keys in mode
+func (km *Keymap) Unbind(mode, keys string) error {
+	if !validKeymapMode(mode) {
+		return fmt.Errorf("unknown mode %q", mode)
+	}
+	seq, err := parseKeys(keys)
+	if err != nil {
+		return err
+	}
+	if _, ok := km.bindings[mode][seq]; !ok {
+		return fmt.Errorf("no %s mapping for %s", mode, seq)
+	}
+	delete(km.bindings[mode], seq)
+	return nil
+}
+
+// effective returns the bindings that apply in mode: the mode's own and
+// the global ones it does not override
+func (km *Keymap) effective(mode string) map[string]string {
+	result := make(map[string]string, len(km.bindings[mode])+len(km.bindings[keymapGlobal]))
+	for seq, action := range km.bindings[keymapGlobal] {
+		result[seq] = action
+	}
+	for seq, action := range km.bindings[mode] {
+		result[seq] = action
+	}
+	return result
+}
+
+// Lookup matches the keys typed so far in mode. It returns the bound
+// action when they form a whole sequence, and reports whether they are
+// the start of a longer one.
+func (km *Keymap) Lookup(mode string, keys []string) (action string, prefix bool) {
+	seq := strings.Join(keys, " ")
+	bindings := km.effective(mode)
+	if action, ok := bindings[seq]; ok {
+		return action, false
+	}
+	for other := range bindings {
+		if strings.HasPrefix(other, seq+" ") {
+			return "", true
+		}
+	}
+	return "", false
+}
+
+// Conflicts describes every pair of bindings where one sequence starts
+// another, so the longer one can never run
+func (km *Keymap) Conflicts() []string {
+	var conflicts []string
+	seen := make(map[string]bool)
+	for _, mode := range keymapModes {
+		for _, c := range km.conflictsIn(mode, "") {
+			if !seen[c] {
+				seen[c] = true
+				conflicts = append(conflicts, c)
+			}
+		}
+	}
+	return conflicts
+}
+
+// conflictsWith returns the conflicts involving seq once it is bound in
+// mode. A global binding is checked against every mode.
+func (km *Keymap) conflictsWith(mode, seq string) []string {
+	if mode != keymapGlobal {
+		return km.conflictsIn(mode, seq)
+	}
+	var conflicts []string
+	for _, m := range keymapModes {
+		conflicts = append(conflicts, km.conflictsIn(m, seq)...)
+	}
+	return conflicts
+}
+
+// conflictsIn finds sequences in mode that start other sequences. When
+// only is set, just the pairs involving it are reported.
+func (km *Keymap) conflictsIn(mode, only string) []string {
+	bindings := km.effective(mode)
+	seqs := make([]string, 0, len(bindings))
+	for seq := range bindings {
+		seqs = append(seqs, seq)
+	}
+	sort.Strings(seqs)
+
+	var conflicts []string
+	for _, short := range seqs {
+		for _, long := range seqs {
+			if !strings.HasPrefix(long, short+" ") {
+				continue
+			}
+			if only != "" && only != short && only != long {
+				continue
+			}
+			conflicts = append(conflicts, fmt.Sprintf("%s: %q (%s) hides %q (%s)",
+				km.modeOf(mode, short), short, bindings[short], long, bindings[long]))
+		}
+	}
+	return conflicts
+}
+
+// modeOf names the mode a binding effective in mode comes from
+func (km *Keymap) modeOf(mode, seq string) string {
+	if _, ok := km.bindings[mode][seq]; ok {
+		return mode
+	}
+	return keymapGlobal
+}
+
+// Bindings lists the bindings of mode sorted by keys, or of every mode if
+// mode is empty
+func (km *Keymap) Bindings(mode string) []Binding {
+	var result []Binding
+	for _, m := range keymapModes {
+		if mode != "" && m != mode {
+			continue
+		}
+		for seq, action := range km.bindings[m] {
+			result = append(result, Binding{Mode: m, Keys: seq, Action: action})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Mode != result[j].Mode {
+			return result[i].Mode < result[j].Mode
+		}
+		return result[i].Keys < result[j].Keys
+	})
+	return result
+}
+
+// KeysFor returns the shortest sequence bound to action, preferring global
+// and normal mode bindings, or "" if the action has none
+func (km *Keymap) KeysFor(action string) string {
+	for _, mode := range keymapModes {
+		best := ""
+		for seq, a := range km.bindings[mode] {
+			if a == action && (best == "" || len(seq) < len(best) || (len(seq) == len(best) && seq < best)) {
+				best = seq
+			}
+		}
+		if best != "" {
+			return best
+		}
+	}
+	return ""
+}
+
+// Load applies a keymap file on top of the current bindings. The file
+// maps mode names to mappings of key sequence to action name; an empty
+// action removes a binding. Bad entries are reported and skipped.
+func (km *Keymap) Load(source string, data []byte) []error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return []error{yamlError(source, err)}
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return []error{&ConfigError{Source: source, Line: root.Line, Msg: "expected a mapping of mode: bindings"}}
+	}
+
+	var errs []error
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		modeNode, bindings := root.Content[i], root.Content[i+1]
+		mode := modeNode.Value
+		if !validKeymapMode(mode) {
+			errs = append(errs, &ConfigError{Source: source, Line: modeNode.Line, Msg: fmt.Sprintf("unknown mode %q", mode)})
+			continue
+		}
+		if bindings.Kind == yaml.ScalarNode && bindings.Tag == "!!null" {
+			continue
+		}
+		if bindings.Kind != yaml.MappingNode {
+			errs = append(errs, &ConfigError{Source: source, Line: bindings.Line, Msg: mode + ": expected a mapping of keys: action"})
+			continue
+		}
+
+		for j := 0; j+1 < len(bindings.Content); j += 2 {
+			keys, action := bindings.Content[j], bindings.Content[j+1]
+			if action.Kind != yaml.ScalarNode {
+				errs = append(errs, &ConfigError{Source: source, Line: action.Line, Msg: keys.Value + ": expected an action name"})
+				continue
+			}
+			if action.Tag == "!!null" || action.Value == "" {
+				if seq, err := parseKeys(keys.Value); err == nil {
+					delete(km.bindings[mode], seq)
+				}
+				continue
+			}
+			if err := km.set(mode, keys.Value, action.Value); err != nil {
+				errs = append(errs, &ConfigError{Source: source, Line: keys.Line, Msg: err.Error()})
+			}
+		}
+	}
+	return errs
+}
+
+// DefaultUserKeymapPath returns the user keymap file, keybindings.yaml
+// next to the user config file
+func DefaultUserKeymapPath(configPath string) string {
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "keybindings.yaml")
+}
+
+// LoadKeybindings layers the user keymap file over the bundled bindings
+// and reports bad entries and conflicting sequences
+func (e *Editor) LoadKeybindings(path string) []error {
+	var errs []error
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			errs = append(errs, e.keymap.Load(path, data)...)
+		case !errors.Is(err, os.ErrNotExist):
+			errs = append(errs, &ConfigError{Source: path, Msg: err.Error()})
+		}
+	}
+	for _, c := range e.keymap.Conflicts() {
+		errs = append(errs, &ConfigError{Source: "keymap", Msg: c})
+	}
+	return errs
+}
+
+// resolveMapping feeds a key to the keymap of the current mode. It
+// reports whether the key was used: it completed a binding, or it may
+// still. Keys that started a sequence which then failed to match are
+// handled as if no mapping existed.
+func (e *Editor) resolveMapping(msg tea.KeyMsg) (tea.Cmd, bool) {
+	mode := keymapModeFor(e.mode)
+	if mode == "" {
+		return nil, false
+	}
+	// Counts and operators in progress belong to the normal mode grammar
+	if len(e.pendingKeys) > 0 {
+		return e.resolveGrammarMapping(mode, msg)
+	}
+
+	if KeyType(msg.String()) == KeyEscape && len(e.mappedKeys) > 0 {
+		e.mappedKeys = nil
+		e.statusMsg = ""
+		return nil, true
+	}
+
+	e.mappedKeys = append(e.mappedKeys, msg)
+	names := make([]string, len(e.mappedKeys))
+	for i, k := range e.mappedKeys {
+		names[i] = keyName(k.String())
+	}
+
+	action, prefix := e.keymap.Lookup(mode, names)
+	switch {
+	case action != "":
+		if len(e.mappedKeys) > 1 {
+			e.statusMsg = ""
+		}
+		e.mappedKeys = nil
+		if a := findAction(action); a != nil {
+			return a.run(e), true
+		}
+		return nil, true
+	case prefix:
+		e.statusMsg = strings.Join(names, " ")
+		return nil, true
+	}
+
+	// No binding: the first key gets its built-in meaning and the rest are
+	// read again, since they may start a binding of their own
+	keys := e.mappedKeys
+	e.mappedKeys = nil
+	if len(keys) == 1 {
+		return nil, false
+	}
+	e.statusMsg = ""
+	cmds := []tea.Cmd{e.handleModeKey(keys[0])}
+	for _, k := range keys[1:] {
+		cmds = append(cmds, e.HandleKeyPress(k))
+	}
+	return tea.Batch(cmds...), true
+}
+
+// resolveGrammarMapping maps a key typed in the middle of a normal or
+// visual mode command. Only single keys bound to grammar actions apply,
+// and only where the command waits for a count, motion or operator, not
+// for the character of f or the kind of a text object.
+func (e *Editor) resolveGrammarMapping(mode string, msg tea.KeyMsg) (tea.Cmd, bool) {
+	if !awaitsMotion(e.pendingKeys, e.mode == viewport.ModeVisual) {
+		return nil, false
+	}
+	action, _ := e.keymap.Lookup(mode, []string{keyName(msg.String())})
+	a := findAction(action)
+	if a == nil || !a.grammar {
+		return nil, false
+	}
+	return a.run(e), true
+}
+
+// exMap handles "map", "map {mode}", "map [mode] {keys} {action}". The mode
+// defaults to normal; the keys are every word between mode and action.
+func (e *Editor) exMap(args exArgs) tea.Cmd {
+	fields := strings.Fields(args.arg)
+	mode := keymapNormal
+	if len(fields) > 0 && validKeymapMode(fields[0]) {
+		mode = fields[0]
+		fields = fields[1:]
+	} else if len(fields) == 0 {
+		mode = ""
+	}
+
+	switch len(fields) {
+	case 0:
+		e.statusMsg = formatBindings(e.keymap.Bindings(mode))
+		return nil
+	case 1:
+		seq, _ := parseKeys(fields[0])
+		action, _ := e.keymap.Lookup(mode, strings.Fields(seq))
+		if action == "" {
+			e.statusMsg = fmt.Sprintf("No %s mapping for %s", mode, seq)
+		} else {
+			e.statusMsg = fmt.Sprintf("%s %s → %s", mode, seq, action)
+		}
+		return nil
+	}
+
+	keys := strings.Join(fields[:len(fields)-1], " ")
+	action := fields[len(fields)-1]
+	if err := e.keymap.Bind(mode, keys, action); err != nil {
+		e.statusMsg = "map: " + err.Error()
+		return nil
+	}
+	e.statusMsg = fmt.Sprintf("Mapped %s %s → %s", mode, keys, action)
+	return nil
+}
+
+// exUnmap handles "unmap [mode] {keys}"
+func (e *Editor) exUnmap(args exArgs) tea.Cmd {
+	fields := strings.Fields(args.arg)
+	mode := keymapNormal
+	if len(fields) > 1 && validKeymapMode(fields[0]) {
+		mode = fields[0]
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		e.statusMsg = "Usage: unmap [mode] {keys}"
+		return nil
+	}
+
+	keys := strings.Join(fields, " ")
+	if err := e.keymap.Unbind(mode, keys); err != nil {
+		e.statusMsg = "unmap: " + err.Error()
+		return nil
+	}
+	e.statusMsg = fmt.Sprintf("Unmapped %s %s", mode, keys)
+	return nil
+}
+
+// formatBindings lists bindings on one status line
+func formatBindings(bindings []Binding) string {
+	if len(bindings) == 0 {
+		return "No mappings"
+	}
+	parts := make([]string, len(bindings))
+	for i, b := range bindings {
+		parts[i] = fmt.Sprintf("%s %s → %s", b.Mode, b.Keys, b.Action)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// completeMapArg completes the mode or the action name of a map command,
+// whichever word is being typed
+func completeMapArg(arg string) []string {
+	head, word := "", arg
+	if i := strings.LastIndex(arg, " "); i != -1 {
+		head, word = arg[:i+1], arg[i+1:]
+	}
+
+	var names []string
+	if head == "" {
+		names = append(names, keymapModes...)
+	} else {
+		for _, a := range actions {
+			names = append(names, a.name)
+		}
+	}
+	sort.Strings(names)
+
+	var matches []string
+	for _, name := range names {
+		if strings.HasPrefix(name, word) {
+			matches = append(matches, head+name)
+		}
+	}
+	return matches
+}
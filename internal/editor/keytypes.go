@@ -5,16 +5,10 @@ package editor
 type KeyType string
 
 const (
-	// --- Global keys ---
+	// --- Prompt cancelling ---
+	// The remappable global shortcuts live in configs/keybindings.yaml
 	KeyQuit      KeyType = "ctrl+q"
 	KeyInterrupt KeyType = "ctrl+c"
-	KeySave      KeyType = "ctrl+s"
-	KeyOpen      KeyType = "ctrl+o"
-	KeyNew       KeyType = "ctrl+n"
-	KeySidebar   KeyType = "ctrl+b"
-	KeyNextBuf   KeyType = "alt+>"
-	KeyPrevBuf   KeyType = "alt+<"
-	KeyPaste     KeyType = "ctrl+i" // The terminal intercepts ctrl+v for paste so we don't get a proper key event.
 
 	// --- Movement ---
 	KeyUp       KeyType = "up"
@@ -30,6 +24,9 @@ const (
 	KeyInsert      KeyType = "i"
 	KeySidebarMode KeyType = "e"
 	KeyEscape      KeyType = "esc"
+	KeyVisual      KeyType = "v"
+	KeyVisualLine  KeyType = "V"
+	KeyVisualBlock KeyType = "ctrl+v"
 
 	// --- Editing ---
 	KeyEnter     KeyType = "enter"
@@ -47,14 +44,58 @@ const (
 	KeyG    KeyType = "g"
 	KeyBigG KeyType = "G"
 
+	// --- History ---
+	KeyUndo    KeyType = "u"
+	KeyRedo    KeyType = "ctrl+r"
+	KeyHistory KeyType = "U"
+
 	// --- Clipboard ---
 	KeyY KeyType = "y"
 	KeyP KeyType = "p"
 
+	// --- Operators ---
+	KeyDeleteOp KeyType = "d"
+	KeyCut      KeyType = "x"
+	KeyChange   KeyType = "c"
+	KeyIndent   KeyType = ">"
+	KeyOutdent  KeyType = "<"
+	KeyToggle   KeyType = "~"
+	KeyLower    KeyType = "u"
+	KeyUpper    KeyType = "U"
+	KeySwapEnds KeyType = "o"
+	KeyReindent KeyType = "="
+
+	// --- Operator shorthands ---
+	KeyDeleteToEnd KeyType = "D" // d$
+	KeyChangeToEnd KeyType = "C" // c$
+	KeyYankLine    KeyType = "Y" // yy
+
 	// --- Search / Rename ---
 	KeySlash KeyType = "/"
+	KeyColon KeyType = ":"
 	KeyR     KeyType = "r"
 
+	// --- Folds ---
+	KeyFoldToggle   KeyType = "za"
+	KeyFoldClose    KeyType = "zc"
+	KeyFoldOpen     KeyType = "zo"
+	KeyFoldOpenAll  KeyType = "zR"
+	KeyFoldCloseAll KeyType = "zM"
+
+	// --- Search ---
+	KeyNextMatch    KeyType = "n"
+	KeyPrevMatch    KeyType = "N"
+	KeyWordForward  KeyType = "*"
+	KeyWordBackward KeyType = "#"
+
+	// --- Search options ---
+	KeySearchCase      KeyType = "alt+c"
+	KeySearchSmartCase KeyType = "alt+s"
+	KeySearchWord      KeyType = "alt+w"
+	KeySearchRegex     KeyType = "alt+r"
+	KeySearchMultiline KeyType = "alt+m"
+	KeyPreserveCase    KeyType = "alt+k"
+
 	// --- Regular keys ---
 	Key0      KeyType = "0"
 	KeyDollar KeyType = "$"
@@ -0,0 +1,77 @@
+package editor
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/tobibamidele/minra/internal/buffer"
+	"github.com/tobibamidele/minra/internal/syntax"
+)
+
+// DefaultUserSyntaxDir returns the directory of user grammar files,
+// syntax/ next to the user config file
+func DefaultUserSyntaxDir(configPath string) string {
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "syntax")
+}
+
+// LoadGrammars registers the grammar files in dir over the bundled ones
+// and reports those that fail to load
+func (e *Editor) LoadGrammars(dir string) []error {
+	return e.languages.LoadDir(dir)
+}
+
+// detectLanguage sets the language of buf from its path and content
+func (e *Editor) detectLanguage(buf *buffer.Buffer) {
+	buf.SetLanguage(e.languages.Detect(buf.Filepath(), buf).Name())
+}
+
+// setFiletype is :set filetype, which overrides the detected language of
+// the active buffer
+func (e *Editor) setFiletype(name string) error {
+	buf := e.bufferMgr.ActiveBuffer()
+	if buf == nil {
+		return nil
+	}
+	lang, ok := e.languages.Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown filetype: %s", name)
+	}
+	buf.SetLanguage(lang.Name())
+	return nil
+}
+
+// filetype returns the language of the active buffer
+func (e *Editor) filetype() string {
+	buf := e.bufferMgr.ActiveBuffer()
+	if buf == nil || buf.Language() == "" {
+		return "plain"
+	}
+	return buf.Language()
+}
+
+// highlighterFor returns the highlighter of buf, switching it to the
+// buffer's language if that changed
+func (e *Editor) highlighterFor(buf *buffer.Buffer) *syntax.Highlighter {
+	h, ok := e.highlighters[buf.ID()]
+	if !ok {
+		h = syntax.New()
+		h.SetTheme(e.theme)
+		e.highlighters[buf.ID()] = h
+	}
+
+	name := buf.Language()
+	if name == "" {
+		name = "plain"
+	}
+	if h.Language().Name() != name {
+		lang, ok := e.languages.ByName(name)
+		if !ok {
+			lang, _ = e.languages.ByName("plain")
+		}
+		h.SetLanguage(lang)
+	}
+	return h
+}
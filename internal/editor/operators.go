@@ -0,0 +1,216 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tobibamidele/minra/internal/buffer"
+	"github.com/tobibamidele/minra/internal/clipboard"
+	"github.com/tobibamidele/minra/internal/cursor"
+)
+
+// operatorRange returns the text a parsed operator command acts on
+func (e *Editor) operatorRange(buf *buffer.Buffer, cmd command) (cursor.Range, bool) {
+	cur := buf.Cursor()
+
+	switch {
+	case cmd.linewise:
+		end := min(cur.Line()+cmd.countOr(1)-1, buf.LineCount()-1)
+		return cursor.Range{StartLine: cur.Line(), EndLine: end, Linewise: true}, true
+	case cmd.object != 0:
+		return e.textObject(buf, cmd)
+	default:
+		return e.motionRange(buf, cmd)
+	}
+}
+
+// applyOperator runs op over r as a single undo step. c leaves the
+// transaction open for the insert session that follows.
+func (e *Editor) applyOperator(buf *buffer.Buffer, op string, r cursor.Range) {
+	cur := buf.Cursor()
+
+	switch op {
+	case "y":
+		e.yankRange(buf, r)
+		if r.Linewise {
+			e.setCursor(buf, r.StartLine, cur.Col())
+		} else {
+			e.setCursor(buf, r.StartLine, r.StartCol)
+		}
+		return
+	case "c":
+		e.yankRange(buf, r)
+		e.enterInsertMode()
+		if r.Linewise {
+			// Keep the first line's indentation to type over
+			indent := buf.Line(r.StartLine)[:firstNonBlank(buf.Line(r.StartLine))]
+			buf.DeleteLines(r.StartLine+1, r.EndLine)
+			buf.SetLine(r.StartLine, indent)
+			e.setCursor(buf, r.StartLine, len(indent))
+		} else {
+			buf.DeleteRange(r.StartLine, r.StartCol, r.EndLine, r.EndCol)
+			e.setCursor(buf, r.StartLine, r.StartCol)
+		}
+		return
+	}
+
+	buf.BeginTransaction()
+	defer buf.CommitTransaction()
+
+	switch op {
+	case "d":
+		e.yankRange(buf, r)
+		if r.Linewise {
+			buf.DeleteLines(r.StartLine, r.EndLine)
+			cur.SetPosition(min(r.StartLine, buf.LineCount()-1), 0)
+			cur.MoveToFirstNonBlank(buf)
+			e.viewport.AdjustScroll(cur)
+		} else {
+			buf.DeleteRange(r.StartLine, r.StartCol, r.EndLine, r.EndCol)
+			e.setCursor(buf, r.StartLine, r.StartCol)
+		}
+		e.statusMsg = "Deleted"
+	case ">", "<":
+		e.indentLines(buf, r.StartLine, r.EndLine, op == ">")
+		e.setCursor(buf, r.StartLine, firstNonBlank(buf.Line(r.StartLine)))
+		e.statusMsg = fmt.Sprintf("%d lines %sed", r.EndLine-r.StartLine+1, op)
+	case "=":
+		e.reindentLines(buf, r.StartLine, r.EndLine)
+		e.setCursor(buf, r.StartLine, firstNonBlank(buf.Line(r.StartLine)))
+		e.statusMsg = fmt.Sprintf("%d lines indented", r.EndLine-r.StartLine+1)
+	case "gu":
+		e.mapRange(buf, r, strings.ToLower)
+	case "gU":
+		e.mapRange(buf, r, strings.ToUpper)
+	case "g~":
+		e.mapRange(buf, r, toggleCase)
+	}
+}
+
+// yankRange copies r into the register
+func (e *Editor) yankRange(buf *buffer.Buffer, r cursor.Range) {
+	if r.Linewise {
+		e.register.Yank(clipboard.CopyLines(buf, r.StartLine, r.EndLine), clipboard.ModeLine)
+		e.statusMsg = fmt.Sprintf("Yanked %d lines", r.EndLine-r.StartLine+1)
+		return
+	}
+	e.register.Yank(clipboard.CopySelection(buf, r.StartLine, r.StartCol, r.EndLine, r.EndCol), clipboard.ModeChar)
+	e.statusMsg = "Yanked"
+}
+
+// mapRange replaces the text in r with fn applied to it, line by line
+func (e *Editor) mapRange(buf *buffer.Buffer, r cursor.Range, fn func(string) string) {
+	for i := r.StartLine; i <= r.EndLine; i++ {
+		line := buf.Line(i)
+		from, to := 0, len(line)
+		if !r.Linewise {
+			if i == r.StartLine {
+				from = min(r.StartCol, len(line))
+			}
+			if i == r.EndLine {
+				to = min(r.EndCol, len(line))
+			}
+		}
+		if from >= to {
+			continue
+		}
+		if mapped := line[:from] + fn(line[from:to]) + line[to:]; mapped != line {
+			buf.SetLine(i, mapped)
+		}
+	}
+
+	if r.Linewise {
+		e.setCursor(buf, r.StartLine, buf.Cursor().Col())
+	} else {
+		e.setCursor(buf, r.StartLine, r.StartCol)
+	}
+}
+
+// indentLines shifts lines start through end by one indent level
+func (e *Editor) indentLines(buf *buffer.Buffer, start, end int, indent bool) {
+	width := e.viewport.TabSize()
+
+	buf.BeginTransaction()
+	defer buf.CommitTransaction()
+
+	for i := start; i <= end; i++ {
+		line := buf.Line(i)
+		if indent {
+			if line != "" {
+				buf.SetLine(i, strings.Repeat(" ", width)+line)
+			}
+			continue
+		}
+
+		// Remove one tab or up to one indent level of spaces
+		if strings.HasPrefix(line, "\t") {
+			buf.SetLine(i, line[1:])
+			continue
+		}
+		n := 0
+		for n < width && n < len(line) && line[n] == ' ' {
+			n++
+		}
+		if n > 0 {
+			buf.SetLine(i, line[n:])
+		}
+	}
+}
+
+// reindentLines indents lines start through end from the nearest non-blank
+// line above: one level deeper after a line ending in an opening bracket or
+// colon, one level shallower for a line starting with a closing bracket
+func (e *Editor) reindentLines(buf *buffer.Buffer, start, end int) {
+	width := e.viewport.TabSize()
+
+	prev := ""
+	for i := start - 1; i >= 0; i-- {
+		if strings.TrimSpace(buf.Line(i)) != "" {
+			prev = buf.Line(i)
+			break
+		}
+	}
+
+	buf.BeginTransaction()
+	defer buf.CommitTransaction()
+
+	for i := start; i <= end; i++ {
+		text := strings.TrimSpace(buf.Line(i))
+		if text == "" {
+			if buf.Line(i) != "" {
+				buf.SetLine(i, "")
+			}
+			continue
+		}
+
+		level := indentWidth(prev, width)
+		if trimmed := strings.TrimRight(prev, " \t"); trimmed != "" && strings.ContainsAny(trimmed[len(trimmed)-1:], "{([:") {
+			level += width
+		}
+		if strings.ContainsAny(text[:1], "})]") {
+			level = max(level-width, 0)
+		}
+
+		if line := strings.Repeat(" ", level) + text; line != buf.Line(i) {
+			buf.SetLine(i, line)
+		}
+		prev = buf.Line(i)
+	}
+}
+
+// indentWidth measures the leading whitespace of line, counting tabs as
+// width columns
+func indentWidth(line string, width int) int {
+	n := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			n++
+		case '\t':
+			n += width
+		default:
+			return n
+		}
+	}
+	return n
+}
@@ -0,0 +1,162 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tobibamidele/minra/internal/search"
+	"github.com/tobibamidele/minra/internal/viewport"
+)
+
+// replaceState is a replace from the search widget, asking about each
+// match in turn. The changes are one transaction, undone in one step.
+type replaceState struct {
+	template string // the replacement, with $1 for groups in regex mode
+	left     int    // matches not yet offered, so a wrap around stops
+	total    int
+	replaced int
+}
+
+// startReplace replaces the matches of the search widget's query from
+// where the search started, confirming each one
+func (e *Editor) startReplace() {
+	buf := e.bufferMgr.ActiveBuffer()
+	if buf == nil || e.searchWidget.GetInput() == "" || e.searchEngine.Err() != nil {
+		return
+	}
+
+	query := e.searchWidget.GetInput()
+	template := e.searchWidget.GetReplacement()
+	e.searchWidget.Hide()
+	e.mode = viewport.ModeNormal
+
+	from := e.searchFrom
+	e.setCursor(buf, from.line, from.col)
+	count := e.searchEngine.Count()
+	if count == 0 {
+		e.statusMsg = "Pattern not found: " + query
+		return
+	}
+
+	buf.BeginTransaction()
+	e.replacing = replaceState{template: template, left: count, total: count}
+	e.mode = viewport.ModeReplace
+	e.nextReplace(from.line, from.col)
+}
+
+// nextReplace offers the first match at or after line and col, wrapping
+// around, or ends the replace when every match has been offered
+func (e *Editor) nextReplace(line, col int) {
+	buf := e.bufferMgr.ActiveBuffer()
+	if e.replacing.left > 0 {
+		// Earlier replacements moved the matches after them
+		e.searchEngine.Search(buf)
+	}
+	if e.replacing.left == 0 || e.searchEngine.Count() == 0 {
+		e.endReplace()
+		return
+	}
+
+	r, _ := e.searchEngine.Seek(line, col, false)
+	e.setCursor(buf, r.Line, r.Column)
+	e.viewport.SetMatcher(e.searchMatches)
+	e.statusMsg = fmt.Sprintf("Replace with %q? (y/n/a/q)", e.searchEngine.Replacement(*r, e.replacing.template))
+}
+
+// replaceCurrent replaces the match under the cursor and moves on
+func (e *Editor) replaceCurrent() {
+	r := e.searchEngine.Current()
+	if r == nil {
+		e.endReplace()
+		return
+	}
+
+	buf := e.bufferMgr.ActiveBuffer()
+	text := e.searchEngine.Replacement(*r, e.replacing.template)
+	line, col := search.ReplaceAt(buf, *r, text)
+	e.replacing.replaced++
+	e.replacing.left--
+	// Go on after the replacement so it is not matched again
+	e.nextReplace(line, col)
+}
+
+// replaceAll replaces the match under the cursor and every match not yet
+// offered. The matches are found once and replaced from the bottom up, so
+// that each replacement leaves the ones still to do where they were. The
+// cursor ends on the last match offered, as if each had been said yes to.
+func (e *Editor) replaceAll() {
+	results := e.searchEngine.Results()
+	first := e.searchEngine.Index() - 1
+	if first < 0 {
+		e.endReplace()
+		return
+	}
+
+	count := min(e.replacing.left, len(results))
+	todo := make([]search.Result, 0, count)
+	for i := 0; i < count; i++ {
+		todo = append(todo, results[(first+i)%len(results)])
+	}
+	last := todo[len(todo)-1]
+	sort.Slice(todo, func(i, j int) bool {
+		return todo[i].Line > todo[j].Line || todo[i].Line == todo[j].Line && todo[i].Column > todo[j].Column
+	})
+
+	buf := e.bufferMgr.ActiveBuffer()
+	line, col := last.Line, last.Column
+	for _, r := range todo {
+		text := e.searchEngine.Replacement(r, e.replacing.template)
+		endLine, endCol := search.ReplaceAt(buf, r, text)
+
+		// Replacements before the last match move it
+		if r.Line < last.Line || r.Line == last.Line && r.Column < last.Column {
+			if r.EndLine == line {
+				col += endCol - r.EndColumn
+			}
+			line += endLine - r.EndLine
+		}
+	}
+
+	e.replacing.replaced += count
+	e.replacing.left -= count
+	e.setCursor(buf, line, col)
+	e.endReplace()
+}
+
+// skipCurrent leaves the match under the cursor as it is and moves on
+func (e *Editor) skipCurrent() {
+	r := e.searchEngine.Current()
+	if r == nil {
+		e.endReplace()
+		return
+	}
+	e.replacing.left--
+	e.nextReplace(r.EndLine, r.EndColumn)
+}
+
+// endReplace commits the replacements made so far
+func (e *Editor) endReplace() {
+	if buf := e.bufferMgr.ActiveBuffer(); buf != nil {
+		buf.CommitTransaction()
+		e.viewport.AdjustScroll(buf.Cursor())
+	}
+	e.mode = viewport.ModeNormal
+	e.statusMsg = fmt.Sprintf("Replaced %d of %d matches", e.replacing.replaced, e.replacing.total)
+	e.replacing = replaceState{}
+}
+
+func (e *Editor) handleReplaceMode(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "y":
+		e.replaceCurrent()
+	case "n":
+		e.skipCurrent()
+	case "a":
+		e.replaceAll()
+	case "q", "esc":
+		e.endReplace()
+	}
+
+	return nil
+}
@@ -0,0 +1,308 @@
+package editor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tobibamidele/minra/internal/buffer"
+	"github.com/tobibamidele/minra/internal/search"
+	"github.com/tobibamidele/minra/internal/viewport"
+	"github.com/tobibamidele/minra/pkg/fileio"
+)
+
+// patchResultMsg carries the planned replacements of one file
+type patchResultMsg struct {
+	id      int // the search they belong to
+	patch   *search.FilePatch
+	patches <-chan *search.FilePatch
+}
+
+// patchDoneMsg ends the planning of a replace across the workspace
+type patchDoneMsg struct {
+	id int
+}
+
+// waitPatch waits for the next file of replace preview id
+func waitPatch(id int, patches <-chan *search.FilePatch) tea.Cmd {
+	return func() tea.Msg {
+		patch, ok := <-patches
+		if !ok {
+			return patchDoneMsg{id: id}
+		}
+		return patchResultMsg{id: id, patch: patch, patches: patches}
+	}
+}
+
+// fileChange is a replace made in one file. It changes the file's lines
+// from before to after by edits, which work the same on a buffer.
+type fileChange struct {
+	path, rel string
+	crlf      bool
+	before    []string
+	after     []string
+	edits     []search.Edit
+}
+
+// inverse returns the change that undoes c
+func (c fileChange) inverse() fileChange {
+	return fileChange{
+		path:   c.path,
+		rel:    c.rel,
+		crlf:   c.crlf,
+		before: c.after,
+		after:  c.before,
+		edits:  search.InvertEdits(c.edits),
+	}
+}
+
+// previewReplace plans replacing the matches of the panel's query with
+// the replace field and shows the diff of each file
+func (e *Editor) previewReplace() tea.Cmd {
+	e.stopGrep()
+	e.grepID++
+
+	query, template := e.grepWidget.GetInput(), e.grepWidget.GetReplacement()
+	root, err := filepath.Abs(e.rootDir)
+	if err != nil {
+		e.grepWidget.SetError(err.Error())
+		return nil
+	}
+	ws := search.NewWorkspace(root)
+	ws.Buffers = e.openBufferLines()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	patches, err := ws.Replace(ctx, query, template, e.grepOptions)
+	if err != nil {
+		cancel()
+		e.grepWidget.SetError(err.Error())
+		return nil
+	}
+	e.grepCancel = cancel
+
+	e.grepWidget.Hide()
+	e.patchWidget.SetSize(e.width-6, e.height-10)
+	e.patchWidget.Show(query, template)
+	e.patchWidget.SetStatus("Searching…")
+	e.mode = viewport.ModePreview
+	e.statusMsg = "-- REPLACE PREVIEW --"
+	return waitPatch(e.grepID, patches)
+}
+
+// patchResult adds a file's diff to the preview and waits for the next
+func (e *Editor) patchResult(msg patchResultMsg) tea.Cmd {
+	if msg.id != e.grepID {
+		return nil
+	}
+	e.patchWidget.Add(msg.patch)
+	e.patchWidget.SetStatus("Searching… " + e.patchCounts())
+	return waitPatch(msg.id, msg.patches)
+}
+
+// patchDone reports the end of the planning
+func (e *Editor) patchDone(msg patchDoneMsg) {
+	if msg.id != e.grepID || e.grepCancel == nil {
+		return
+	}
+	e.stopGrep()
+	if _, total, _ := e.patchWidget.Counts(); total == 0 {
+		e.patchWidget.SetStatus("Nothing to replace")
+		return
+	}
+	e.patchWidget.SetStatus(e.patchCounts())
+}
+
+// patchCounts describes the replacements of the preview, as in
+// "12 of 14 replacements in 3 files"
+func (e *Editor) patchCounts() string {
+	enabled, total, files := e.patchWidget.Counts()
+	return fmt.Sprintf("%d of %d replacements in %d files", enabled, total, files)
+}
+
+// hidePreview closes the preview and goes back to the panel it came from
+func (e *Editor) hidePreview() {
+	e.stopGrep()
+	e.patchWidget.Hide()
+	e.grepWidget.Show()
+	e.mode = viewport.ModeGrep
+	e.statusMsg = "-- REPLACE IN FILES --"
+}
+
+// applyReplace makes the replacements of the enabled hunks in every file
+// of the preview, or in none of them
+func (e *Editor) applyReplace() {
+	if e.grepCancel != nil {
+		e.patchWidget.SetError("Still searching")
+		return
+	}
+
+	var changes []fileChange
+	matches := 0
+	for _, p := range e.patchWidget.Patches() {
+		edits := p.Edits()
+		if len(edits) == 0 {
+			continue
+		}
+		enabled, _ := p.Matches()
+		matches += enabled
+		changes = append(changes, fileChange{
+			path:   p.Path,
+			rel:    p.Rel,
+			crlf:   p.CRLF,
+			before: p.Lines,
+			after:  p.Result(),
+			edits:  edits,
+		})
+	}
+	if len(changes) == 0 {
+		e.patchWidget.SetError("No hunks to apply")
+		return
+	}
+
+	if err := e.applyFileChanges(changes); err != nil {
+		e.patchWidget.SetError(fmt.Sprintf("Cannot replace: %v", err))
+		return
+	}
+	e.fileReplace = changes
+
+	// The matches listed are gone
+	e.grepWidget.Clear()
+	e.grepWidget.SetStatus("")
+	e.patchWidget.Hide()
+	e.mode = viewport.ModeNormal
+	e.statusMsg = fmt.Sprintf("Replaced %d matches in %d files (:undoreplace reverts them)", matches, len(changes))
+}
+
+// undoFileReplace reverts the last replace across the workspace in every
+// file it touched
+func (e *Editor) undoFileReplace() {
+	if e.fileReplace == nil {
+		e.statusMsg = "No replace in files to undo"
+		return
+	}
+
+	changes := make([]fileChange, len(e.fileReplace))
+	for i, c := range e.fileReplace {
+		changes[i] = c.inverse()
+	}
+	if err := e.applyFileChanges(changes); err != nil {
+		e.statusMsg = fmt.Sprintf("Cannot undo replace: %v", err)
+		return
+	}
+	e.fileReplace = nil
+	e.statusMsg = fmt.Sprintf("Reverted the replace in %d files", len(changes))
+}
+
+// applyFileChanges makes every change or none. Open files are changed in
+// their buffers as one undo step each, other files on disk. Nothing is
+// changed unless every file still has the lines the changes expect, and
+// files already written are restored if writing another fails.
+func (e *Editor) applyFileChanges(changes []fileChange) error {
+	bufs := make([]*buffer.Buffer, len(changes))
+	for i, c := range changes {
+		var lines []string
+		if buf := e.bufferForPath(c.path); buf != nil {
+			bufs[i] = buf
+			lines = buf.Lines()
+		} else {
+			doc, err := search.ReadDocument(c.path)
+			if err != nil {
+				return fmt.Errorf("%s: %v", c.rel, err)
+			}
+			lines = doc.Lines
+		}
+		if !slices.Equal(lines, c.before) {
+			return fmt.Errorf("%s has changed in the meantime", c.rel)
+		}
+	}
+
+	var written []fileChange
+	for i, c := range changes {
+		if bufs[i] != nil {
+			continue
+		}
+		doc := search.Document{Lines: c.after, CRLF: c.crlf}
+		if err := fileio.WriteFileAtomic(c.path, doc.Content()); err != nil {
+			for _, w := range written {
+				doc := search.Document{Lines: w.before, CRLF: w.crlf}
+				fileio.WriteFileAtomic(w.path, doc.Content())
+			}
+			return fmt.Errorf("%s: %v", c.rel, err)
+		}
+		written = append(written, c)
+	}
+
+	for i, c := range changes {
+		if buf := bufs[i]; buf != nil {
+			applyEdits(buf, c.edits)
+			// Keep the cursor in the lines left
+			cur := buf.Cursor()
+			line := min(cur.Line(), buf.LineCount()-1)
+			cur.SetPosition(line, min(cur.Col(), len(buf.Line(line))))
+		}
+	}
+	return nil
+}
+
+// applyEdits makes edits in buf as one undo step
+func applyEdits(buf *buffer.Buffer, edits []search.Edit) {
+	buf.BeginTransaction()
+	defer buf.CommitTransaction()
+
+	// From the last, so the lines of the others stay put
+	for i := len(edits) - 1; i >= 0; i-- {
+		edit := edits[i]
+		last := edit.Line + len(edit.Old) - 1
+		buf.DeleteRange(edit.Line, 0, last, len(buf.Line(last)))
+		buf.InsertText(edit.Line, 0, strings.Join(edit.New, "\n"))
+	}
+}
+
+// bufferForPath returns the open buffer of the file at the absolute path,
+// or nil
+func (e *Editor) bufferForPath(path string) *buffer.Buffer {
+	for _, buf := range e.bufferMgr.AllBuffers() {
+		if buf.Filepath() == "" {
+			continue
+		}
+		if p, err := filepath.Abs(buf.Filepath()); err == nil && p == path {
+			return buf
+		}
+	}
+	return nil
+}
+
+// togglePatch updates the counts after hunks were toggled
+func (e *Editor) togglePatch() {
+	e.patchWidget.SetError("")
+	if e.grepCancel == nil {
+		e.patchWidget.SetStatus(e.patchCounts())
+	}
+}
+
+func (e *Editor) handlePreviewMode(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		e.hidePreview()
+	case "enter":
+		e.applyReplace()
+	case "up", "k", "ctrl+p":
+		e.patchWidget.MoveUp()
+	case "down", "j", "ctrl+n":
+		e.patchWidget.MoveDown()
+	case "pgup":
+		e.patchWidget.PageUp()
+	case "pgdown":
+		e.patchWidget.PageDown()
+	case " ":
+		e.patchWidget.ToggleHunk()
+		e.togglePatch()
+	case "f":
+		e.patchWidget.ToggleFile()
+		e.togglePatch()
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package editor
+
+import (
+	"fmt"
+
+	"github.com/tobibamidele/minra/internal/buffer"
+	"github.com/tobibamidele/minra/internal/cursor"
+	"github.com/tobibamidele/minra/internal/search"
+	"github.com/tobibamidele/minra/internal/viewport"
+)
+
+// searchOrigin is where a search being typed started from. The cursor
+// goes back there, and the previous search is restored, if it is
+// cancelled.
+type searchOrigin struct {
+	line, col     int
+	query         string
+	options       search.Options
+	searchOptions *search.Options
+	matcher       viewport.Matcher
+}
+
+// showSearch opens the search widget with the current search options. A
+// new search drops the options a * or # search set.
+func (e *Editor) showSearch() {
+	if buf := e.bufferMgr.ActiveBuffer(); buf != nil {
+		line, col := buf.Cursor().Position()
+		e.searchFrom = searchOrigin{
+			line:          line,
+			col:           col,
+			query:         e.searchEngine.Query(),
+			options:       e.searchEngine.Options(),
+			searchOptions: e.searchOptions,
+			matcher:       e.viewport.Matcher(),
+		}
+	}
+	if e.searchOptions != nil {
+		e.searchEngine.SetOptions(*e.searchOptions)
+		e.searchOptions = nil
+	}
+
+	e.mode = viewport.ModeSearch
+	e.searchWidget.Show()
+	e.searchWidget.SetOptions(e.searchEngine.Options())
+	e.searchWidget.SetError("")
+	e.statusMsg = "-- SEARCH --"
+}
+
+// toggleSearchOption flips the search option bound to key
+func (e *Editor) toggleSearchOption(key KeyType) {
+	opts := e.searchEngine.Options()
+	switch key {
+	case KeySearchCase:
+		opts.CaseSensitive = !opts.CaseSensitive
+	case KeySearchSmartCase:
+		opts.SmartCase = !opts.SmartCase
+	case KeySearchWord:
+		opts.WholeWord = !opts.WholeWord
+	case KeySearchRegex:
+		opts.Regex = !opts.Regex
+	case KeySearchMultiline:
+		opts.Multiline = !opts.Multiline
+	case KeyPreserveCase:
+		opts.PreserveCase = !opts.PreserveCase
+	}
+	e.searchEngine.SetOptions(opts)
+	e.searchWidget.SetOptions(opts)
+	e.updateSearch()
+}
+
+// updateSearch searches for the query typed so far, moving the cursor to
+// the first match from where the search started
+func (e *Editor) updateSearch() {
+	buf := e.bufferMgr.ActiveBuffer()
+	if buf == nil {
+		return
+	}
+	from := e.searchFrom
+
+	query := e.searchWidget.GetInput()
+	e.searchEngine.SetQuery(query)
+	e.searchWidget.SetError("")
+	e.searchWidget.SetCount(0, -1)
+	e.viewport.SetMatcher(nil)
+
+	if err := e.searchEngine.Err(); err != nil {
+		e.searchWidget.SetError(err.Error())
+		e.setCursor(buf, from.line, from.col)
+		return
+	}
+	if query == "" {
+		e.setCursor(buf, from.line, from.col)
+		return
+	}
+
+	e.searchEngine.Search(buf)
+	r, _ := e.searchEngine.Seek(from.line, from.col, false)
+	e.searchWidget.SetCount(e.searchEngine.Index(), e.searchEngine.Count())
+	if r == nil {
+		e.setCursor(buf, from.line, from.col)
+		return
+	}
+	e.setCursor(buf, r.Line, r.Column)
+	e.viewport.SetMatcher(e.searchMatches)
+}
+
+// finishSearch closes the search widget, leaving the cursor on the match
+func (e *Editor) finishSearch() {
+	query := e.searchWidget.GetInput()
+	if query == "" || e.searchEngine.Err() != nil {
+		// Keep the widget open so the pattern can be fixed
+		return
+	}
+
+	e.searchWidget.Hide()
+	e.mode = viewport.ModeNormal
+	if e.searchEngine.Count() == 0 {
+		e.statusMsg = "Pattern not found: " + query
+		return
+	}
+	e.statusMsg = e.searchStatus("")
+}
+
+// cancelSearch closes the search widget, putting the cursor back and
+// restoring the previous search
+func (e *Editor) cancelSearch() {
+	from := e.searchFrom
+	e.searchWidget.Hide()
+	e.mode = viewport.ModeNormal
+
+	e.searchEngine.SetOptions(from.options)
+	e.searchEngine.SetQuery(from.query)
+	e.searchOptions = from.searchOptions
+	e.viewport.SetMatcher(from.matcher)
+	if buf := e.bufferMgr.ActiveBuffer(); buf != nil {
+		e.searchEngine.Search(buf)
+		e.setCursor(buf, from.line, from.col)
+	}
+}
+
+// searchStatus describes the current match for the status bar, as in
+// "[3/17] /query", after a note such as a wrap-around
+func (e *Editor) searchStatus(note string) string {
+	status := fmt.Sprintf("[%d/%d] /%s", e.searchEngine.Index(), e.searchEngine.Count(), e.searchEngine.Query())
+	if note != "" {
+		status += "  " + note
+	}
+	return status
+}
+
+// searchMatches highlights the current search query on a line. The match
+// under the cursor is the current one.
+func (e *Editor) searchMatches(line int, text string) []viewport.Match {
+	results := e.searchEngine.MatchLine(line, text)
+	if len(results) == 0 {
+		return nil
+	}
+
+	var cur *cursor.Cursor
+	if buf := e.bufferMgr.ActiveBuffer(); buf != nil {
+		cur = buf.Cursor()
+	}
+
+	// A match spanning lines covers the end of its first line, the whole
+	// of the lines between and the start of its last
+	matches := make([]viewport.Match, len(results))
+	for i, r := range results {
+		start, end := 0, len(text)
+		if r.Line == line {
+			start = r.Column
+		}
+		if r.EndLine == line {
+			end = r.EndColumn
+		}
+		matches[i] = viewport.Match{
+			Start:   start,
+			End:     end,
+			Current: cur != nil && cur.Line() == r.Line && cur.Col() == r.Column,
+		}
+	}
+	return matches
+}
+
+// searchMotion is n and N: to the next or previous match of the last
+// search, wrapping around the ends of the buffer
+func searchMotion(backward bool) motion {
+	return motion{kind: exclusive, move: func(e *Editor, buf *buffer.Buffer, c *cursor.Cursor, cmd command) bool {
+		if e.searchEngine.Query() == "" {
+			e.statusMsg = "No previous search"
+			return false
+		}
+		return e.searchStep(buf, c, cmd.countOr(1), backward)
+	}}
+}
+
+// wordSearchMotion is * and #: search for the word under the cursor as a
+// whole word, then go to its next or previous match. n and N keep
+// matching whole words until the next search is typed, which goes back to
+// the options from before.
+func wordSearchMotion(backward bool) motion {
+	return motion{kind: exclusive, move: func(e *Editor, buf *buffer.Buffer, c *cursor.Cursor, cmd command) bool {
+		word, start := wordAt(buf.Line(c.Line()), c.Col())
+		if word == "" {
+			e.statusMsg = "No word under cursor"
+			return false
+		}
+
+		opts := e.searchEngine.Options()
+		if e.searchOptions == nil {
+			saved := opts
+			e.searchOptions = &saved
+		}
+		opts.Regex, opts.WholeWord, opts.Multiline = false, true, false
+		e.searchEngine.SetOptions(opts)
+		e.searchEngine.SetQuery(word)
+
+		// Step from the start of the word so # skips the word itself
+		c.SetPosition(c.Line(), start)
+		return e.searchStep(buf, c, cmd.countOr(1), backward)
+	}}
+}
+
+// searchStep moves c count matches on from where it is and highlights the
+// matches
+func (e *Editor) searchStep(buf *buffer.Buffer, c *cursor.Cursor, count int, backward bool) bool {
+	e.searchEngine.Search(buf)
+	if e.searchEngine.Err() != nil || e.searchEngine.Count() == 0 {
+		e.statusMsg = "Pattern not found: " + e.searchEngine.Query()
+		return false
+	}
+
+	note := ""
+	for ; count > 0; count-- {
+		col := c.Col() + 1
+		if backward {
+			col = c.Col()
+		}
+		r, wrapped := e.searchEngine.Seek(c.Line(), col, backward)
+		if wrapped && backward {
+			note = "search hit TOP, continuing at BOTTOM"
+		} else if wrapped {
+			note = "search hit BOTTOM, continuing at TOP"
+		}
+		c.SetPosition(r.Line, r.Column)
+	}
+
+	e.viewport.SetMatcher(e.searchMatches)
+	e.statusMsg = e.searchStatus(note)
+	return true
+}
+
+// wordAt returns the keyword under or after col in text, and where it
+// starts
+func wordAt(text string, col int) (string, int) {
+	isWord := func(b byte) bool {
+		return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b >= 0x80
+	}
+
+	start := min(max(col, 0), len(text))
+	for start < len(text) && !isWord(text[start]) {
+		start++
+	}
+	if start == len(text) {
+		return "", 0
+	}
+	for start > 0 && isWord(text[start-1]) {
+		start--
+	}
+	end := start
+	for end < len(text) && isWord(text[end]) {
+		end++
+	}
+	return text[start:end], start
+}
@@ -0,0 +1,173 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tobibamidele/minra/internal/buffer"
+	"github.com/tobibamidele/minra/internal/cursor"
+	"github.com/tobibamidele/minra/internal/syntax/tree"
+	"github.com/tobibamidele/minra/internal/viewport"
+	"github.com/tobibamidele/minra/internal/widgets"
+)
+
+// syntaxTree returns the parse tree of buf, reparsing it if it was edited
+// since the last call
+func (e *Editor) syntaxTree(buf *buffer.Buffer) *tree.Tree {
+	p, ok := e.syntaxTrees[buf.ID()]
+	if !ok {
+		p = tree.NewParser()
+		e.syntaxTrees[buf.ID()] = p
+	}
+	return p.Parse(buf, e.highlighterFor(buf).Language())
+}
+
+// cursorPos returns the cursor position as a tree position
+func cursorPos(c *cursor.Cursor) tree.Pos {
+	return tree.Pos{Line: c.Line(), Col: c.Col()}
+}
+
+// functionStart is the [[ and ]] motion: to the start of the previous or
+// next function
+func functionStart(backward bool) motion {
+	return motion{kind: exclusive, move: func(e *Editor, buf *buffer.Buffer, c *cursor.Cursor, cmd command) bool {
+		funcs := e.syntaxTree(buf).Functions()
+		for n := cmd.countOr(1); n > 0; n-- {
+			pos := cursorPos(c)
+			var target *tree.Node
+			if backward {
+				for i := len(funcs) - 1; i >= 0 && target == nil; i-- {
+					if funcs[i].Start.Before(pos) {
+						target = funcs[i]
+					}
+				}
+			} else {
+				for i := 0; i < len(funcs) && target == nil; i++ {
+					if pos.Before(funcs[i].Start) {
+						target = funcs[i]
+					}
+				}
+			}
+			if target == nil {
+				return false
+			}
+			c.SetPosition(target.Start.Line, target.Start.Col)
+		}
+		return true
+	}}
+}
+
+// textObject returns the text object cmd names at the cursor. Functions
+// and arguments come from the parse tree, the rest from the cursor.
+func (e *Editor) textObject(buf *buffer.Buffer, cmd command) (cursor.Range, bool) {
+	switch cmd.object {
+	case 'f':
+		return e.functionObject(buf, cmd.around)
+	case 'a':
+		return e.argumentObject(buf, cmd.around)
+	}
+	return buf.Cursor().TextObject(buf, cmd.object, cmd.around)
+}
+
+// functionObject is af, the whole function on its lines, or if, the inside
+// of its body
+func (e *Editor) functionObject(buf *buffer.Buffer, around bool) (cursor.Range, bool) {
+	fn := e.syntaxTree(buf).Enclosing(tree.NodeFunction, cursorPos(buf.Cursor()))
+	if fn == nil {
+		return cursor.Range{}, false
+	}
+
+	if around {
+		end := fn.End.Line
+		if fn.End.Col == 0 && end > fn.Start.Line {
+			end--
+		}
+		return cursor.Range{StartLine: fn.Start.Line, EndLine: end, Linewise: true}, true
+	}
+
+	if fn.Body == nil {
+		return cursor.Range{}, false
+	}
+	start, end := fn.Body.Start, fn.Body.End
+	if line := buf.Line(start.Line); start.Col < len(line) && line[start.Col] == '{' {
+		start.Col++
+		end.Col--
+	}
+
+	// A body that starts and ends on lines of its own is taken whole
+	if strings.TrimSpace(buf.Line(start.Line)[start.Col:]) == "" {
+		first, last := start.Line+1, end.Line
+		if end.Col <= firstNonBlank(buf.Line(end.Line)) {
+			last--
+		}
+		if first <= last {
+			return cursor.Range{StartLine: first, EndLine: last, Linewise: true}, true
+		}
+	}
+	return cursor.Range{StartLine: start.Line, StartCol: start.Col, EndLine: end.Line, EndCol: end.Col}, true
+}
+
+// argumentObject is ia, the argument under the cursor, or aa, which also
+// takes the separator after it, or before it for the last argument
+func (e *Editor) argumentObject(buf *buffer.Buffer, around bool) (cursor.Range, bool) {
+	arg := e.syntaxTree(buf).Enclosing(tree.NodeArgument, cursorPos(buf.Cursor()))
+	if arg == nil {
+		return cursor.Range{}, false
+	}
+
+	start, end := arg.Start, arg.End
+	if around {
+		args := arg.Parent.Arguments()
+		for i, a := range args {
+			if a != arg {
+				continue
+			}
+			if i+1 < len(args) {
+				end = args[i+1].Start
+			} else if i > 0 {
+				start = args[i-1].End
+			}
+		}
+	}
+	return cursor.Range{StartLine: start.Line, StartCol: start.Col, EndLine: end.Line, EndCol: end.Col}, true
+}
+
+// showOutline lists the symbols of the current buffer in the palette and
+// jumps to the one picked
+func (e *Editor) showOutline() {
+	buf := e.bufferMgr.ActiveBuffer()
+	if buf == nil {
+		return
+	}
+	symbols := e.syntaxTree(buf).Symbols()
+	if len(symbols) == 0 {
+		e.statusMsg = "No symbols"
+		return
+	}
+
+	items := make([]widgets.PaletteItem, len(symbols))
+	for i, s := range symbols {
+		kind := "func"
+		if s.Kind == tree.NodeType {
+			kind = "type"
+		}
+		items[i] = widgets.PaletteItem{
+			ID:    fmt.Sprint(i),
+			Title: strings.Repeat("  ", s.Depth()) + s.Name,
+			Key:   fmt.Sprintf("%s %d", kind, s.Start.Line+1),
+		}
+	}
+
+	e.paletteReturn = e.mode
+	e.paletteSelect = func(id string) tea.Cmd {
+		var i int
+		fmt.Sscan(id, &i)
+		s := symbols[i]
+		e.setCursor(buf, s.NameStart.Line, s.NameStart.Col)
+		return nil
+	}
+	e.paletteWidget.Show(items)
+	e.mode = viewport.ModePalette
+	e.statusMsg = "-- OUTLINE --"
+}
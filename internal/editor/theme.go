@@ -0,0 +1,128 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/termenv"
+	"github.com/tobibamidele/minra/internal/syntax"
+	"github.com/tobibamidele/minra/internal/ui"
+	"github.com/tobibamidele/minra/internal/viewport"
+	"github.com/tobibamidele/minra/internal/widgets"
+)
+
+// DefaultUserThemeDir returns the directory of user theme files, themes/
+// next to the user config file
+func DefaultUserThemeDir(configPath string) string {
+	if configPath == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(configPath), "themes")
+}
+
+// LoadThemes adds the theme files in dir to the bundled ones and reports
+// those that fail to load. It runs before the config is read so the
+// config may name a user theme.
+func LoadThemes(dir string) []error {
+	return syntax.LoadThemeDir(dir)
+}
+
+// applyTheme colors the syntax of every buffer and the rest of the UI
+// with t, downsampled to the terminal's colors
+func (e *Editor) applyTheme(t *syntax.Theme) {
+	e.theme = t.Downsampled()
+	for _, h := range e.highlighters {
+		h.SetTheme(e.theme)
+	}
+	ui.Apply(t.UI)
+}
+
+// setColorMode is :set colormode, which switches the color profile and
+// redraws the theme for it
+func (e *Editor) setColorMode(mode string) error {
+	p, err := ui.ProfileFor(mode, os.Getenv)
+	if err != nil {
+		return err
+	}
+	e.config.ColorMode = mode
+	ui.SetProfile(p)
+	if t, ok := syntax.ThemeByName(e.config.Theme); ok {
+		e.applyTheme(t)
+	}
+	return nil
+}
+
+// colorMode returns the color_mode setting and, for auto, what it found
+func (e *Editor) colorMode() string {
+	mode := e.config.ColorMode
+	if mode == "" || mode == "auto" {
+		return fmt.Sprintf("auto (%s)", profileName(ui.Profile()))
+	}
+	return mode
+}
+
+// profileName is the color_mode value of a color profile
+func profileName(p termenv.Profile) string {
+	switch p {
+	case termenv.TrueColor:
+		return "truecolor"
+	case termenv.ANSI256:
+		return "256"
+	case termenv.ANSI:
+		return "16"
+	}
+	return "mono"
+}
+
+// setColorscheme is :colorscheme, which switches to the named theme
+func (e *Editor) setColorscheme(name string) error {
+	t, ok := syntax.ThemeByName(name)
+	if !ok {
+		return fmt.Errorf("unknown theme %q (available: %s)", name, strings.Join(syntax.ThemeNames(), ", "))
+	}
+	e.config.Theme = t.Name
+	e.applyTheme(t)
+	return nil
+}
+
+// completeTheme lists the themes starting with arg
+func completeTheme(arg string) []string {
+	var matches []string
+	for _, name := range syntax.ThemeNames() {
+		if strings.HasPrefix(name, arg) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
+
+// showThemes lists the themes in the palette and switches to the one
+// picked
+func (e *Editor) showThemes() {
+	var items []widgets.PaletteItem
+	for _, name := range syntax.ThemeNames() {
+		t, _ := syntax.ThemeByName(name)
+		key := "dark"
+		if !t.Dark {
+			key = "light"
+		}
+		if name == e.theme.Name {
+			key = "current · " + key
+		}
+		items = append(items, widgets.PaletteItem{ID: name, Title: name, Key: key})
+	}
+
+	e.paletteReturn = e.mode
+	e.paletteSelect = func(id string) tea.Cmd {
+		if err := e.setColorscheme(id); err != nil {
+			e.statusMsg = err.Error()
+		}
+		return nil
+	}
+	e.paletteWidget.Show(items)
+	e.mode = viewport.ModePalette
+	e.statusMsg = "-- THEME --"
+}
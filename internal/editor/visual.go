@@ -0,0 +1,284 @@
+package editor
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/tobibamidele/minra/internal/buffer"
+	"github.com/tobibamidele/minra/internal/clipboard"
+	"github.com/tobibamidele/minra/internal/viewport"
+)
+
+// visualLabels are the status messages for each selection mode
+var visualLabels = map[clipboard.Mode]string{
+	clipboard.ModeChar:  "-- VISUAL --",
+	clipboard.ModeLine:  "-- VISUAL LINE --",
+	clipboard.ModeBlock: "-- VISUAL BLOCK --",
+}
+
+// enterVisualMode starts a selection anchored at the cursor
+func (e *Editor) enterVisualMode(mode clipboard.Mode) {
+	buf := e.bufferMgr.ActiveBuffer()
+	if buf == nil {
+		return
+	}
+
+	line, col := buf.Cursor().Position()
+	e.viewport.SetSelection(&viewport.Selection{
+		Mode:       mode,
+		AnchorLine: line,
+		AnchorCol:  col,
+		CursorLine: line,
+		CursorCol:  col,
+	})
+	e.mode = viewport.ModeVisual
+	e.statusMsg = visualLabels[mode]
+}
+
+// exitVisualMode clears the selection and returns to normal mode. The
+// selected lines are kept as the '< and '> marks for ex ranges.
+func (e *Editor) exitVisualMode() {
+	if sel := e.viewport.Selection(); sel != nil {
+		e.visualStart, _, e.visualEnd, _ = sel.Bounds()
+	}
+	e.viewport.SetSelection(nil)
+	e.mode = viewport.ModeNormal
+	e.statusMsg = "-- NORMAL --"
+}
+
+func (e *Editor) handleVisualMode(msg tea.KeyMsg) tea.Cmd {
+	buf := e.bufferMgr.ActiveBuffer()
+	sel := e.viewport.Selection()
+	if buf == nil || sel == nil {
+		e.exitVisualMode()
+		return nil
+	}
+
+	cmd, ok := e.readCommand(msg, true)
+	if !ok {
+		return nil
+	}
+
+	cur := buf.Cursor()
+
+	switch {
+	case cmd.motion != "":
+		e.runMotion(buf, cmd)
+		sel.CursorLine, sel.CursorCol = cur.Position()
+		return nil
+	case cmd.object != 0:
+		e.selectTextObject(buf, sel, cmd)
+		return nil
+	}
+
+	key := KeyType(cmd.action)
+	switch key {
+	case KeyEscape:
+		e.exitVisualMode()
+	case KeyColon:
+		e.exitVisualMode()
+		e.showCommandLine("'<,'>")
+	case KeyVisual, KeyVisualLine, KeyVisualBlock:
+		mode := map[KeyType]clipboard.Mode{
+			KeyVisual:      clipboard.ModeChar,
+			KeyVisualLine:  clipboard.ModeLine,
+			KeyVisualBlock: clipboard.ModeBlock,
+		}[key]
+		// Pressing the key of the current mode leaves visual mode
+		if mode == sel.Mode {
+			e.exitVisualMode()
+			return nil
+		}
+		sel.Mode = mode
+		e.statusMsg = visualLabels[mode]
+	case KeySwapEnds:
+		sel.AnchorLine, sel.CursorLine = sel.CursorLine, sel.AnchorLine
+		sel.AnchorCol, sel.CursorCol = sel.CursorCol, sel.AnchorCol
+		cur.SetPosition(sel.CursorLine, sel.CursorCol)
+		e.viewport.AdjustScroll(cur)
+	case KeyY:
+		e.yankSelection(buf, sel)
+		e.moveToSelectionStart(buf, sel)
+		e.exitVisualMode()
+	case KeyDeleteOp, KeyCut:
+		e.yankSelection(buf, sel)
+		buf.BeginTransaction()
+		e.deleteSelection(buf, sel)
+		buf.CommitTransaction()
+		e.exitVisualMode()
+		e.statusMsg = "Deleted selection"
+	case KeyChange:
+		e.yankSelection(buf, sel)
+		// The insert session shares the delete's transaction so the change
+		// undoes in one step
+		e.viewport.SetSelection(nil)
+		e.enterInsertMode()
+		if sel.Mode == clipboard.ModeLine {
+			startLine, _, endLine, _ := sel.Bounds()
+			buf.DeleteLines(startLine+1, endLine)
+			buf.SetLine(startLine, "")
+			cur.SetPosition(startLine, 0)
+		} else {
+			e.deleteSelection(buf, sel)
+		}
+		e.viewport.AdjustScroll(cur)
+	case KeyIndent, KeyOutdent:
+		buf.BeginTransaction()
+		for i := cmd.countOr(1); i > 0; i-- {
+			e.indentSelection(buf, sel, key == KeyIndent)
+		}
+		buf.CommitTransaction()
+		e.moveToSelectionStart(buf, sel)
+		e.exitVisualMode()
+	case KeyReindent:
+		startLine, _, endLine, _ := sel.Bounds()
+		e.reindentLines(buf, startLine, endLine)
+		e.moveToSelectionStart(buf, sel)
+		e.exitVisualMode()
+	case KeyToggle, "g~":
+		e.mapSelection(buf, sel, toggleCase)
+		e.exitVisualMode()
+	case KeyLower, "gu":
+		e.mapSelection(buf, sel, strings.ToLower)
+		e.exitVisualMode()
+	case KeyUpper, "gU":
+		e.mapSelection(buf, sel, strings.ToUpper)
+		e.exitVisualMode()
+	}
+
+	return nil
+}
+
+// selectTextObject replaces the selection with the text object named by
+// cmd. Linewise objects such as paragraphs switch to line selection.
+func (e *Editor) selectTextObject(buf *buffer.Buffer, sel *viewport.Selection, cmd command) {
+	r, ok := e.textObject(buf, cmd)
+	if !ok {
+		e.statusMsg = "No text object " + string(cmd.object)
+		return
+	}
+
+	if r.Linewise {
+		sel.Mode = clipboard.ModeLine
+		sel.AnchorLine, sel.AnchorCol = r.StartLine, 0
+		sel.CursorLine, sel.CursorCol = r.EndLine, 0
+	} else {
+		// Selections include the character under the cursor, ranges do not
+		endLine, endCol := r.EndLine, r.EndCol-1
+		if endCol < 0 && endLine > r.StartLine {
+			endLine--
+			endCol = len(buf.Line(endLine))
+		}
+		sel.AnchorLine, sel.AnchorCol = r.StartLine, r.StartCol
+		sel.CursorLine, sel.CursorCol = endLine, max(endCol, r.StartCol)
+	}
+	e.statusMsg = visualLabels[sel.Mode]
+	e.setCursor(buf, sel.CursorLine, sel.CursorCol)
+}
+
+// yankSelection copies the selection and remembers its mode for paste
+func (e *Editor) yankSelection(buf *buffer.Buffer, sel *viewport.Selection) {
+	startLine, startCol, endLine, endCol := sel.Bounds()
+
+	var text string
+	switch sel.Mode {
+	case clipboard.ModeLine:
+		text = clipboard.CopyLines(buf, startLine, endLine)
+	case clipboard.ModeBlock:
+		text = clipboard.CopyBlock(buf, startLine, startCol, endLine, endCol+1)
+	default:
+		text = clipboard.CopySelection(buf, startLine, startCol, endLine, endCol+1)
+		if endCol >= len(buf.Line(endLine)) && endLine < buf.LineCount()-1 {
+			text += "\n"
+		}
+	}
+
+	e.register.Yank(text, sel.Mode)
+	e.statusMsg = fmt.Sprintf("Yanked %d lines", endLine-startLine+1)
+}
+
+// deleteSelection removes the selected text and leaves the cursor at the
+// start of the selection
+func (e *Editor) deleteSelection(buf *buffer.Buffer, sel *viewport.Selection) {
+	startLine, startCol, endLine, endCol := sel.Bounds()
+
+	switch sel.Mode {
+	case clipboard.ModeLine:
+		buf.DeleteLines(startLine, endLine)
+		startCol = 0
+	case clipboard.ModeBlock:
+		for i := startLine; i <= endLine; i++ {
+			line := buf.Line(i)
+			from := min(startCol, len(line))
+			to := min(endCol+1, len(line))
+			buf.SetLine(i, line[:from]+line[to:])
+		}
+	default:
+		// Selecting past the end of a line takes the line break with it
+		if endCol >= len(buf.Line(endLine)) && endLine < buf.LineCount()-1 {
+			buf.DeleteRange(startLine, startCol, endLine+1, 0)
+		} else {
+			buf.DeleteRange(startLine, startCol, endLine, endCol+1)
+		}
+	}
+
+	e.setCursor(buf, startLine, startCol)
+}
+
+// indentSelection shifts every selected line by one indent level
+func (e *Editor) indentSelection(buf *buffer.Buffer, sel *viewport.Selection, indent bool) {
+	startLine, _, endLine, _ := sel.Bounds()
+	e.indentLines(buf, startLine, endLine, indent)
+}
+
+// mapSelection replaces the selected text on each line with fn applied to it
+func (e *Editor) mapSelection(buf *buffer.Buffer, sel *viewport.Selection, fn func(string) string) {
+	startLine, _, endLine, _ := sel.Bounds()
+
+	buf.BeginTransaction()
+	defer buf.CommitTransaction()
+
+	for i := startLine; i <= endLine; i++ {
+		line := buf.Line(i)
+		from, to, ok := sel.LineRange(i, len(line))
+		if !ok {
+			continue
+		}
+		from = min(from, len(line))
+		to = min(to, len(line))
+		if mapped := line[:from] + fn(line[from:to]) + line[to:]; mapped != line {
+			buf.SetLine(i, mapped)
+		}
+	}
+
+	e.moveToSelectionStart(buf, sel)
+}
+
+// moveToSelectionStart puts the cursor at the top-left of the selection
+func (e *Editor) moveToSelectionStart(buf *buffer.Buffer, sel *viewport.Selection) {
+	startLine, startCol, _, _ := sel.Bounds()
+	if sel.Mode == clipboard.ModeLine {
+		startCol = buf.Cursor().Col()
+	}
+	e.setCursor(buf, startLine, startCol)
+}
+
+// setCursor moves the cursor to line and col, clamped to the buffer
+func (e *Editor) setCursor(buf *buffer.Buffer, line, col int) {
+	line = min(max(line, 0), buf.LineCount()-1)
+	col = min(max(col, 0), len(buf.Line(line)))
+	buf.Cursor().SetPosition(line, col)
+	e.viewport.AdjustScroll(buf.Cursor())
+}
+
+// toggleCase swaps the case of every letter in s
+func toggleCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
@@ -0,0 +1,227 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultDiffContext is the number of unchanged lines shown around the
+// changes of a hunk, as in diff -u
+const DefaultDiffContext = 3
+
+// Edit replaces whole lines of a document
+type Edit struct {
+	Line    int      // the first line replaced
+	Old     []string // the lines replaced
+	New     []string // the lines that replace them
+	Matches int      // replacements made in these lines
+}
+
+// Hunk is a group of edits close enough to share their context. Hunks
+// are shown, and turned on or off, as one.
+type Hunk struct {
+	Edits   []Edit
+	Enabled bool
+}
+
+// DiffOp is what a line of a diff does
+type DiffOp int
+
+const (
+	DiffContext DiffOp = iota // unchanged
+	DiffRemoved
+	DiffAdded
+)
+
+// DiffLine is a line of a unified diff
+type DiffLine struct {
+	Op   DiffOp
+	Line int // line number in the old document, or in the new for added lines
+	Text string
+}
+
+// FilePatch is the replacements planned in one file of the workspace
+type FilePatch struct {
+	Path    string // absolute path
+	Rel     string // path relative to the workspace root, with forward slashes
+	Lines   []string
+	CRLF    bool // the file's lines end with \r\n
+	Context int  // unchanged lines shown around each hunk
+	Hunks   []Hunk
+}
+
+// NewFilePatch groups the edits of the document lines into hunks, all
+// enabled. Edits must be sorted and must not overlap.
+func NewFilePatch(path, rel string, lines []string, edits []Edit, context int) *FilePatch {
+	p := &FilePatch{Path: path, Rel: rel, Lines: lines, Context: context}
+	for _, edit := range edits {
+		if n := len(p.Hunks); n > 0 {
+			last := p.Hunks[n-1].Edits
+			prev := last[len(last)-1]
+			if edit.Line-(prev.Line+len(prev.Old)) <= 2*context {
+				p.Hunks[n-1].Edits = append(last, edit)
+				continue
+			}
+		}
+		p.Hunks = append(p.Hunks, Hunk{Edits: []Edit{edit}, Enabled: true})
+	}
+	return p
+}
+
+// Edits returns the edits of the enabled hunks
+func (p *FilePatch) Edits() []Edit {
+	var edits []Edit
+	for _, h := range p.Hunks {
+		if h.Enabled {
+			edits = append(edits, h.Edits...)
+		}
+	}
+	return edits
+}
+
+// Matches counts the replacements in the enabled hunks and in all of them
+func (p *FilePatch) Matches() (enabled, total int) {
+	for _, h := range p.Hunks {
+		for _, edit := range h.Edits {
+			total += edit.Matches
+			if h.Enabled {
+				enabled += edit.Matches
+			}
+		}
+	}
+	return enabled, total
+}
+
+// Result returns the lines of the document with the enabled hunks applied
+func (p *FilePatch) Result() []string {
+	return ApplyEdits(p.Lines, p.Edits())
+}
+
+// length is the number of lines a diff may show. The empty line after a
+// final newline is not one.
+func (p *FilePatch) length() int {
+	n := len(p.Lines)
+	if n > 1 && p.Lines[n-1] == "" {
+		n--
+	}
+	return n
+}
+
+// Diff returns the header and lines of hunk h as in a unified diff. Line
+// numbers in the new document count only the enabled hunks before h.
+func (p *FilePatch) Diff(h int) (string, []DiffLine) {
+	hunk := p.Hunks[h]
+	first, last := hunk.Edits[0], hunk.Edits[len(hunk.Edits)-1]
+	start := max(first.Line-p.Context, 0)
+	end := max(min(last.Line+len(last.Old)+p.Context, p.length()), last.Line+len(last.Old))
+
+	offset := 0
+	for _, before := range p.Hunks[:h] {
+		if before.Enabled {
+			for _, edit := range before.Edits {
+				offset += len(edit.New) - len(edit.Old)
+			}
+		}
+	}
+
+	var lines []DiffLine
+	oldLine, newLine := start, start+offset
+	context := func(to int) {
+		for ; oldLine < to; oldLine++ {
+			lines = append(lines, DiffLine{Op: DiffContext, Line: oldLine, Text: p.Lines[oldLine]})
+			newLine++
+		}
+	}
+	for _, edit := range hunk.Edits {
+		context(edit.Line)
+		for _, text := range edit.Old {
+			lines = append(lines, DiffLine{Op: DiffRemoved, Line: oldLine, Text: text})
+			oldLine++
+		}
+		for _, text := range edit.New {
+			lines = append(lines, DiffLine{Op: DiffAdded, Line: newLine, Text: text})
+			newLine++
+		}
+	}
+	context(end)
+
+	header := fmt.Sprintf("@@ -%s +%s @@", diffRange(start, end-start), diffRange(start+offset, newLine-start-offset))
+	return header, lines
+}
+
+// diffRange writes the start and length of a hunk side as diff -u does
+func diffRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+// ApplyEdits returns lines with the edits made. Edits must be sorted and
+// must not overlap.
+func ApplyEdits(lines []string, edits []Edit) []string {
+	result := make([]string, 0, len(lines))
+	pos := 0
+	for _, edit := range edits {
+		result = append(result, lines[pos:edit.Line]...)
+		result = append(result, edit.New...)
+		pos = edit.Line + len(edit.Old)
+	}
+	return append(result, lines[pos:]...)
+}
+
+// InvertEdits returns the edits that undo edits, in the lines they made
+func InvertEdits(edits []Edit) []Edit {
+	inverse := make([]Edit, len(edits))
+	offset := 0
+	for i, edit := range edits {
+		inverse[i] = Edit{Line: edit.Line + offset, Old: edit.New, New: edit.Old, Matches: edit.Matches}
+		offset += len(edit.New) - len(edit.Old)
+	}
+	return inverse
+}
+
+// replaceEdits turns the matches of re in lines into edits that replace
+// each with template. Matches sharing a line become one edit.
+func replaceEdits(re *regexp.Regexp, opts Options, template string, lines []string, matches []Result) []Edit {
+	var edits []Edit
+	for i := 0; i < len(matches); {
+		// The matches whose lines touch the first
+		first, last := matches[i].Line, matches[i].EndLine
+		j := i + 1
+		for j < len(matches) && matches[j].Line <= last {
+			last = max(last, matches[j].EndLine)
+			j++
+		}
+
+		// Offsets of the lines in their text
+		old := lines[first : last+1]
+		starts := make([]int, len(old))
+		for k := 1; k < len(old); k++ {
+			starts[k] = starts[k-1] + len(old[k-1]) + 1
+		}
+		text := strings.Join(old, "\n")
+
+		var b strings.Builder
+		pos := 0
+		for _, m := range matches[i:j] {
+			from := starts[m.Line-first] + m.Column
+			to := starts[m.EndLine-first] + m.EndColumn
+			b.WriteString(text[pos:from])
+			b.WriteString(expand(re, opts, m, template))
+			pos = to
+		}
+		b.WriteString(text[pos:])
+
+		if replaced := strings.Split(b.String(), "\n"); !slices.Equal(replaced, old) {
+			edits = append(edits, Edit{Line: first, Old: old, New: replaced, Matches: j - i})
+		}
+		i = j
+	}
+	return edits
+}
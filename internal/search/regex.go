@@ -1 +1,134 @@
 package search
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Options change how the query matches
+type Options struct {
+	Regex         bool // the query is a regular expression in Go's RE2 syntax
+	CaseSensitive bool
+	SmartCase     bool // ignore case unless the query has an upper case letter
+	WholeWord     bool // matches start and end at word boundaries
+	Multiline     bool // matches may span lines, as with \n in a regex
+	PreserveCase  bool // replacements take the case of the text they replace
+}
+
+// compile turns a query into the regexp that finds it. Plain text is
+// quoted, so every search runs through the same matcher.
+func compile(query string, opts Options) (*regexp.Regexp, error) {
+	pattern := query
+	if opts.Regex {
+		// Check the query alone so errors quote what was typed
+		if _, err := regexp.Compile(query); err != nil {
+			return nil, err
+		}
+	} else {
+		pattern = regexp.QuoteMeta(query)
+	}
+	if opts.WholeWord {
+		pattern = `\b(?:` + pattern + `)\b`
+	}
+
+	flags := "m"
+	if !opts.CaseSensitive && !(opts.SmartCase && hasUpper(query, opts.Regex)) {
+		flags += "i"
+	}
+	return regexp.Compile("(?" + flags + ")" + pattern)
+}
+
+// hasUpper reports whether s has an upper case letter. Escapes such as \S
+// and \W in a regex are not letters of the query, so they do not count.
+func hasUpper(s string, regex bool) bool {
+	for i, r := range s {
+		if regex && i > 0 && s[i-1] == '\\' {
+			continue
+		}
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// findAll finds the matches of re in the lines of a document
+func findAll(re *regexp.Regexp, lines []string, multiline bool) []Result {
+	if multiline {
+		return matchText(re, lines)
+	}
+	var results []Result
+	for i, line := range lines {
+		results = append(results, matchLine(re, i, line)...)
+	}
+	return results
+}
+
+// matchLine finds the matches of re in line lineNum of a document
+func matchLine(re *regexp.Regexp, lineNum int, line string) []Result {
+	var results []Result
+	for _, m := range re.FindAllStringSubmatchIndex(line, -1) {
+		if m[0] == m[1] {
+			continue
+		}
+		results = append(results, Result{
+			Line:      lineNum,
+			Column:    m[0],
+			Length:    m[1] - m[0],
+			EndLine:   lineNum,
+			EndColumn: m[1],
+			Groups:    groups(line, m),
+		})
+	}
+	return results
+}
+
+// matchText finds the matches of re in a whole document, for patterns
+// that may span lines. Columns are byte offsets into each line.
+func matchText(re *regexp.Regexp, lines []string) []Result {
+	text := strings.Join(lines, "\n")
+
+	// Offset of the start of each line in text
+	starts := make([]int, len(lines))
+	offset := 0
+	for i, line := range lines {
+		starts[i] = offset
+		offset += len(line) + 1
+	}
+	position := func(offset int) (int, int) {
+		line := sort.Search(len(starts), func(i int) bool { return starts[i] > offset }) - 1
+		return line, offset - starts[line]
+	}
+
+	var results []Result
+	for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+		if m[0] == m[1] {
+			continue
+		}
+		line, col := position(m[0])
+		endLine, endCol := position(m[1])
+		results = append(results, Result{
+			Line:      line,
+			Column:    col,
+			Length:    m[1] - m[0],
+			EndLine:   endLine,
+			EndColumn: endCol,
+			Groups:    groups(text, m),
+		})
+	}
+	return results
+}
+
+// groups returns the text of each capture group of a match, "" for groups
+// that did not take part
+func groups(text string, m []int) []string {
+	g := make([]string, len(m)/2)
+	for i := range g {
+		if m[2*i] >= 0 {
+			g[i] = text[m[2*i]:m[2*i+1]]
+		}
+	}
+	return g
+}
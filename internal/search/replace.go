@@ -1,70 +1,74 @@
 package search
 
 import (
+	"regexp"
 	"strings"
+	"unicode"
 
 	"github.com/tobibamidele/minra/internal/buffer"
 )
 
-// Replace replaces all search results with replacement text
-func Replace(
-	buf *buffer.Buffer,
-	pattern,
-	replacement string,
-	caseSensitive bool,
-) int {
-	count := 0
-
-	searchPattern := pattern
-	if !caseSensitive {
-		searchPattern = strings.ToLower(pattern)
-	}
+// Replacement returns the text that replaces r. In regex mode $1, ${1}
+// and ${name} in template stand for capture groups and $$ for a dollar
+// sign; otherwise template is taken as it is. With PreserveCase the
+// replacement follows the case of the text it replaces.
+func (e *Engine) Replacement(r Result, template string) string {
+	return expand(e.compiled(), e.options, r, template)
+}
 
-	for lineNum := 0; lineNum < buf.LineCount(); lineNum++ {
-		line := buf.Line(lineNum)
-		searchLine := line
-		if !caseSensitive {
-			searchLine = strings.ToLower(searchLine)
+// expand returns the text that replaces r, a match of re found with opts
+func expand(re *regexp.Regexp, opts Options, r Result, template string) string {
+	text := template
+	if re != nil && opts.Regex && len(r.Groups) > 0 {
+		// Rebuild the match from its groups for regexp's expansion
+		var src strings.Builder
+		match := make([]int, 0, 2*len(r.Groups))
+		for _, g := range r.Groups {
+			match = append(match, src.Len(), src.Len()+len(g))
+			src.WriteString(g)
 		}
+		text = string(re.ExpandString(nil, template, src.String(), match))
+	}
+	if opts.PreserveCase && len(r.Groups) > 0 {
+		text = MatchCase(text, r.Groups[0])
+	}
+	return text
+}
 
-		if strings.Contains(searchLine, searchPattern) {
-			newLine := ""
-			if caseSensitive {
-				newLine = strings.ReplaceAll(line, pattern, replacement)
-			} else {
-				// Case insensitive replacement
-				newLine = replaceInsensitive(line, pattern, replacement)
-			}
+// MatchCase gives replacement the case of original: upper case if it is
+// all upper case, lower case if it is all lower case, and a capital first
+// letter if only its first letter is one. Mixed case is left alone.
+func MatchCase(replacement, original string) string {
+	upper, lower := strings.ToUpper(original), strings.ToLower(original)
+	switch {
+	case upper == lower:
+		return replacement
+	case original == upper:
+		return strings.ToUpper(replacement)
+	case original == lower:
+		return strings.ToLower(replacement)
+	}
 
-			if newLine != line {
-				buf.SetLine(lineNum, newLine)
-				count++
-			}
+	runes := []rune(original)
+	if unicode.IsUpper(runes[0]) && string(runes[1:]) == strings.ToLower(string(runes[1:])) {
+		r := []rune(replacement)
+		if len(r) > 0 {
+			r[0] = unicode.ToUpper(r[0])
 		}
+		return string(r)
 	}
-
-	return count
+	return replacement
 }
 
-func replaceInsensitive(text, pattern, replacement string) string {
-	lowerText := strings.ToLower(text)
-	lowerPattern := strings.ToLower(pattern)
-
-	result := ""
-	lastIdx := 0
+// ReplaceAt replaces the text of r in buf with text and returns where the
+// replacement ends
+func ReplaceAt(buf *buffer.Buffer, r Result, text string) (line, col int) {
+	buf.DeleteRange(r.Line, r.Column, r.EndLine, r.EndColumn)
+	buf.InsertText(r.Line, r.Column, text)
 
-	for {
-		idx := strings.Index(lowerText[lastIdx:], lowerPattern)
-		if idx == -1 {
-			result += text[lastIdx:]
-			break
-		}
-
-		actualIdx := lastIdx + idx
-		result := text[lastIdx:actualIdx]
-		result += replacement
-		lastIdx = actualIdx + len(pattern)
+	lines := strings.Split(text, "\n")
+	if len(lines) == 1 {
+		return r.Line, r.Column + len(text)
 	}
-
-	return result
+	return r.Line + len(lines) - 1, len(lines[len(lines)-1])
 }
@@ -1,90 +1,136 @@
 package search
 
 import (
-	"strings"
+	"regexp"
 
 	"github.com/tobibamidele/minra/internal/buffer"
 )
 
-// Result represents a search result
+// Result represents a search result. A match that spans lines ends on
+// EndLine; Length counts the bytes of the match, line breaks included.
 type Result struct {
-	Line   int
-	Column int
-	Length int
+	Line      int
+	Column    int
+	Length    int
+	EndLine   int
+	EndColumn int
+	Groups    []string // the whole match, then each capture group
 }
 
 // Engine performs text search
 type Engine struct {
-	query         string
-	caseSensitive bool
-	regex         bool
-	results       []Result
-	currentIdx    int
+	query      string
+	options    Options
+	pattern    *regexp.Regexp // compiled query, nil until needed or if invalid
+	err        error          // why the query does not compile
+	results    []Result
+	currentIdx int
 }
 
 // NewEngine creates a new search engine
 func NewEngine() *Engine {
 	return &Engine{
-		caseSensitive: false,
-		regex:         false,
-		results:       make([]Result, 0),
-		currentIdx:    -1,
+		results:    make([]Result, 0),
+		currentIdx: -1,
 	}
 }
 
 // SetQuery sets the search query
 func (e *Engine) SetQuery(query string) {
 	e.query = query
-	e.results = make([]Result, 0)
-	e.currentIdx = -1
+	e.reset()
 }
 
 // SetCaseSensitive sets case sensitivity
 func (e *Engine) SetCaseSensitive(sensitive bool) {
-	e.caseSensitive = sensitive
+	e.options.CaseSensitive = sensitive
+	e.reset()
+}
+
+// SetRegex sets whether the query is a regular expression
+func (e *Engine) SetRegex(regex bool) {
+	e.options.Regex = regex
+	e.reset()
+}
+
+// SetOptions sets every search option at once
+func (e *Engine) SetOptions(opts Options) {
+	e.options = opts
+	e.reset()
+}
+
+// Options returns the search options
+func (e *Engine) Options() Options {
+	return e.options
+}
+
+// reset drops the results and the compiled query after a change
+func (e *Engine) reset() {
+	e.pattern = nil
+	e.err = nil
+	e.results = make([]Result, 0)
+	e.currentIdx = -1
+}
+
+// compiled returns the query as a regexp, compiling it on first use
+func (e *Engine) compiled() *regexp.Regexp {
+	if e.pattern == nil && e.err == nil && e.query != "" {
+		e.pattern, e.err = compile(e.query, e.options)
+	}
+	return e.pattern
+}
+
+// Err returns why the query is not a valid regular expression, or nil
+func (e *Engine) Err() error {
+	e.compiled()
+	return e.err
 }
 
 // Search performs search on buffer
 func (e *Engine) Search(buf *buffer.Buffer) []Result {
 	e.results = make([]Result, 0)
+	e.currentIdx = -1
 
-	if e.query == "" {
+	re := e.compiled()
+	if re == nil {
 		return e.results
 	}
 
-	searchQuery := e.query
-	if !e.caseSensitive {
-		searchQuery = strings.ToLower(searchQuery)
+	e.results = findAll(re, buf.Lines(), e.options.Multiline)
+
+	if len(e.results) > 0 {
+		e.currentIdx = 0
 	}
 
-	for lineNum := 0; lineNum < buf.LineCount(); lineNum++ {
-		line := buf.Line(lineNum)
-		searchLine := line
-		if !e.caseSensitive {
-			searchLine = strings.ToLower(searchLine)
-		}
+	return e.results
+}
 
-		col := 0
-		for {
-			idx := strings.Index(searchLine[col:], searchQuery)
-			if idx == -1 {
-				break
-			}
+// MatchLine finds the query in one line of text, which is line lineNum of
+// the document. It does not change the engine's results. Multi-line
+// searches are answered from the results of the last Search, as a line
+// alone cannot tell where they match.
+func (e *Engine) MatchLine(lineNum int, line string) []Result {
+	re := e.compiled()
+	if re == nil {
+		return nil
+	}
 
-			e.results = append(e.results, Result{
-				Line:   lineNum,
-				Column: col + idx,
-				Length: len(e.query),
-			})
-			col += idx + 1
+	if e.options.Multiline {
+		var results []Result
+		for _, r := range e.results {
+			if r.Line <= lineNum && lineNum <= r.EndLine {
+				results = append(results, r)
+			}
 		}
+		return results
 	}
 
-	if len(e.results) > 0 {
-		e.currentIdx = 0
-	}
+	return matchLine(re, lineNum, line)
+}
 
-	return e.results
+// Query returns the current search query
+func (e *Engine) Query() string {
+	return e.query
 }
 
 // Next returns the next result
@@ -107,6 +153,45 @@ func (e *Engine) Previous() *Result {
 	return &e.results[e.currentIdx]
 }
 
+// Seek makes the first result at or after line and col the current one,
+// or with backward the last result before them. Past either end of the
+// buffer it wraps around, and reports that it did.
+func (e *Engine) Seek(line, col int, backward bool) (result *Result, wrapped bool) {
+	if len(e.results) == 0 {
+		return nil, false
+	}
+
+	before := func(r Result) bool {
+		return r.Line < line || r.Line == line && r.Column < col
+	}
+	if backward {
+		e.currentIdx = len(e.results) - 1
+		wrapped = true
+		for i := len(e.results) - 1; i >= 0; i-- {
+			if before(e.results[i]) {
+				e.currentIdx, wrapped = i, false
+				break
+			}
+		}
+	} else {
+		e.currentIdx = 0
+		wrapped = true
+		for i, r := range e.results {
+			if !before(r) {
+				e.currentIdx, wrapped = i, false
+				break
+			}
+		}
+	}
+	return &e.results[e.currentIdx], wrapped
+}
+
+// Index returns the position of the current result counting from 1, or 0
+// if there is none
+func (e *Engine) Index() int {
+	return e.currentIdx + 1
+}
+
 // Current returns the current result
 func (e *Engine) Current() *Result {
 	if e.currentIdx < 0 || e.currentIdx >= len(e.results) {
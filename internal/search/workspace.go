@@ -0,0 +1,282 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/tobibamidele/minra/pkg/fileio"
+)
+
+// Defaults of a workspace search
+const (
+	DefaultContextLines = 2
+	DefaultMaxFileSize  = 1 << 20 // larger files are skipped
+)
+
+// binarySniffLen is how much of a file is checked for NUL bytes, which
+// mark it as binary, the way git decides
+const binarySniffLen = 8000
+
+// FileResult is the matches found in one file of the workspace
+type FileResult struct {
+	Path    string // absolute path
+	Rel     string // path relative to the workspace root, with forward slashes
+	Matches []FileMatch
+}
+
+// FileMatch is a match with the lines around it
+type FileMatch struct {
+	Result
+	Text   string   // the line the match starts on
+	Before []string // context lines before Text, the nearest last
+	After  []string // context lines after the line the match ends on
+}
+
+// Workspace searches the files under a directory, skipping those ignored
+// by .gitignore and .ignore files, binary files and huge files
+type Workspace struct {
+	Root        string
+	Context     int                 // lines of context around each match
+	MaxFileSize int64               // 0 for DefaultMaxFileSize
+	Workers     int                 // files searched at once, 0 for one per CPU
+	Buffers     map[string][]string // lines of open files by path, searched instead of the disk
+}
+
+// NewWorkspace returns a search of the files under root with the default
+// settings
+func NewWorkspace(root string) *Workspace {
+	return &Workspace{Root: root, Context: DefaultContextLines}
+}
+
+// walkedFile is a file found by the walk, waiting to be searched
+type walkedFile struct {
+	path, rel string
+}
+
+// Search looks for query in every file of the workspace. Each file's
+// matches are sent as soon as it has been searched, so results arrive in
+// no particular order. The channel is closed when the search ends, either
+// because every file was searched or because ctx was cancelled.
+func (w *Workspace) Search(ctx context.Context, query string, opts Options) (<-chan FileResult, error) {
+	re, err := w.compile(query, opts)
+	if err != nil {
+		return nil, err
+	}
+	return scan(ctx, w, re, opts, func(f walkedFile, doc Document, matches []Result) FileResult {
+		return FileResult{Path: f.path, Rel: f.rel, Matches: w.withContext(doc.Lines, matches)}
+	}), nil
+}
+
+// Replace plans the replacement of every match of query with template,
+// sending a patch for each file with matches the way Search sends their
+// matches. Nothing is changed until the patches are applied. In regex
+// mode the template may refer to capture groups as $1 or ${name}.
+func (w *Workspace) Replace(ctx context.Context, query, template string, opts Options) (<-chan *FilePatch, error) {
+	re, err := w.compile(query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	patches := make(chan *FilePatch)
+	go func() {
+		defer close(patches)
+		results := scan(ctx, w, re, opts, func(f walkedFile, doc Document, matches []Result) *FilePatch {
+			edits := replaceEdits(re, opts, template, doc.Lines, matches)
+			if len(edits) == 0 {
+				return nil
+			}
+			p := NewFilePatch(f.path, f.rel, doc.Lines, edits, DefaultDiffContext)
+			p.CRLF = doc.CRLF
+			return p
+		})
+		for p := range results {
+			if p == nil {
+				continue
+			}
+			select {
+			case patches <- p:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return patches, nil
+}
+
+// fileBatch is how many paths Files sends at once
+const fileBatch = 512
+
+// Files lists the files of the workspace that are not ignored, as paths
+// relative to the root with forward slashes. They are sent in batches as
+// the walk finds them, and the channel is closed when it ends.
+func (w *Workspace) Files(ctx context.Context) <-chan []string {
+	batches := make(chan []string)
+	go func() {
+		defer close(batches)
+		var batch []string
+		send := func() error {
+			select {
+			case batches <- batch:
+				batch = nil
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		err := fileio.Walk(ctx, w.Root, func(path, rel string, info fs.FileInfo) error {
+			batch = append(batch, rel)
+			if len(batch) == fileBatch {
+				return send()
+			}
+			return nil
+		})
+		if err == nil && len(batch) > 0 {
+			send()
+		}
+	}()
+	return batches
+}
+
+// compile checks the query of a search of the workspace
+func (w *Workspace) compile(query string, opts Options) (*regexp.Regexp, error) {
+	if query == "" {
+		return nil, errors.New("empty search")
+	}
+	return compile(query, opts)
+}
+
+// scan runs re over the files of the workspace in parallel, sending what
+// result makes of each file with matches
+func scan[T any](ctx context.Context, w *Workspace, re *regexp.Regexp, opts Options, result func(walkedFile, Document, []Result) T) <-chan T {
+	maxSize := w.MaxFileSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxFileSize
+	}
+	workers := w.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	files := make(chan walkedFile)
+	go func() {
+		defer close(files)
+		fileio.Walk(ctx, w.Root, func(path, rel string, info fs.FileInfo) error {
+			if _, open := w.Buffers[path]; !open && info.Size() > maxSize {
+				return nil
+			}
+			select {
+			case files <- walkedFile{path, rel}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+
+	results := make(chan T)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range files {
+				if ctx.Err() != nil {
+					continue
+				}
+				doc, ok := w.document(f.path)
+				if !ok {
+					continue
+				}
+				matches := findAll(re, doc.Lines, opts.Multiline)
+				if len(matches) == 0 {
+					continue
+				}
+				select {
+				case results <- result(f, doc, matches):
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// Document is the text of a file as lines, split the way buffers split
+// it: a file ending in a newline has an empty last line
+type Document struct {
+	Lines []string
+	CRLF  bool // the lines ended with \r\n, which is not part of Lines
+}
+
+// ReadDocument reads the file at path. Binary files are refused.
+func ReadDocument(path string) (Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Document{}, err
+	}
+	if bytes.IndexByte(data[:min(len(data), binarySniffLen)], 0) >= 0 {
+		return Document{}, errors.New("binary file")
+	}
+
+	lines := strings.Split(string(data), "\n")
+	crlf := len(lines) > 1
+	for _, line := range lines[:len(lines)-1] {
+		crlf = crlf && strings.HasSuffix(line, "\r")
+	}
+	if crlf {
+		for i, line := range lines {
+			lines[i] = strings.TrimSuffix(line, "\r")
+		}
+	}
+	return Document{Lines: lines, CRLF: crlf}, nil
+}
+
+// Content joins the lines of the document back into its file
+func (d Document) Content() string {
+	if d.CRLF {
+		return strings.Join(d.Lines, "\r\n")
+	}
+	return strings.Join(d.Lines, "\n")
+}
+
+// document returns the file at path, from its buffer if it is open
+func (w *Workspace) document(path string) (Document, bool) {
+	if lines, ok := w.Buffers[path]; ok {
+		return Document{Lines: lines}, true
+	}
+	doc, err := ReadDocument(path)
+	return doc, err == nil
+}
+
+// withContext attaches the lines around each match
+func (w *Workspace) withContext(lines []string, results []Result) []FileMatch {
+	// The empty line after a final newline is no context
+	n := len(lines)
+	if n > 1 && lines[n-1] == "" {
+		n--
+	}
+
+	matches := make([]FileMatch, len(results))
+	for i, r := range results {
+		before := max(r.Line-w.Context, 0)
+		after := max(min(r.EndLine+1+w.Context, n), r.EndLine+1)
+		matches[i] = FileMatch{
+			Result: r,
+			Text:   lines[r.Line],
+			Before: lines[before:r.Line],
+			After:  lines[r.EndLine+1 : after],
+		}
+	}
+	return matches
+}
@@ -1,13 +1,28 @@
 package session
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 
+	"github.com/tobibamidele/minra/internal/buffer"
 	"github.com/tobibamidele/minra/internal/sidebar"
 )
 
+// ErrStaleUndo is returned when a saved undo history no longer matches the
+// file on disk
+var ErrStaleUndo = errors.New("file changed since undo history was saved")
+
+// UndoFile is the on-disk form of a buffer's undo history
+type UndoFile struct {
+	Path    string              `json:"path"`
+	Hash    string              `json:"hash"` // hash of the file content the history ends at
+	History buffer.HistoryState `json:"history"`
+}
+
 // Save saves session to a file
 func SaveSession(session *Session, path string) error {
 	// Ensure directory exists
@@ -64,3 +79,70 @@ func SaveUIState(
 	}
 	return os.WriteFile(path, []byte(sidebar.GetFileTreeState()), 0644)
 }
+
+// DefaultUndoPath returns the undo history file for a source file.
+// This is `$HOME/.minra/undo/{hash of absolute path}.json`
+func DefaultUndoPath(file string) string {
+	homeDir, _ := os.UserHomeDir()
+	absPath, err := filepath.Abs(file)
+	if err != nil {
+		absPath = file
+	}
+	sum := sha256.Sum256([]byte(absPath))
+	return filepath.Join(homeDir, ".minra", "undo", hex.EncodeToString(sum[:16])+".json")
+}
+
+// ContentHash returns the hash stored with an undo history
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// SaveUndoHistory saves buf's undo history. The buffer must match the file
+// on disk, so unsaved buffers and open transactions are skipped.
+func SaveUndoHistory(buf *buffer.Buffer, path string) error {
+	if buf.Filepath() == "" || buf.Modified() || buf.History().InTransaction() {
+		return nil
+	}
+
+	undo := UndoFile{
+		Path:    buf.Filepath(),
+		Hash:    ContentHash(buf.Content()),
+		History: buf.History().State(),
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(undo)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// LoadUndoHistory restores buf's undo history from path. If the file on
+// disk changed outside the editor the saved history is deleted and
+// ErrStaleUndo is returned.
+func LoadUndoHistory(buf *buffer.Buffer, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var undo UndoFile
+	if err := json.Unmarshal(data, &undo); err != nil {
+		return err
+	}
+
+	if undo.Hash != ContentHash(buf.Content()) {
+		os.Remove(path)
+		return ErrStaleUndo
+	}
+
+	buf.History().Restore(undo.History)
+	return nil
+}
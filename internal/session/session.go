@@ -9,8 +9,13 @@ type Session struct {
 		Width  int
 		Height int
 	}
+	Folds  map[string][]int `json:",omitempty"` // closed folds by file, as the lines they start on
+	Recent []string         `json:",omitempty"` // files opened, most recent first
 }
 
+// maxRecentFiles is how many opened files the session remembers
+const maxRecentFiles = 100
+
 // New creates a new session
 func New(workspace string) *Session {
 	return &Session{
@@ -44,3 +49,39 @@ func (s *Session) RemoveFile(filepath string) {
 func (s *Session) SetActiveFile(filepath string) {
 	s.ActiveFile = filepath
 }
+
+// AddRecentFile moves a file to the top of the recently opened files
+func (s *Session) AddRecentFile(filepath string) {
+	for i, f := range s.Recent {
+		if f == filepath {
+			s.Recent = append(s.Recent[:i], s.Recent[i+1:]...)
+			break
+		}
+	}
+	s.Recent = append([]string{filepath}, s.Recent...)
+	if len(s.Recent) > maxRecentFiles {
+		s.Recent = s.Recent[:maxRecentFiles]
+	}
+}
+
+// RecentFiles returns the recently opened files, most recent first
+func (s *Session) RecentFiles() []string {
+	return s.Recent
+}
+
+// SetClosedFolds records the closed folds of a file
+func (s *Session) SetClosedFolds(filepath string, lines []int) {
+	if len(lines) == 0 {
+		delete(s.Folds, filepath)
+		return
+	}
+	if s.Folds == nil {
+		s.Folds = make(map[string][]int)
+	}
+	s.Folds[filepath] = lines
+}
+
+// ClosedFolds returns the closed folds recorded for a file
+func (s *Session) ClosedFolds(filepath string) []int {
+	return s.Folds[filepath]
+}
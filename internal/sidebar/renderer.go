@@ -18,19 +18,19 @@ func (s *Sidebar) Render() string {
 	visibleLines := s.height - 2 // minus top & bottom borders
 
 	// Styles
-	selectedBg := lipgloss.Color("240")
-	selectedText := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("230")).
-		Background(selectedBg)
+	selectedBg := ui.Colors.SidebarSelected
+	selectedText := ui.Highlight(lipgloss.NewStyle().
+		Foreground(ui.Colors.SidebarSelectedForeground).
+		Background(selectedBg))
 
 	dirStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("39")).
-		Background(ui.ColorSidebar).
+		Foreground(ui.Colors.SidebarDirectory).
+		Background(ui.Colors.Sidebar).
 		Bold(true)
 
 	titleStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("205")).
-		Background(ui.ColorSidebar).
+		Foreground(ui.Colors.SidebarTitle).
+		Background(ui.Colors.Sidebar).
 		Bold(true).
 		Width(s.width - 2).
 		Align(lipgloss.Center)
@@ -60,11 +60,11 @@ func (s *Sidebar) Render() string {
 		var nameStyled string
 		if isSelected {
 			nameStyled = selectedText.Render(" " + node.Name)
-			iconStyle = iconStyle.Background(selectedBg)
+			iconStyle = ui.Highlight(iconStyle.Background(selectedBg))
 		} else if node.IsDir {
 			nameStyled = dirStyle.Render(" " + node.Name)
 		} else {
-			nameStyled = lipgloss.NewStyle().Background(ui.ColorSidebar).Render(" " + node.Name)
+			nameStyled = lipgloss.NewStyle().Foreground(ui.Colors.SidebarForeground).Background(ui.Colors.Sidebar).Render(" " + node.Name)
 		}
 
 		// join parts (indent + icon + filename)
@@ -82,7 +82,7 @@ func (s *Sidebar) Render() string {
 		if isSelected {
 			lineStyle = lineStyle.Background(selectedBg)
 		} else {
-			lineStyle = lineStyle.Background(ui.ColorSidebar)
+			lineStyle = lineStyle.Background(ui.Colors.Sidebar)
 		}
 
 		b.WriteString(lineStyle.Render(line) + "\n")
@@ -96,13 +96,13 @@ func (s *Sidebar) Render() string {
 	// border
 	borderStyle := lipgloss.NewStyle().
 		Border(lipgloss.NormalBorder()).
-		BorderForeground(lipgloss.Color("240")).
-		Background(ui.ColorSidebar).
+		BorderForeground(ui.Colors.Border).
+		Background(ui.Colors.Sidebar).
 		Width(s.width - 2).
 		Height(s.height - 2)
 
 	return lipgloss.NewStyle().
-		Background(ui.ColorBackground).
+		Background(ui.Colors.Background).
 		Render(borderStyle.Render(b.String()))
 }
 
@@ -1,6 +1,10 @@
 package sidebar
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+)
 
 // Sidebar represents the file browser sidebar
 type Sidebar struct {
@@ -39,6 +43,16 @@ func (s *Sidebar) Toggle() {
 	s.visible = !s.visible
 }
 
+// SetVisible shows or hides the sidebar
+func (s *Sidebar) SetVisible(visible bool) {
+	s.visible = visible
+}
+
+// SetWidth sets the sidebar width
+func (s *Sidebar) SetWidth(width int) {
+	s.width = width
+}
+
 // Width returns the sidebar width
 func (s *Sidebar) Width() int {
 	if !s.visible {
@@ -122,6 +136,47 @@ func (s *Sidebar) Refresh() error {
 	return nil
 }
 
+// SelectPath selects the node of the absolute path, expanding the
+// directories above it. It returns false if the path is not in the tree.
+func (s *Sidebar) SelectPath(path string) bool {
+	if s.tree == nil {
+		return false
+	}
+
+	node := s.tree.Root
+	for node.Path != path {
+		var next *FileNode
+		for _, child := range node.Children {
+			if child.Path == path || strings.HasPrefix(path, child.Path+string(filepath.Separator)) {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return false
+		}
+		if next.Path != path {
+			if len(next.Children) == 0 {
+				if err := loadDirectory(next); err != nil {
+					return false
+				}
+			}
+			next.Expanded = true
+		}
+		node = next
+	}
+	s.tree.rebuildFlatList()
+
+	for i, n := range s.tree.FlatList() {
+		if n == node {
+			s.selectedIndex = i
+			s.adjustScroll()
+			return true
+		}
+	}
+	return false
+}
+
 // adjustScroll adjusts the scroll offset to keep selection visible
 func (s *Sidebar) adjustScroll() {
 	visibleLines := s.height - 2 // Account for borders
@@ -166,8 +221,10 @@ func (s *Sidebar) restoreExpandedPaths(node *FileNode, expandedPaths map[string]
 
 		// Load Children if not loaded before
 		if len(node.Children) == 0 {
-			s.tree.ToggleExpanded(node) // load the children
-			node.Expanded = true        // Ensure children are expanded
+			if err := loadDirectory(node); err != nil {
+				node.Expanded = false
+				return
+			}
 		}
 
 		for _, child := range node.Children {
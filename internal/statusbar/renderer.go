@@ -4,18 +4,19 @@ import (
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/tobibamidele/minra/internal/ui"
 )
 
 // Render renders the status bar
 func (s *StatusBar) Render(width int, left, right string) string {
 	leftStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("230")).
-		Background(lipgloss.Color("240")).
+		Foreground(ui.Colors.StatusBarForeground).
+		Background(ui.Colors.StatusBar).
 		Padding(0, 1)
 
 	rightStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("230")).
-		Background(lipgloss.Color("240")).
+		Foreground(ui.Colors.StatusBarForeground).
+		Background(ui.Colors.StatusBar).
 		Padding(0, 1)
 
 	leftRendered := leftStyle.Render(left)
@@ -27,7 +28,7 @@ func (s *StatusBar) Render(width int, left, right string) string {
 	}
 
 	statusBar := lipgloss.NewStyle().
-		Background(lipgloss.Color("240")).
+		Background(ui.Colors.StatusBar).
 		Width(width).
 		Render(leftRendered + strings.Repeat(" ", gap) + rightRendered)
 
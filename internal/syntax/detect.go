@@ -0,0 +1,170 @@
+package syntax
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/tobibamidele/minra/internal/syntax/languages"
+)
+
+// Text is the content of a file whose language is being detected
+type Text interface {
+	Line(n int) string
+	LineCount() int
+}
+
+// modelineLines is how many lines at each end of a file are searched for
+// a modeline
+const modelineLines = 5
+
+// heuristicLines is how many lines from the top content heuristics read
+const heuristicLines = 20
+
+var (
+	vimModeline   = regexp.MustCompile(`\b(?:vi|vim|ex):.*?\b(?:ft|filetype|syn|syntax)=([\w+-]+)`)
+	emacsModeline = regexp.MustCompile(`-\*-.*?\bmode:\s*([\w+-]+)`)
+	emacsShort    = regexp.MustCompile(`-\*-\s*([\w+-]+)\s*-\*-`)
+)
+
+// aliases are other names modelines and :set filetype use for languages
+var aliases = map[string]string{
+	"golang":    "go",
+	"py":        "python",
+	"js":        "javascript",
+	"ts":        "typescript",
+	"md":        "markdown",
+	"yml":       "yaml",
+	"rs":        "rust",
+	"make":      "makefile",
+	"docker":    "dockerfile",
+	"terraform": "hcl",
+	"text":      "plain",
+	"txt":       "plain",
+}
+
+// heuristics recognize languages by how a line of the file starts, for
+// files with neither a known name nor a #! line
+var heuristics = []struct {
+	language string
+	line     *regexp.Regexp
+}{
+	{"go", regexp.MustCompile(`^package [a-z_][a-z0-9_]*\s*$`)},
+	{"java", regexp.MustCompile(`^(package [\w.]+;|import [\w.*]+;|public (final )?class )`)},
+	{"rust", regexp.MustCompile(`^(use \w+(::[\w{}*, ]+)*;|fn main\(\)|mod \w+;|#!\[)`)},
+	{"python", regexp.MustCompile(`^(def \w+\(|class \w+(\(.*\))?:|from [\w.]+ import |import \w+$|if __name__ == )`)},
+	{"javascript", regexp.MustCompile(`^(const|let|var) \w+ = require\(|^import .* from ['"]|^export (default|function|const) `)},
+	{"dockerfile", regexp.MustCompile(`^FROM \S+`)},
+	{"hcl", regexp.MustCompile(`^(resource|variable|provider|terraform|module) ("[^"]*" )*\{`)},
+	{"sql", regexp.MustCompile(`(?i)^(select .* from |create (table|view|index|database) |insert into |alter table )`)},
+	{"yaml", regexp.MustCompile(`^(%YAML|---\s*$)`)},
+	{"markdown", regexp.MustCompile(`^#{1,6} \S`)},
+}
+
+// Lookup returns the language called name, also accepting an extension
+// or a common alias such as "py"
+func (r *Registry) Lookup(name string) (languages.Language, bool) {
+	name = strings.ToLower(name)
+	if lang, ok := r.ByName(name); ok {
+		return lang, true
+	}
+	if alias, ok := aliases[name]; ok {
+		return r.ByName(alias)
+	}
+	if byExt, ok := r.byExtension["."+name]; ok {
+		return r.ByName(byExt)
+	}
+	return nil, false
+}
+
+// Detect picks the language of the file at path with content text. A
+// modeline naming the language wins, then the file name or extension,
+// then the interpreter on a #! line, then the way lines of the file
+// start. Files nothing matches are plain text.
+func (r *Registry) Detect(path string, text Text) languages.Language {
+	if lang, ok := r.fromModeline(text); ok {
+		return lang
+	}
+	if path != "" {
+		if lang, ok := r.ForPath(path); ok {
+			return lang
+		}
+	}
+	if text.LineCount() > 0 {
+		if lang, ok := r.fromShebang(text.Line(0)); ok {
+			return lang
+		}
+	}
+	if lang, ok := r.fromContent(text); ok {
+		return lang
+	}
+	return languages.NewPlain()
+}
+
+// fromModeline reads a vim or emacs modeline near the top or bottom
+func (r *Registry) fromModeline(text Text) (languages.Language, bool) {
+	n := text.LineCount()
+	for i := 0; i < n; i++ {
+		if i == modelineLines && n-modelineLines > i {
+			i = n - modelineLines
+		}
+		line := text.Line(i)
+		for _, re := range []*regexp.Regexp{vimModeline, emacsModeline, emacsShort} {
+			if m := re.FindStringSubmatch(line); m != nil {
+				if lang, ok := r.Lookup(m[1]); ok {
+					return lang, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// fromShebang finds the interpreter named by a #! line, looking through
+// env and dropping version numbers if the exact name is unknown
+func (r *Registry) fromShebang(line string) (languages.Language, bool) {
+	if !strings.HasPrefix(line, "#!") {
+		return nil, false
+	}
+	fields := strings.Fields(line[2:])
+	if len(fields) == 0 {
+		return nil, false
+	}
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				interpreter = filepath.Base(f)
+				break
+			}
+		}
+	}
+
+	for interpreter != "" {
+		if lang, ok := r.ForShebang(interpreter); ok {
+			return lang, true
+		}
+		trimmed := strings.TrimRight(interpreter, "0123456789.")
+		if trimmed == interpreter {
+			break
+		}
+		interpreter = trimmed
+	}
+	return nil, false
+}
+
+// fromContent tries the heuristics on the first lines of the file
+func (r *Registry) fromContent(text Text) (languages.Language, bool) {
+	for i := 0; i < min(text.LineCount(), heuristicLines); i++ {
+		line := text.Line(i)
+		for _, h := range heuristics {
+			if h.line.MatchString(line) {
+				if lang, ok := r.ByName(h.language); ok {
+					return lang, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
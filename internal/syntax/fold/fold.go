@@ -0,0 +1,124 @@
+// Package fold finds the blocks of a file that can be folded away
+package fold
+
+import (
+	"sort"
+
+	"github.com/tobibamidele/minra/internal/syntax/matchers"
+	"github.com/tobibamidele/minra/internal/syntax/tree"
+)
+
+// Range is a foldable block of lines, Start to End inclusive. When it is
+// closed Start stays on screen and the lines after it are hidden.
+type Range struct {
+	Start int
+	End   int
+}
+
+// Methods are the ways folds can be found
+var Methods = []string{"syntax", "indent", "bracket"}
+
+// Syntax returns the declarations and blocks of a parse tree
+func Syntax(t *tree.Tree) []Range {
+	var ranges []Range
+	for _, r := range t.FoldRanges() {
+		ranges = append(ranges, Range{Start: r.StartLine, End: r.EndLine})
+	}
+	return ranges
+}
+
+// Indent returns a fold for every line followed by lines indented deeper
+// than it. Blank lines inside a block belong to it; those after its last
+// line do not.
+func Indent(text matchers.LineReader, tabSize int) []Range {
+	if tabSize < 1 {
+		tabSize = 4
+	}
+	n := text.LineCount()
+	indents := make([]int, n)
+	for i := range indents {
+		indents[i] = indentOf(text.Line(i), tabSize)
+	}
+
+	var ranges []Range
+	for i := 0; i < n; i++ {
+		if indents[i] < 0 {
+			continue
+		}
+		end := i
+		for j := i + 1; j < n; j++ {
+			if indents[j] < 0 {
+				continue
+			}
+			if indents[j] <= indents[i] {
+				break
+			}
+			end = j
+		}
+		if end > i {
+			ranges = append(ranges, Range{Start: i, End: end})
+		}
+	}
+	return ranges
+}
+
+// indentOf returns the width of the leading whitespace of line, or -1 if
+// the line is blank
+func indentOf(line string, tabSize int) int {
+	width := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			width++
+		case '\t':
+			width += tabSize - width%tabSize
+		case '\r':
+		default:
+			return width
+		}
+	}
+	return -1
+}
+
+// Brackets returns a fold for every bracket pair that spans lines, from
+// the line of the open bracket to the line of the close bracket. Lines
+// with several open brackets fold to the furthest close.
+func Brackets(text matchers.LineReader) []Range {
+	ends := make(map[int]int)
+	for line := 0; line < text.LineCount(); line++ {
+		s := text.Line(line)
+		for col := 0; col < len(s); col++ {
+			switch s[col] {
+			case '(', '[', '{':
+			default:
+				continue
+			}
+			closeLine, _, ok := matchers.FindMatchingBracketAcross(text, line, col)
+			if ok && closeLine > line && closeLine > ends[line] {
+				ends[line] = closeLine
+			}
+		}
+	}
+
+	ranges := make([]Range, 0, len(ends))
+	for start, end := range ends {
+		ranges = append(ranges, Range{Start: start, End: end})
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+	return ranges
+}
+
+// At returns the innermost range containing line, or false
+func At(ranges []Range, line int) (Range, bool) {
+	var found Range
+	ok := false
+	for _, r := range ranges {
+		if r.Start > line {
+			break
+		}
+		if line <= r.End && (!ok || r.Start >= found.Start) {
+			found, ok = r, true
+		}
+	}
+	return found, ok
+}
@@ -1,42 +1,114 @@
 package syntax
 
 import (
+	"github.com/charmbracelet/lipgloss"
 	"github.com/tobibamidele/minra/internal/syntax/languages"
+	"github.com/tobibamidele/minra/internal/syntax/tree"
 )
 
+// Document is text being highlighted along with its cache of lexer states
+// at the start of each line. buffer.Buffer implements it.
+type Document interface {
+	Line(n int) string
+	LineCount() int
+	LineState(key string, line int) (int, bool)
+	CachedLineStates(key string) int
+	SetLineState(key string, line, state int)
+}
+
 // Highlighter provides syntax highlighting
 type Highlighter struct {
 	language languages.Language
+	theme    *Theme
+	tree     *tree.Tree
 }
 
 // New creates a new highlighter
 func New() *Highlighter {
 	return &Highlighter{
 		language: languages.NewPlain(),
+		theme:    DefaultTheme(),
 	}
 }
 
-// ForExtension returns highlighter for file extension
-func (h *Highlighter) ForExtension(ext string) *Highlighter {
-	switch ext {
-	case ".go":
-		h.language = languages.NewGo()
-	case ".py":
-		h.language = languages.NewPython()
-	case ".js", ".jsx":
-		h.language = languages.NewJavaScript()
-	case ".ts", ".tsx":
-		h.language = languages.NewTypeScript()
-	default:
-		h.language = languages.NewPlain()
+// SetTheme changes the colors used for highlighting
+func (h *Highlighter) SetTheme(theme *Theme) {
+	h.theme = theme
+}
+
+// Theme returns the colors used for highlighting
+func (h *Highlighter) Theme() *Theme {
+	return h.theme
+}
+
+// SetTree sets the parse tree of the document being highlighted, which
+// colors declared names the lexer cannot tell apart
+func (h *Highlighter) SetTree(t *tree.Tree) {
+	h.tree = t
+}
+
+// Language returns the language being highlighted
+func (h *Highlighter) Language() languages.Language {
+	return h.language
+}
+
+// SetLanguage changes the language being highlighted
+func (h *Highlighter) SetLanguage(lang languages.Language) {
+	h.language = lang
+}
+
+// Tokens returns the tokens of line n of doc. The lexer resumes from the
+// closest line above n with a cached state, caching the states it passes
+// so the next call starts where this one ended.
+func (h *Highlighter) Tokens(doc Document, n int) []languages.Token {
+	if h.language == nil || n < 0 || n >= doc.LineCount() {
+		return nil
+	}
+	if _, plain := h.language.(*languages.Plain); plain {
+		return nil
+	}
+
+	key := h.language.Name()
+	line := min(doc.CachedLineStates(key)-1, n)
+	var state languages.State
+	if line < 0 {
+		line = 0
+		doc.SetLineState(key, 0, 0)
+	} else {
+		s, _ := doc.LineState(key, line)
+		state = languages.State(s)
+	}
+
+	for ; line < n; line++ {
+		_, state = h.language.Tokenize(doc.Line(line), state)
+		doc.SetLineState(key, line+1, int(state))
+	}
+
+	tokens, next := h.language.Tokenize(doc.Line(n), state)
+	if n+1 < doc.LineCount() {
+		doc.SetLineState(key, n+1, int(next))
 	}
-	return h
+	// Later tokens win, so names from the tree override the lexer
+	return append(tokens, h.tree.Tokens(n)...)
 }
 
-// Highlight applies syntax highlighting to a line
-func (h *Highlighter) Highlight(line string) string {
-	if h.language == nil {
-		return line
+// Style returns the theme's style for a kind of token
+func (h *Highlighter) Style(kind languages.TokenKind) lipgloss.Style {
+	switch kind {
+	case languages.TokenKeyword:
+		return h.theme.Keyword
+	case languages.TokenType:
+		return h.theme.Type
+	case languages.TokenConstant:
+		return h.theme.Constant
+	case languages.TokenString:
+		return h.theme.String
+	case languages.TokenComment:
+		return h.theme.Comment
+	case languages.TokenNumber:
+		return h.theme.Number
+	case languages.TokenFunction:
+		return h.theme.Function
 	}
-	return h.language.Highlight(line)
+	return lipgloss.NewStyle()
 }
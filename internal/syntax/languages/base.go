@@ -1,123 +1,292 @@
 package languages
 
 import (
+	"regexp"
+	"sort"
 	"strings"
+	"unicode/utf8"
+)
+
+// TokenKind is the syntactic class of a token, which picks its color
+type TokenKind int
 
-	"github.com/charmbracelet/lipgloss"
+const (
+	TokenText TokenKind = iota
+	TokenKeyword
+	TokenType
+	TokenConstant
+	TokenString
+	TokenComment
+	TokenNumber
+	TokenFunction
 )
 
-// Language interface for syntax highlighting
+// Token is a highlighted span of a line, as buffer columns (byte offsets)
+// [Start, End)
+type Token struct {
+	Start int
+	End   int
+	Kind  TokenKind
+}
+
+// State is the lexer state at a line boundary. The zero state is the start
+// of a file; other states mean a construct such as a block comment is
+// still open.
+type State int
+
+// Language splits lines into tokens. Tokenize is given the state at the
+// start of the line and returns the state at its end, so constructs that
+// span lines are colored correctly.
 type Language interface {
-	Highlight(line string) string
+	Name() string
+	Tokenize(line string, state State) ([]Token, State)
+}
+
+// Region is a delimited span such as a string or block comment. Escape,
+// if set, makes the character after it part of the region. A region that
+// is not Multiline ends at the end of the line even when left open.
+type Region struct {
+	Start     string
+	End       string
+	Escape    byte
+	Multiline bool
+	Kind      TokenKind
+}
+
+// Pattern colors whatever its regular expression matches. Patterns run
+// on the whole line, so ^ is the start of the line, and are tried where
+// no comment or region starts.
+type Pattern struct {
+	Regexp *regexp.Regexp
+	Kind   TokenKind
+}
+
+// Structure names the keywords that open declarations, for the parse
+// tree. Indented languages open blocks with a trailing colon and close
+// them by dedenting.
+type Structure struct {
+	Functions []string
+	Types     []string
+	Indented  bool
+}
+
+// Rules describe a language for the rule-driven lexer in Base
+type Rules struct {
+	Keywords     []string
+	Types        []string
+	Constants    []string
+	LineComments []string
+	Regions      []Region
+	Patterns     []Pattern
+	IgnoreCase   bool // keywords, types and constants match in any case
+	Structure    Structure
 }
 
-// Base provides common highlighting utilities
+// Base lexes a line by its rules: comments and regions first, then words
+// and numbers
 type Base struct {
-	keywordStyle  lipgloss.Style
-	typeStyle     lipgloss.Style
-	constantStyle lipgloss.Style
-	stringStyle   lipgloss.Style
-	commentStyle  lipgloss.Style
-}
-
-func NewBase() *Base {
-	return &Base{
-		keywordStyle:  lipgloss.NewStyle().Foreground(lipgloss.Color("205")),
-		typeStyle:     lipgloss.NewStyle().Foreground(lipgloss.Color("117")),
-		constantStyle: lipgloss.NewStyle().Foreground(lipgloss.Color("141")),
-		stringStyle:   lipgloss.NewStyle().Foreground(lipgloss.Color("150")),
-		commentStyle:  lipgloss.NewStyle().Foreground(lipgloss.Color("243")),
+	words        map[string]TokenKind
+	lineComments []string
+	regions      []Region
+	patterns     []Pattern
+	ignoreCase   bool
+	structure    Structure
+}
+
+// NewBase creates a lexer for rules
+func NewBase(rules Rules) *Base {
+	b := &Base{
+		words:        make(map[string]TokenKind),
+		lineComments: rules.LineComments,
+		regions:      append([]Region(nil), rules.Regions...),
+		patterns:     rules.Patterns,
+		ignoreCase:   rules.IgnoreCase,
+		structure:    rules.Structure,
+	}
+	for _, words := range []struct {
+		list []string
+		kind TokenKind
+	}{{rules.Keywords, TokenKeyword}, {rules.Types, TokenType}, {rules.Constants, TokenConstant}} {
+		for _, w := range words.list {
+			if b.ignoreCase {
+				w = strings.ToLower(w)
+			}
+			b.words[w] = words.kind
+		}
 	}
+
+	// Longer delimiters first, so """ wins over "
+	sort.SliceStable(b.regions, func(i, j int) bool {
+		return len(b.regions[i].Start) > len(b.regions[j].Start)
+	})
+	return b
 }
 
-// HighlightWord highlights whole words
-func (b *Base) HighlightWord(text, word string, style lipgloss.Style) string {
-	inString := markStringRegions(text)
+// Structure returns the declaration keywords of the language
+func (b *Base) Structure() Structure {
+	return b.structure
+}
 
-	var result strings.Builder
+// Tokenize implements Language. Text that is not part of a token is left
+// out of the result.
+func (b *Base) Tokenize(line string, state State) ([]Token, State) {
+	var tokens []Token
 	i := 0
-	wordLen := len(word)
-
-	for i < len(text) {
-		// Skip highlighting if inside a string
-		if !inString[i] &&
-			i+wordLen <= len(text) &&
-			text[i:i+wordLen] == word &&
-			isBoundary(text, i-1) &&
-			isBoundary(text, i+wordLen) {
-
-			result.WriteString(style.Render(word))
-			i += wordLen
-		} else {
-			result.WriteByte(text[i])
-			i++
+	patterns := b.matchPatterns(line)
+
+	// Finish a region left open on an earlier line
+	if state > 0 && int(state) <= len(b.regions) {
+		r := b.regions[state-1]
+		end, closed := r.findEnd(line, 0)
+		if end > 0 {
+			tokens = append(tokens, Token{Start: 0, End: end, Kind: r.Kind})
+		}
+		if !closed {
+			return tokens, state
 		}
+		i = end
 	}
-	return result.String()
-}
-
-func markStringRegions(text string) []bool {
-	inString := false
-	quote := rune(0)
-	mark := make([]bool, len(text))
-
-	for i, ch := range text {
-		if !inString && (ch == '"' || ch == '\'') {
-			inString = true
-			quote = ch
-			mark[i] = true
-		} else if inString && ch == quote && (i == 0 || text[i-1] != '\\') {
-			mark[i] = true
-			inString = false
-		} else if inString {
-			mark[i] = true
+
+	for i < len(line) {
+		if b.lineCommentAt(line, i) {
+			tokens = append(tokens, Token{Start: i, End: len(line), Kind: TokenComment})
+			break
+		}
+
+		if idx := b.regionAt(line, i); idx != -1 {
+			r := b.regions[idx]
+			end, closed := r.findEnd(line, i+len(r.Start))
+			tokens = append(tokens, Token{Start: i, End: end, Kind: r.Kind})
+			if !closed && r.Multiline {
+				return tokens, State(idx + 1)
+			}
+			i = end
+			continue
+		}
+
+		if end, kind, ok := patterns.at(i); ok {
+			tokens = append(tokens, Token{Start: i, End: end, Kind: kind})
+			i = end
+			continue
+		}
+
+		c := line[i]
+		switch {
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(line) && isIdentChar(line[j]) {
+				j++
+			}
+			word := line[i:j]
+			if b.ignoreCase {
+				word = strings.ToLower(word)
+			}
+			kind, ok := b.words[word]
+			if !ok && j < len(line) && line[j] == '(' {
+				kind, ok = TokenFunction, true
+			}
+			if ok {
+				tokens = append(tokens, Token{Start: i, End: j, Kind: kind})
+			}
+			i = j
+		case isDigit(c):
+			j := i + 1
+			for j < len(line) && (isIdentChar(line[j]) || line[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, Token{Start: i, End: j, Kind: TokenNumber})
+			i = j
+		default:
+			_, size := utf8.DecodeRuneInString(line[i:])
+			i += size
 		}
 	}
-	return mark
+
+	return tokens, 0
 }
 
-func isBoundary(s string, idx int) bool {
-	if idx < 0 || idx >= len(s) {
-		return true
+// patternMatches are the matches of each pattern on a line, consumed
+// from the left as the lexer moves along
+type patternMatches struct {
+	patterns []Pattern
+	matches  [][][]int
+	next     []int
+}
+
+func (b *Base) matchPatterns(line string) *patternMatches {
+	if len(b.patterns) == 0 {
+		return nil
+	}
+	pm := &patternMatches{
+		patterns: b.patterns,
+		matches:  make([][][]int, len(b.patterns)),
+		next:     make([]int, len(b.patterns)),
 	}
-	ch := s[idx]
-	return ch != '_' && (ch < 'a' || ch > 'z') && (ch < 'A' || ch > 'Z') && (ch < '0' || ch > '9')
-}
-
-// HighlightStrings highlights quoted strings
-func (b *Base) HighlightStrings(text string, style lipgloss.Style) string {
-	inString := false
-	quote := rune(0)
-	var result strings.Builder
-
-	for i, ch := range text {
-		if !inString && (ch == '"' || ch == '\'') {
-			inString = true
-			quote = ch
-			result.WriteString(style.Render(string(ch)))
-		} else if inString && ch == quote && (i == 0 || text[i-1] != '\\') {
-			result.WriteString(style.Render(string(ch)))
-			inString = false
-		} else if inString {
-			result.WriteString(style.Render(string(ch)))
-		} else {
-			result.WriteRune(ch)
+	for i, p := range b.patterns {
+		pm.matches[i] = p.Regexp.FindAllStringIndex(line, -1)
+	}
+	return pm
+}
+
+// at returns the end and kind of the first pattern with a non-empty
+// match starting at i
+func (pm *patternMatches) at(i int) (int, TokenKind, bool) {
+	if pm == nil {
+		return 0, 0, false
+	}
+	for p, matches := range pm.matches {
+		for pm.next[p] < len(matches) && matches[pm.next[p]][0] < i {
+			pm.next[p]++
+		}
+		if n := pm.next[p]; n < len(matches) && matches[n][0] == i && matches[n][1] > i {
+			return matches[n][1], pm.patterns[p].Kind, true
 		}
 	}
+	return 0, 0, false
+}
 
-	return strings.ReplaceAll(result.String(), "\t", "  ")
-	// return result.String()
+func (b *Base) lineCommentAt(line string, i int) bool {
+	for _, prefix := range b.lineComments {
+		if strings.HasPrefix(line[i:], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// regionAt returns the index of the region starting at i, or -1
+func (b *Base) regionAt(line string, i int) int {
+	for idx, r := range b.regions {
+		if strings.HasPrefix(line[i:], r.Start) {
+			return idx
+		}
+	}
+	return -1
 }
 
-// HighlightComments highlights comments
-func (b *Base) HighlightComments(text, commentStart string, style lipgloss.Style) string {
-	idx := strings.Index(text, commentStart)
-	if idx == -1 {
-		return text
+// findEnd returns the offset just past the region's end delimiter,
+// searching from from, and whether the delimiter was found
+func (r Region) findEnd(line string, from int) (int, bool) {
+	for j := from; j < len(line); j++ {
+		if r.Escape != 0 && line[j] == r.Escape {
+			j++
+			continue
+		}
+		if strings.HasPrefix(line[j:], r.End) {
+			return j + len(r.End), true
+		}
 	}
+	return len(line), false
+}
 
-	before := text[:idx]
-	comment := text[idx:]
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
 
-	return before + style.Render(comment)
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
 }
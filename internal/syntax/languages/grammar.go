@@ -0,0 +1,138 @@
+package languages
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Grammar is a language read from a grammar file instead of written in
+// Go. Besides the lexer rules it names the files it applies to.
+type Grammar struct {
+	*Base
+	name       string
+	Extensions []string // with the dot, as in ".rs"
+	Filenames  []string // whole file names, as in "Makefile"
+	Shebangs   []string // interpreters, as in "python3"
+}
+
+func (g *Grammar) Name() string {
+	return g.name
+}
+
+// grammarFile is the layout of a grammar file. The bundled grammars in
+// configs/syntax serve as examples; kinds are the names in tokenKinds.
+type grammarFile struct {
+	Name         string   `yaml:"name"`
+	Extensions   []string `yaml:"extensions"`
+	Filenames    []string `yaml:"filenames"`
+	Shebangs     []string `yaml:"shebangs"`
+	IgnoreCase   bool     `yaml:"ignore_case"`
+	Keywords     []string `yaml:"keywords"`
+	Types        []string `yaml:"types"`
+	Constants    []string `yaml:"constants"`
+	LineComments []string `yaml:"line_comments"`
+	Regions      []struct {
+		Start     string `yaml:"start"`
+		End       string `yaml:"end"`
+		Escape    string `yaml:"escape"`
+		Multiline bool   `yaml:"multiline"`
+		Kind      string `yaml:"kind"`
+	} `yaml:"regions"`
+	Patterns []struct {
+		Match string `yaml:"match"`
+		Kind  string `yaml:"kind"`
+	} `yaml:"patterns"`
+	Structure struct {
+		Functions []string `yaml:"functions"`
+		Types     []string `yaml:"types"`
+		Indented  bool     `yaml:"indented"`
+	} `yaml:"structure"`
+}
+
+// tokenKinds are the token kind names used in grammar files
+var tokenKinds = map[string]TokenKind{
+	"text":     TokenText,
+	"keyword":  TokenKeyword,
+	"type":     TokenType,
+	"constant": TokenConstant,
+	"string":   TokenString,
+	"comment":  TokenComment,
+	"number":   TokenNumber,
+	"function": TokenFunction,
+}
+
+// ParseGrammar reads a grammar file in YAML or JSON. source names the
+// file in errors.
+func ParseGrammar(source string, data []byte) (*Grammar, error) {
+	var f grammarFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			// Report "line 2: field foo not found" without the Go type
+			msg := strings.Join(typeErr.Errors, "; ")
+			return nil, fmt.Errorf("%s: %s", source, strings.ReplaceAll(msg, " in type languages.grammarFile", ""))
+		}
+		return nil, fmt.Errorf("%s: %v", source, err)
+	}
+	if f.Name == "" {
+		return nil, fmt.Errorf("%s: grammar has no name", source)
+	}
+
+	rules := Rules{
+		Keywords:     f.Keywords,
+		Types:        f.Types,
+		Constants:    f.Constants,
+		LineComments: f.LineComments,
+		IgnoreCase:   f.IgnoreCase,
+		Structure: Structure{
+			Functions: f.Structure.Functions,
+			Types:     f.Structure.Types,
+			Indented:  f.Structure.Indented,
+		},
+	}
+
+	for i, r := range f.Regions {
+		kind, ok := tokenKinds[r.Kind]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("%s: region %d: unknown kind %q", source, i+1, r.Kind)
+		case r.Start == "" || r.End == "":
+			return nil, fmt.Errorf("%s: region %d: start and end are required", source, i+1)
+		case len(r.Escape) > 1:
+			return nil, fmt.Errorf("%s: region %d: escape must be one character", source, i+1)
+		}
+		region := Region{Start: r.Start, End: r.End, Multiline: r.Multiline, Kind: kind}
+		if r.Escape != "" {
+			region.Escape = r.Escape[0]
+		}
+		rules.Regions = append(rules.Regions, region)
+	}
+
+	for i, p := range f.Patterns {
+		kind, ok := tokenKinds[p.Kind]
+		if !ok {
+			return nil, fmt.Errorf("%s: pattern %d: unknown kind %q", source, i+1, p.Kind)
+		}
+		re, err := regexp.Compile(p.Match)
+		if err != nil {
+			return nil, fmt.Errorf("%s: pattern %d: %v", source, i+1, err)
+		}
+		rules.Patterns = append(rules.Patterns, Pattern{Regexp: re, Kind: kind})
+	}
+
+	return &Grammar{
+		Base:       NewBase(rules),
+		name:       f.Name,
+		Extensions: f.Extensions,
+		Filenames:  f.Filenames,
+		Shebangs:   f.Shebangs,
+	}, nil
+}
@@ -3,39 +3,34 @@ package languages
 // JavaScript provides JavaScript syntax highlighting
 type JavaScript struct {
 	*Base
-	keywords  []string
-	constants []string
 }
 
 func NewJavaScript() *JavaScript {
 	return &JavaScript{
-		Base: NewBase(),
-		keywords: []string{
-			"function", "const", "let", "var", "if", "else", "for",
-			"while", "return", "class", "extends", "import", "export",
-			"from", "async", "await", "try", "catch", "finally",
-			"switch", "case", "default", "break", "continue", "new",
-			"this", "typeof", "instanceof",
-		},
-		constants: []string{"true", "false", "null", "undefined"},
+		Base: NewBase(Rules{
+			Keywords: []string{
+				"function", "const", "let", "var", "if", "else", "for",
+				"while", "do", "return", "class", "extends", "import", "export",
+				"from", "async", "await", "try", "catch", "finally", "throw",
+				"switch", "case", "default", "break", "continue", "new",
+				"this", "typeof", "instanceof", "of", "in", "yield", "delete",
+			},
+			Constants:    []string{"true", "false", "null", "undefined"},
+			LineComments: []string{"//"},
+			Regions: []Region{
+				{Start: "/*", End: "*/", Multiline: true, Kind: TokenComment},
+				{Start: `"`, End: `"`, Escape: '\\', Kind: TokenString},
+				{Start: "'", End: "'", Escape: '\\', Kind: TokenString},
+				// Template literals span lines
+				{Start: "`", End: "`", Escape: '\\', Multiline: true, Kind: TokenString},
+			},
+			Structure: Structure{Functions: []string{"function"}, Types: []string{"class"}},
+		}),
 	}
 }
 
-func (j *JavaScript) Highlight(line string) string {
-	result := line
-
-	for _, kw := range j.keywords {
-		result = j.HighlightWord(result, kw, j.keywordStyle)
-	}
-
-	for _, c := range j.constants {
-		result = j.HighlightWord(result, c, j.constantStyle)
-	}
-
-	result = j.HighlightStrings(result, j.stringStyle)
-	result = j.HighlightComments(result, "//", j.commentStyle)
-
-	return result
+func (j *JavaScript) Name() string {
+	return "javascript"
 }
 
 // TypeScript is an alias for JavaScript
@@ -48,3 +43,7 @@ func NewTypeScript() *TypeScript {
 		JavaScript: NewJavaScript(),
 	}
 }
+
+func (t *TypeScript) Name() string {
+	return "typescript"
+}
@@ -1,16 +1,18 @@
 package languages
 
 // Plain is a plain text highlighter that does no highlighting
-type Plain struct {
-	*Base
-}
+type Plain struct{}
 
 // NewPlain creates a new plain text highlighter
 func NewPlain() *Plain {
 	return &Plain{}
 }
 
-// Highlight return the line unchanged
-func (p *Plain) Highlight(line string) string {
-	return line
+func (p *Plain) Name() string {
+	return "plain"
+}
+
+// Tokenize returns no tokens, leaving the line unchanged
+func (p *Plain) Tokenize(line string, state State) ([]Token, State) {
+	return nil, state
 }
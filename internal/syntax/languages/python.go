@@ -3,36 +3,32 @@ package languages
 // Python provides Python syntax highlighting
 type Python struct {
 	*Base
-	keywords  []string
-	constants []string
 }
 
 func NewPython() *Python {
 	return &Python{
-		Base: NewBase(),
-		keywords: []string{
-			"def", "class", "if", "elif", "else", "for", "while",
-			"return", "import", "from", "as", "try", "except",
-			"finally", "with", "lambda", "yield", "pass", "break",
-			"continue", "raise", "assert", "global", "nonlocal",
-		},
-		constants: []string{"True", "False", "None"},
+		Base: NewBase(Rules{
+			Keywords: []string{
+				"def", "class", "if", "elif", "else", "for", "while",
+				"return", "import", "from", "as", "try", "except",
+				"finally", "with", "lambda", "yield", "pass", "break",
+				"continue", "raise", "assert", "global", "nonlocal",
+				"and", "or", "not", "in", "is", "async", "await", "del",
+			},
+			Constants:    []string{"True", "False", "None"},
+			LineComments: []string{"#"},
+			Regions: []Region{
+				// Triple-quoted strings span lines
+				{Start: `"""`, End: `"""`, Escape: '\\', Multiline: true, Kind: TokenString},
+				{Start: "'''", End: "'''", Escape: '\\', Multiline: true, Kind: TokenString},
+				{Start: `"`, End: `"`, Escape: '\\', Kind: TokenString},
+				{Start: "'", End: "'", Escape: '\\', Kind: TokenString},
+			},
+			Structure: Structure{Functions: []string{"def"}, Types: []string{"class"}, Indented: true},
+		}),
 	}
 }
 
-func (p *Python) Highlight(line string) string {
-	result := line
-
-	for _, kw := range p.keywords {
-		result = p.HighlightWord(result, kw, p.keywordStyle)
-	}
-
-	for _, c := range p.constants {
-		result = p.HighlightWord(result, c, p.constantStyle)
-	}
-
-	result = p.HighlightStrings(result, p.stringStyle)
-	result = p.HighlightComments(result, "#", p.commentStyle)
-
-	return result
+func (p *Python) Name() string {
+	return "python"
 }
@@ -53,3 +53,109 @@ func FindMatchingBracket(line string, pos int) int {
 
 	return -1
 }
+
+// LineReader gives the multi-line matchers access to a buffer
+type LineReader interface {
+	Line(n int) string
+	LineCount() int
+}
+
+// FindMatchingBracketAcross finds the bracket matching the one at (line, col),
+// searching across lines. Columns are byte offsets. It tries
+// FindMatchingBracket on the line first.
+func FindMatchingBracketAcross(buf LineReader, line, col int) (int, int, bool) {
+	text := buf.Line(line)
+	if col < 0 || col >= len(text) || !IsBracket(rune(text[col])) {
+		return 0, 0, false
+	}
+
+	// Single-line fast path, only valid when rune and byte offsets agree
+	if len(text) == len([]rune(text)) {
+		if match := FindMatchingBracket(text, col); match != -1 {
+			return line, match, true
+		}
+	}
+
+	opening := map[byte]byte{'(': ')', '[': ']', '{': '}'}
+	closing := map[byte]byte{')': '(', ']': '[', '}': '{'}
+	target := text[col]
+
+	if match, ok := opening[target]; ok {
+		return scanForward(buf, line, col+1, target, match)
+	}
+	return scanBackward(buf, line, col-1, closing[target], target)
+}
+
+// FindEnclosingBracket finds the open bracket of the innermost open/close
+// pair that contains (line, col), and its matching close bracket
+func FindEnclosingBracket(buf LineReader, line, col int, open, close byte) (openLine, openCol, closeLine, closeCol int, ok bool) {
+	// A bracket under the cursor counts as enclosing
+	text := buf.Line(line)
+	if col >= 0 && col < len(text) && text[col] == open {
+		openLine, openCol = line, col
+	} else if col >= 0 && col < len(text) && text[col] == close {
+		openLine, openCol, ok = scanBackward(buf, line, col-1, open, close)
+		if !ok {
+			return
+		}
+		return openLine, openCol, line, col, true
+	} else {
+		openLine, openCol, ok = scanBackward(buf, line, col-1, open, close)
+		if !ok {
+			return
+		}
+	}
+
+	closeLine, closeCol, ok = scanForward(buf, openLine, openCol+1, open, close)
+	return
+}
+
+// scanForward finds the close bracket that balances one open bracket
+// before (line, col)
+func scanForward(buf LineReader, line, col int, open, close byte) (int, int, bool) {
+	depth := 1
+	for l := line; l < buf.LineCount(); l++ {
+		text := buf.Line(l)
+		start := 0
+		if l == line {
+			start = col
+		}
+		for i := start; i < len(text); i++ {
+			switch text[i] {
+			case open:
+				depth++
+			case close:
+				depth--
+				if depth == 0 {
+					return l, i, true
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// scanBackward finds the open bracket that balances one close bracket
+// after (line, col)
+func scanBackward(buf LineReader, line, col int, open, close byte) (int, int, bool) {
+	depth := 1
+	for l := line; l >= 0; l-- {
+		text := buf.Line(l)
+		start := len(text) - 1
+		if l == line {
+			start = min(col, len(text)-1)
+		}
+		for i := start; i >= 0; i-- {
+			switch text[i] {
+			case close:
+				depth++
+			case open:
+				depth--
+				if depth == 0 {
+					return l, i, true
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,160 @@
+package syntax
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tobibamidele/minra/internal/syntax/languages"
+)
+
+// Registry knows the available languages and which files they apply to.
+// Languages registered later take over the names, extensions, file names
+// and interpreters of earlier ones, so user grammars override bundled
+// ones.
+type Registry struct {
+	byName      map[string]languages.Language
+	byExtension map[string]string
+	byFilename  map[string]string
+	byShebang   map[string]string
+}
+
+// NewRegistry creates a registry of the languages written in Go
+func NewRegistry() *Registry {
+	r := &Registry{
+		byName:      make(map[string]languages.Language),
+		byExtension: make(map[string]string),
+		byFilename:  make(map[string]string),
+		byShebang:   make(map[string]string),
+	}
+	r.Register(languages.NewPlain(), []string{".txt"}, nil, nil)
+	r.Register(languages.NewGo(), []string{".go"}, nil, nil)
+	r.Register(languages.NewPython(), []string{".py", ".pyw"}, nil, []string{"python", "python3"})
+	r.Register(languages.NewJavaScript(), []string{".js", ".jsx", ".mjs", ".cjs"}, nil, []string{"node"})
+	r.Register(languages.NewTypeScript(), []string{".ts", ".tsx"}, nil, []string{"deno", "ts-node"})
+	return r
+}
+
+// Register adds lang for files with the given extensions, file names and
+// shebang interpreters
+func (r *Registry) Register(lang languages.Language, extensions, filenames, shebangs []string) {
+	name := lang.Name()
+	r.byName[name] = lang
+	for _, ext := range extensions {
+		r.byExtension[strings.ToLower(ext)] = name
+	}
+	for _, f := range filenames {
+		r.byFilename[f] = name
+	}
+	for _, s := range shebangs {
+		r.byShebang[s] = name
+	}
+}
+
+// RegisterGrammar adds a language read from a grammar file
+func (r *Registry) RegisterGrammar(g *languages.Grammar) {
+	r.Register(g, g.Extensions, g.Filenames, g.Shebangs)
+}
+
+// LoadFS registers every .yaml, .yml and .json grammar in dir of fsys, in
+// name order. Grammars that fail to parse are reported and skipped.
+func (r *Registry) LoadFS(fsys fs.FS, dir string) []error {
+	return r.load(fsys, dir, func(name string) string { return path.Join(dir, name) })
+}
+
+// LoadDir registers the grammars in a directory on disk. A missing
+// directory has no grammars.
+func (r *Registry) LoadDir(dir string) []error {
+	if dir == "" {
+		return nil
+	}
+	return r.load(os.DirFS(dir), ".", func(name string) string { return filepath.Join(dir, name) })
+}
+
+// load registers the grammars in dir, naming each file in errors by
+// source
+func (r *Registry) load(fsys fs.FS, dir string, source func(name string) string) []error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return []error{fmt.Errorf("%s: %v", source(""), unwrapPath(err))}
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		switch path.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", source(entry.Name()), unwrapPath(err)))
+			continue
+		}
+		g, err := languages.ParseGrammar(source(entry.Name()), data)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		r.RegisterGrammar(g)
+	}
+	return errs
+}
+
+// unwrapPath drops the path from a file system error, which names the
+// file relative to the directory being loaded
+func unwrapPath(err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Err
+	}
+	return err
+}
+
+// ByName returns the language with the given name
+func (r *Registry) ByName(name string) (languages.Language, bool) {
+	lang, ok := r.byName[name]
+	return lang, ok
+}
+
+// ForPath returns the language of a file by its name or extension, or
+// false if neither is known
+func (r *Registry) ForPath(p string) (languages.Language, bool) {
+	base := filepath.Base(p)
+	name, ok := r.byFilename[base]
+	if !ok {
+		name, ok = r.byExtension[strings.ToLower(filepath.Ext(base))]
+	}
+	if !ok {
+		return nil, false
+	}
+	return r.ByName(name)
+}
+
+// ForShebang returns the language run by an interpreter, as named on a
+// #! line
+func (r *Registry) ForShebang(interpreter string) (languages.Language, bool) {
+	name, ok := r.byShebang[interpreter]
+	if !ok {
+		return nil, false
+	}
+	return r.ByName(name)
+}
+
+// Names returns the names of the registered languages, sorted
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.byName))
+	for name := range r.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
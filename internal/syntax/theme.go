@@ -1,9 +1,30 @@
 package syntax
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 
-// Theme defines color scheme
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tobibamidele/minra/configs"
+	"github.com/tobibamidele/minra/internal/ui"
+	"gopkg.in/yaml.v3"
+)
+
+// Theme defines color scheme: the styles of each kind of token and the
+// colors of the rest of the UI
 type Theme struct {
+	Name string
+	Dark bool
+
 	Keyword  lipgloss.Style
 	Type     lipgloss.Style
 	Constant lipgloss.Style
@@ -11,11 +32,15 @@ type Theme struct {
 	Comment  lipgloss.Style
 	Function lipgloss.Style
 	Number   lipgloss.Style
+
+	UI ui.Palette
 }
 
 // DefaultTheme returns default theme
 func DefaultTheme() *Theme {
 	return &Theme{
+		Name:     "default",
+		Dark:     true,
 		Keyword:  lipgloss.NewStyle().Foreground(lipgloss.Color("205")),
 		Type:     lipgloss.NewStyle().Foreground(lipgloss.Color("117")),
 		Constant: lipgloss.NewStyle().Foreground(lipgloss.Color("141")),
@@ -23,5 +48,235 @@ func DefaultTheme() *Theme {
 		Comment:  lipgloss.NewStyle().Foreground(lipgloss.Color("243")),
 		Function: lipgloss.NewStyle().Foreground(lipgloss.Color("220")),
 		Number:   lipgloss.NewStyle().Foreground(lipgloss.Color("174")),
+		UI:       ui.DefaultPalette(),
+	}
+}
+
+// styles returns the token styles by the names theme files use
+func (t *Theme) styles() map[string]*lipgloss.Style {
+	return map[string]*lipgloss.Style{
+		"keyword":  &t.Keyword,
+		"type":     &t.Type,
+		"constant": &t.Constant,
+		"string":   &t.String,
+		"comment":  &t.Comment,
+		"function": &t.Function,
+		"number":   &t.Number,
+	}
+}
+
+// Downsampled returns a copy of t with its token colors replaced by the
+// nearest the terminal has. Without colors, keywords are bold.
+func (t *Theme) Downsampled() *Theme {
+	d := *t
+	for _, s := range d.styles() {
+		*s = ui.DownsampleStyle(*s)
+	}
+	if ui.Monochrome() {
+		d.Keyword = d.Keyword.Bold(true)
+	}
+	return &d
+}
+
+// themes are the known themes by name: the default, the bundled theme
+// files and the user's
+var themes = map[string]*Theme{
+	"default": DefaultTheme(),
+}
+
+func init() {
+	if errs := LoadThemesFS(configs.Themes, "themes"); len(errs) > 0 {
+		panic(errs[0])
+	}
+}
+
+// ThemeByName returns the theme with the given name
+func ThemeByName(name string) (*Theme, bool) {
+	t, ok := themes[name]
+	return t, ok
+}
+
+// ThemeNames returns the names of the known themes, sorted
+func ThemeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// themeFile is the layout of a theme file. A theme starts as a copy of
+// its base theme, the default unless named, and overrides the colors it
+// lists. Styles are a color or a mapping of fg, bg, bold, italic and
+// underline.
+type themeFile struct {
+	Name   string               `yaml:"name"`
+	Base   string               `yaml:"base"`
+	Dark   *bool                `yaml:"dark"`
+	Syntax map[string]yaml.Node `yaml:"syntax"`
+	UI     map[string]string    `yaml:"ui"`
+}
+
+// styleSpec is the long form of a token style
+type styleSpec struct {
+	Fg        string `yaml:"fg"`
+	Bg        string `yaml:"bg"`
+	Bold      bool   `yaml:"bold"`
+	Italic    bool   `yaml:"italic"`
+	Underline bool   `yaml:"underline"`
+}
+
+// colorPattern matches the colors theme files may use: #rgb, #rrggbb or
+// an ANSI color number
+var colorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{3}|#[0-9a-fA-F]{6}|[0-9]{1,3})$`)
+
+func parseColor(s string) (lipgloss.Color, error) {
+	if !colorPattern.MatchString(s) {
+		return "", fmt.Errorf("invalid color %q", s)
+	}
+	return lipgloss.Color(s), nil
+}
+
+// ParseTheme reads a theme file in YAML or JSON. source names the file in
+// errors.
+func ParseTheme(source string, data []byte) (*Theme, error) {
+	var f themeFile
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&f); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			msg := strings.Join(typeErr.Errors, "; ")
+			return nil, fmt.Errorf("%s: %s", source, strings.ReplaceAll(msg, " in type syntax.themeFile", ""))
+		}
+		return nil, fmt.Errorf("%s: %v", source, err)
+	}
+	if f.Name == "" {
+		return nil, fmt.Errorf("%s: theme has no name", source)
+	}
+
+	baseName := f.Base
+	if baseName == "" {
+		baseName = "default"
+	}
+	base, ok := themes[baseName]
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown base theme %q", source, baseName)
+	}
+	t := *base
+	t.Name = f.Name
+	if f.Dark != nil {
+		t.Dark = *f.Dark
+	}
+
+	styles := t.styles()
+	for name, node := range f.Syntax {
+		style, ok := styles[name]
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: unknown syntax scope %q", source, node.Line, name)
+		}
+		var spec styleSpec
+		if node.Kind == yaml.ScalarNode {
+			spec.Fg = node.Value
+		} else if err := node.Decode(&spec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s: %v", source, node.Line, name, err)
+		}
+
+		s := lipgloss.NewStyle().Bold(spec.Bold).Italic(spec.Italic).Underline(spec.Underline)
+		if spec.Fg != "" {
+			color, err := parseColor(spec.Fg)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %s: %v", source, node.Line, name, err)
+			}
+			s = s.Foreground(color)
+		}
+		if spec.Bg != "" {
+			color, err := parseColor(spec.Bg)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: %s: %v", source, node.Line, name, err)
+			}
+			s = s.Background(color)
+		}
+		*style = s
+	}
+
+	fields := t.UI.Fields()
+	for name, value := range f.UI {
+		field, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown UI color %q", source, name)
+		}
+		color, err := parseColor(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %v", source, name, err)
+		}
+		*field = color
+	}
+
+	return &t, nil
+}
+
+// LoadThemesFS adds the .yaml, .yml and .json themes in dir of fsys.
+// Themes that fail to load are reported and skipped.
+func LoadThemesFS(fsys fs.FS, dir string) []error {
+	return loadThemes(fsys, dir, func(name string) string { return path.Join(dir, name) })
+}
+
+// LoadThemeDir adds the themes in a directory on disk. A missing
+// directory has no themes.
+func LoadThemeDir(dir string) []error {
+	if dir == "" {
+		return nil
+	}
+	return loadThemes(os.DirFS(dir), ".", func(name string) string { return filepath.Join(dir, name) })
+}
+
+func loadThemes(fsys fs.FS, dir string, source func(name string) string) []error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return []error{fmt.Errorf("%s: %v", source(""), unwrapPath(err))}
+	}
+
+	// Load in passes so a theme may be based on one whose file sorts
+	// after it
+	pending := make(map[string][]byte)
+	var errs []error
+	for _, entry := range entries {
+		switch path.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", source(entry.Name()), unwrapPath(err)))
+			continue
+		}
+		pending[entry.Name()] = data
+	}
+
+	for len(pending) > 0 {
+		var failed []error
+		loaded := false
+		for name, data := range pending {
+			t, err := ParseTheme(source(name), data)
+			if err != nil {
+				failed = append(failed, err)
+				continue
+			}
+			themes[t.Name] = t
+			delete(pending, name)
+			loaded = true
+		}
+		if !loaded {
+			sort.Slice(failed, func(i, j int) bool { return failed[i].Error() < failed[j].Error() })
+			errs = append(errs, failed...)
+			break
+		}
 	}
+	return errs
 }
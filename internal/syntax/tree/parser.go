@@ -0,0 +1,637 @@
+package tree
+
+import (
+	"sort"
+
+	"github.com/tobibamidele/minra/internal/syntax/languages"
+)
+
+// Document is the text a Parser reads. buffer.Buffer implements it.
+type Document interface {
+	Line(n int) string
+	LineCount() int
+	Version() int
+}
+
+type itemKind uint8
+
+const (
+	itemOpen itemKind = iota
+	itemClose
+	itemComma
+	itemColon
+	itemWord
+)
+
+// item is a piece of a line that matters to the structure: a bracket, a
+// separator or a word outside strings and comments
+type item struct {
+	kind itemKind
+	col  int
+	ch   byte
+	word string
+}
+
+// lineInfo is what the parser needs from one line. It only depends on the
+// text and the lexer state at the start of the line, which is how lines
+// are cached between parses.
+type lineInfo struct {
+	items     []item
+	indent    int
+	blank     bool // nothing but whitespace and comments
+	continued bool // starts inside a string or comment from an earlier line
+	end       languages.State
+}
+
+// editedDocument is a Document that knows which of its lines changed
+// since an earlier version, as buffer.Buffer does. Other documents are
+// parsed from scratch on every version.
+type editedDocument interface {
+	Document
+	ChangedLines(since int) (start, end, delta int, ok bool)
+}
+
+// mark is a line where the builder is back at the top level once the line
+// has dedented: nothing is open and no declaration is pending. A parse
+// resumes from the last mark above an edit, and stops at the first mark
+// below it where the previous parse was in the same state.
+type mark struct {
+	line       int
+	children   int // top-level nodes before the line
+	stmtIndent int
+	state      languages.State // lexer state at the start of the line
+}
+
+// Parser keeps the parse tree of one document up to date. Each parse
+// rebuilds the tree only around the lines edited since the last one, and
+// rescans only the lines whose text or starting lexer state changed.
+// Nodes are shared between versions, so a tree is only valid until the
+// next parse.
+type Parser struct {
+	language string
+	tree     *Tree
+	infos    []*lineInfo // by line, from the last parse
+	marks    []mark
+}
+
+// NewParser creates a parser with an empty cache
+func NewParser() *Parser {
+	return &Parser{}
+}
+
+// Parse returns the tree of doc in lang. The previous tree is returned as
+// is while the document version and language stay the same.
+func (p *Parser) Parse(doc Document, lang languages.Language) *Tree {
+	if p.tree != nil && p.tree.Version == doc.Version() && p.language == lang.Name() {
+		return p.tree
+	}
+
+	start, end, delta, ok := 0, 0, 0, false
+	if d, edited := doc.(editedDocument); edited && p.tree != nil && p.language == lang.Name() {
+		start, end, delta, ok = d.ChangedLines(p.tree.Version)
+	}
+	if !ok {
+		p.tree, p.infos, p.marks = nil, nil, nil
+		start, end, delta = 0, doc.LineCount(), doc.LineCount()
+	}
+	p.language = lang.Name()
+
+	var structure languages.Structure
+	if s, ok := lang.(interface{ Structure() languages.Structure }); ok {
+		structure = s.Structure()
+	}
+
+	// Resume from the last mark above the edit. The mark's own line must
+	// be unchanged, since its dedent was already done.
+	b := newBuilder(doc, structure)
+	resume := sort.Search(len(p.marks), func(i int) bool { return p.marks[i].line >= start }) - 1
+	from := 0
+	if resume >= 0 {
+		from = p.marks[resume].line
+		b.resume(p.tree, p.marks[resume])
+	}
+	marks := append([]mark(nil), p.marks[:resume+1]...)
+
+	infos := make([]*lineInfo, doc.LineCount())
+	copy(infos, p.infos[:from])
+	var state languages.State
+	if from > 0 {
+		state = infos[from-1].end
+	}
+
+	// The first mark of the last parse that could be below the edit
+	next := sort.Search(len(p.marks), func(i int) bool { return p.marks[i].line >= end-delta })
+
+	for n := from; n < doc.LineCount(); n++ {
+		info := p.lineInfo(doc, lang, n, start, end, delta, state)
+		infos[n] = info
+
+		if resume < 0 || n > from {
+			b.dedentLine(info)
+			if b.clean() {
+				m := mark{line: n, children: len(b.root.Children), stmtIndent: b.stmtIndent, state: state}
+				for next < len(p.marks) && p.marks[next].line < n-delta {
+					next++
+				}
+				if n >= end && next < len(p.marks) && p.marks[next].line == n-delta &&
+					p.marks[next].stmtIndent == m.stmtIndent && p.marks[next].state == m.state {
+					// The rest of the document parses as it did before
+					marks = b.splice(p, next, delta, infos, marks)
+					break
+				}
+				marks = append(marks, m)
+			}
+		}
+
+		b.line(n, info)
+		state = info.end
+	}
+
+	p.infos = infos
+	p.marks = marks
+	p.tree = b.finish()
+	p.tree.Version = doc.Version()
+	return p.tree
+}
+
+// lineInfo returns the info of line n, from the last parse when the line
+// is outside the edit and starts in the same lexer state
+func (p *Parser) lineInfo(doc Document, lang languages.Language, n, start, end, delta int, state languages.State) *lineInfo {
+	old := n
+	if n >= end {
+		old = n - delta
+	} else if n >= start {
+		old = -1
+	}
+	if old >= 0 && old < len(p.infos) {
+		var oldState languages.State
+		if old > 0 {
+			oldState = p.infos[old-1].end
+		}
+		if oldState == state {
+			return p.infos[old]
+		}
+	}
+	return scanLine(lang, doc.Line(n), state)
+}
+
+// scanLine lexes a line and picks out its structural items
+func scanLine(lang languages.Language, text string, state languages.State) *lineInfo {
+	tokens, end := lang.Tokenize(text, state)
+	info := &lineInfo{end: end, continued: state != 0, blank: true}
+
+	for _, ch := range text {
+		if ch == ' ' {
+			info.indent++
+		} else if ch == '\t' {
+			info.indent += 8 - info.indent%8
+		} else {
+			break
+		}
+	}
+
+	ti := 0
+	for i := 0; i < len(text); {
+		for ti < len(tokens) && tokens[ti].End <= i {
+			ti++
+		}
+		if ti < len(tokens) && tokens[ti].Start <= i {
+			switch tokens[ti].Kind {
+			case languages.TokenComment:
+				i = tokens[ti].End
+				continue
+			case languages.TokenString:
+				info.blank = false
+				i = tokens[ti].End
+				continue
+			}
+		}
+
+		c := text[i]
+		if c != ' ' && c != '\t' {
+			info.blank = false
+		}
+		switch {
+		case c == '(' || c == '[' || c == '{':
+			info.items = append(info.items, item{kind: itemOpen, col: i, ch: c})
+		case c == ')' || c == ']' || c == '}':
+			info.items = append(info.items, item{kind: itemClose, col: i, ch: c})
+		case c == ',':
+			info.items = append(info.items, item{kind: itemComma, col: i})
+		case c == ':':
+			info.items = append(info.items, item{kind: itemColon, col: i})
+		case isIdentStart(c) || isDigit(c):
+			j := i + 1
+			for j < len(text) && (isIdentStart(text[j]) || isDigit(text[j])) {
+				j++
+			}
+			if !isDigit(c) {
+				info.items = append(info.items, item{kind: itemWord, col: i, word: text[i:j]})
+			}
+			i = j
+			continue
+		}
+		i++
+	}
+	return info
+}
+
+// declStage tracks how far a declaration has been read. Names come right
+// after the keyword, or after a Go method receiver, which is only known
+// to be one when a parameter list follows the word after it.
+type declStage int
+
+const (
+	stageKeyword   declStage = iota // just after the keyword
+	stageFirstList                  // in a list right after the keyword
+	stageAfterList                  // that list has closed
+	stageCandidate                  // a word followed it; a name if ( comes next
+	stageNamed                      // waiting for the body
+)
+
+type builder struct {
+	doc       Document
+	structure languages.Structure
+	root      *Node
+	stack     []*Node // open brackets and indented blocks, innermost last
+
+	decl      *Node // declaration waiting for its body
+	declDepth int   // stack depth the declaration was opened at
+	stage     declStage
+	candidate item
+	candPos   Pos
+
+	lastCode   Pos // end of the last line with code
+	stmtIndent int // indent of the line the current statement started on
+	names      [][]languages.Token
+}
+
+func newBuilder(doc Document, structure languages.Structure) *builder {
+	root := &Node{Kind: NodeFile}
+	return &builder{
+		doc:       doc,
+		structure: structure,
+		root:      root,
+		stack:     []*Node{root},
+		names:     make([][]languages.Token, doc.LineCount()),
+	}
+}
+
+// resume starts the builder at mark m of the previous tree, keeping the
+// top-level nodes and names above it
+func (b *builder) resume(prev *Tree, m mark) {
+	b.root.Children = make([]*Node, m.children)
+	for i, c := range prev.Root.Children[:m.children] {
+		c.Parent = b.root
+		b.root.Children[i] = c
+	}
+	copy(b.names, prev.names[:m.line])
+	b.stmtIndent = m.stmtIndent
+}
+
+// splice ends the build at a mark where the previous parse, p, was in the
+// same state: the rest of its tree, lines and marks are moved by delta
+// lines and reused. next is the index of that mark in p.marks.
+func (b *builder) splice(p *Parser, next, delta int, infos []*lineInfo, marks []mark) []mark {
+	old := p.marks[next]
+	line := old.line + delta
+	copy(infos[line:], p.infos[old.line:])
+	copy(b.names[line:], p.tree.names[old.line:])
+
+	children := len(b.root.Children)
+	for _, c := range p.tree.Root.Children[old.children:] {
+		shift(c, delta)
+		b.attach(b.root, c)
+	}
+	for _, m := range p.marks[next:] {
+		m.line += delta
+		m.children += children - old.children
+		marks = append(marks, m)
+	}
+	return marks
+}
+
+// shift moves a node and everything in it down by delta lines
+func shift(n *Node, delta int) {
+	if delta == 0 {
+		return
+	}
+	n.Start.Line += delta
+	n.End.Line += delta
+	if n.Name != "" {
+		n.NameStart.Line += delta
+		n.NameEnd.Line += delta
+	}
+	for _, c := range n.Children {
+		shift(c, delta)
+	}
+}
+
+// clean reports whether nothing is open and no declaration is pending
+func (b *builder) clean() bool {
+	return len(b.stack) == 1 && b.decl == nil
+}
+
+func (b *builder) top() *Node {
+	return b.stack[len(b.stack)-1]
+}
+
+// pending reports whether a declaration is waiting at the current depth
+func (b *builder) pending() bool {
+	return b.decl != nil && len(b.stack) == b.declDepth
+}
+
+// dedentLine closes the indented blocks a line is outside of. It comes
+// before the line's items.
+func (b *builder) dedentLine(info *lineInfo) {
+	if b.structure.Indented && !info.continued && !info.blank && b.top().open == 0 {
+		// Lines inside brackets continue a statement and do not dedent
+		b.dedent(info.indent)
+		b.stmtIndent = info.indent
+	}
+}
+
+// line adds the items of line n to the tree
+func (b *builder) line(n int, info *lineInfo) {
+	for _, it := range info.items {
+		pos := Pos{Line: n, Col: it.col}
+		switch it.kind {
+		case itemWord:
+			b.word(it, pos)
+		case itemOpen:
+			b.open(it.ch, pos)
+		case itemClose:
+			b.close(it.ch, pos)
+		case itemComma:
+			if top := b.top(); top.Kind == NodeList {
+				top.commas = append(top.commas, pos)
+			}
+		}
+	}
+
+	lineEnd := Pos{Line: n, Col: len(b.doc.Line(n))}
+	last := len(info.items) - 1
+	if b.structure.Indented && last >= 0 && info.items[last].kind == itemColon && b.top().open == 0 {
+		block := &Node{Kind: NodeBlock, Start: Pos{Line: n, Col: info.items[last].col + 1}, headerIndent: b.stmtIndent}
+		if b.pending() {
+			b.decl.Body = block
+			b.attach(b.decl, block)
+			b.decl = nil
+		} else {
+			b.attach(b.top(), block)
+		}
+		b.stack = append(b.stack, block)
+	}
+
+	// A declaration with no body on its line, like type X int, ends there
+	if b.pending() {
+		b.endDecl(lineEnd)
+	}
+
+	if !info.blank {
+		b.lastCode = lineEnd
+	}
+}
+
+func (b *builder) word(it item, pos Pos) {
+	if b.pending() {
+		switch b.stage {
+		case stageKeyword:
+			b.setName(it, pos)
+		case stageAfterList:
+			b.candidate, b.candPos = it, pos
+			b.stage = stageCandidate
+		case stageCandidate:
+			b.stage = stageNamed
+		}
+		return
+	}
+	if b.decl != nil {
+		return
+	}
+
+	kind := NodeKind(-1)
+	for _, kw := range b.structure.Functions {
+		if it.word == kw {
+			kind = NodeFunction
+		}
+	}
+	for _, kw := range b.structure.Types {
+		if it.word == kw {
+			kind = NodeType
+		}
+	}
+	if kind == -1 {
+		return
+	}
+
+	b.decl = &Node{Kind: kind, Start: pos}
+	b.attach(b.top(), b.decl)
+	b.declDepth = len(b.stack)
+	b.stage = stageKeyword
+}
+
+func (b *builder) setName(it item, pos Pos) {
+	b.decl.Name = it.word
+	b.decl.NameStart = pos
+	b.decl.NameEnd = Pos{Line: pos.Line, Col: pos.Col + len(it.word)}
+	b.stage = stageNamed
+
+	kind := languages.TokenFunction
+	if b.decl.Kind == NodeType {
+		kind = languages.TokenType
+	}
+	b.names[pos.Line] = append(b.names[pos.Line], languages.Token{Start: pos.Col, End: b.decl.NameEnd.Col, Kind: kind})
+}
+
+func (b *builder) open(ch byte, pos Pos) {
+	kind := NodeList
+	if ch == '{' {
+		kind = NodeBlock
+	}
+	node := &Node{Kind: kind, Start: pos, open: ch}
+	parent := b.top()
+
+	if b.pending() {
+		parent = b.decl
+		switch {
+		case ch == '{':
+			b.decl.Body = node
+			b.decl = nil
+		case b.stage == stageKeyword && ch == '(':
+			b.stage = stageFirstList
+		case b.stage == stageCandidate && ch == '(':
+			b.setName(b.candidate, b.candPos)
+		default:
+			b.stage = stageNamed
+		}
+	}
+
+	b.attach(parent, node)
+	b.stack = append(b.stack, node)
+}
+
+func (b *builder) close(ch byte, pos Pos) {
+	open := map[byte]byte{')': '(', ']': '[', '}': '{'}[ch]
+
+	idx := -1
+	for i := len(b.stack) - 1; i > 0; i-- {
+		n := b.stack[i]
+		if n.open == 0 {
+			break // brackets do not close across an indented block
+		}
+		if n.open == open {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return
+	}
+
+	for len(b.stack) > idx {
+		n := b.top()
+		end, closed := pos, len(b.stack)-1 == idx
+		if closed {
+			end = Pos{Line: pos.Line, Col: pos.Col + 1}
+		}
+		b.finishNode(n, end, closed)
+		b.stack = b.stack[:len(b.stack)-1]
+	}
+
+	if b.pending() && b.stage == stageFirstList {
+		b.stage = stageAfterList
+	}
+}
+
+// dedent closes the indented blocks that a line at indent is outside of
+func (b *builder) dedent(indent int) {
+	for len(b.stack) > 1 {
+		top := b.top()
+		if top.open != 0 || top.headerIndent < indent {
+			return
+		}
+		b.finishNode(top, b.lastCode, false)
+		b.stack = b.stack[:len(b.stack)-1]
+	}
+}
+
+// finishNode sets where a node ends and fills in the arguments of lists.
+// closed is set when its own bracket closed it, just before end.
+func (b *builder) finishNode(n *Node, end Pos, closed bool) {
+	n.End = end
+	if n.Parent != nil && n.Parent.Body == n {
+		n.Parent.End = end
+	}
+	if n.Kind != NodeList || n.open == 0 {
+		return
+	}
+
+	// The last argument stops before the closing bracket, or at the end
+	// of a list left open, which can be column 0 of the line after it
+	last := end
+	if closed {
+		last.Col--
+	}
+	bounds := append([]Pos{n.Start}, n.commas...)
+	bounds = append(bounds, last)
+	for i := 0; i+1 < len(bounds); i++ {
+		start := b.skipForward(Pos{Line: bounds[i].Line, Col: bounds[i].Col + 1}, bounds[i+1])
+		stop := b.skipBackward(bounds[i+1], start)
+		if start.Before(stop) {
+			b.attach(n, &Node{Kind: NodeArgument, Start: start, End: stop})
+		}
+	}
+	sort.SliceStable(n.Children, func(i, j int) bool { return n.Children[i].Start.Before(n.Children[j].Start) })
+}
+
+// skipForward moves pos past whitespace and line breaks, not beyond limit
+func (b *builder) skipForward(pos, limit Pos) Pos {
+	for pos.Before(limit) {
+		text := b.doc.Line(pos.Line)
+		if pos.Col >= len(text) {
+			pos = Pos{Line: pos.Line + 1}
+			continue
+		}
+		if text[pos.Col] != ' ' && text[pos.Col] != '\t' {
+			break
+		}
+		pos.Col++
+	}
+	return pos
+}
+
+// skipBackward moves pos back over whitespace and line breaks before it,
+// not beyond limit
+func (b *builder) skipBackward(pos, limit Pos) Pos {
+	for limit.Before(pos) {
+		if pos.Col <= 0 {
+			if pos.Line == 0 {
+				break
+			}
+			pos = Pos{Line: pos.Line - 1, Col: len(b.doc.Line(pos.Line - 1))}
+			continue
+		}
+		c := b.doc.Line(pos.Line)[pos.Col-1]
+		if c != ' ' && c != '\t' {
+			break
+		}
+		pos.Col--
+	}
+	return pos
+}
+
+// endDecl ends a declaration that has no body. Functions without a name
+// are only kept when they have a body, as closures.
+func (b *builder) endDecl(end Pos) {
+	d := b.decl
+	b.decl = nil
+	d.End = end
+	if d.Name == "" && d.Parent != nil {
+		siblings := d.Parent.Children
+		for i, c := range siblings {
+			if c == d {
+				d.Parent.Children = append(siblings[:i], siblings[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func (b *builder) attach(parent, child *Node) {
+	child.Parent = parent
+	parent.Children = append(parent.Children, child)
+}
+
+// finish closes everything still open at the end of the document
+func (b *builder) finish() *Tree {
+	lines := b.doc.LineCount()
+	end := Pos{}
+	if lines > 0 {
+		end = Pos{Line: lines - 1, Col: len(b.doc.Line(lines - 1))}
+	}
+
+	if b.decl != nil {
+		b.endDecl(end)
+	}
+	for len(b.stack) > 1 {
+		n := b.top()
+		if n.open == 0 {
+			b.finishNode(n, b.lastCode, false)
+		} else {
+			b.finishNode(n, end, false)
+		}
+		b.stack = b.stack[:len(b.stack)-1]
+	}
+	b.root.End = end
+
+	return &Tree{Root: b.root, names: b.names}
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
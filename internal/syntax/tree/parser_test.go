@@ -0,0 +1,126 @@
+package tree
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/tobibamidele/minra/internal/buffer"
+	"github.com/tobibamidele/minra/internal/syntax/languages"
+)
+
+// lines is a Document over a fixed text
+type lines []string
+
+func (l lines) Line(n int) string { return l[n] }
+func (l lines) LineCount() int    { return len(l) }
+func (l lines) Version() int      { return 1 }
+
+func TestParseUnbalancedBrackets(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		args []string // arguments of every list, in document order
+	}{
+		{"unterminated call", "x := foo(bar\n", []string{"bar"}},
+		{"unterminated call at end", "x := foo(bar", []string{"bar"}},
+		{"unterminated arguments", "foo(a,\n", []string{"a"}},
+		{"empty unterminated list", "(\n", nil},
+		{"brace closes paren", "func f() {\n\tfoo(bar\n}\n", []string{"bar"}},
+		{"brace at column 0 closes list", "x := []int{foo(1,\n}\n", []string{"1"}},
+		{"stray closers", ")\n]\n}\n", nil},
+		{"mismatched", "foo(bar]\n[a, b)\n", []string{"bar]\n[a, b", "a", "b"}},
+		{"nested unterminated", "a(b(c(\n\n", []string{"b(c(", "c("}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := lines(strings.Split(tt.text, "\n"))
+			tree := NewParser().Parse(doc, languages.NewGo())
+
+			var args []string
+			var walk func(n *Node)
+			walk = func(n *Node) {
+				if n.Kind == NodeArgument {
+					if !n.Start.Before(n.End) {
+						t.Fatalf("argument %v-%v is empty", n.Start, n.End)
+					}
+					args = append(args, text(doc, n.Start, n.End))
+				}
+				for _, c := range n.Children {
+					walk(c)
+				}
+			}
+			walk(tree.Root)
+
+			if strings.Join(args, "|") != strings.Join(tt.args, "|") {
+				t.Errorf("arguments = %q, want %q", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestParseIncremental(t *testing.T) {
+	src := "package p\n\nfunc a() {\n\tx(1, 2)\n}\n\ntype T struct {\n\tn int\n}\n\nfunc (t T) b(s string) {\n}\n"
+	edits := []struct {
+		name string
+		edit func(buf *buffer.Buffer)
+	}{
+		{"line inserted at the top", func(buf *buffer.Buffer) { buf.InsertLines(0, []string{"// c", ""}) }},
+		{"declaration renamed", func(buf *buffer.Buffer) { buf.InsertText(2, 6, "z") }},
+		{"brace left open", func(buf *buffer.Buffer) { buf.DeleteLine(4) }},
+		{"brace closed again", func(buf *buffer.Buffer) { buf.InsertLines(4, []string{"}"}) }},
+		{"comment opened", func(buf *buffer.Buffer) { buf.InsertText(5, 0, "/*") }},
+		{"lines joined", func(buf *buffer.Buffer) { buf.DeleteRange(6, 14, 7, 1) }},
+		{"undone", func(buf *buffer.Buffer) { buf.Undo() }},
+	}
+
+	buf := buffer.NewFromContent(src, "p.go")
+	p := NewParser()
+	p.Parse(buf, languages.NewGo())
+	for _, e := range edits {
+		e.edit(buf)
+		got := describe(p.Parse(buf, languages.NewGo()))
+		want := describe(NewParser().Parse(buf, languages.NewGo()))
+		if got != want {
+			t.Errorf("%s: incremental parse\n%s\nwant\n%s", e.name, got, want)
+		}
+	}
+}
+
+// describe prints every node of a tree and the names it highlights
+func describe(tree *Tree) string {
+	var sb strings.Builder
+	var walk func(n *Node, depth int)
+	walk = func(n *Node, depth int) {
+		fmt.Fprintf(&sb, "%*s%d %q %v-%v\n", depth, "", n.Kind, n.Name, n.Start, n.End)
+		for _, c := range n.Children {
+			walk(c, depth+1)
+		}
+	}
+	walk(tree.Root, 0)
+	for line := range tree.names {
+		fmt.Fprintf(&sb, "%v", tree.Tokens(line))
+	}
+	return sb.String()
+}
+
+// text returns the text of doc from start up to end
+func text(doc lines, start, end Pos) string {
+	var sb strings.Builder
+	for n := start.Line; n <= end.Line; n++ {
+		line := doc[n]
+		from, to := 0, len(line)
+		if n == start.Line {
+			from = start.Col
+		}
+		if n == end.Line {
+			to = end.Col
+		}
+		if n > start.Line {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(line[from:to])
+	}
+	return sb.String()
+}
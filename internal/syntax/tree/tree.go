@@ -0,0 +1,181 @@
+// Package tree builds a structural parse tree of a document: declarations,
+// blocks, bracketed lists and their arguments. It drives folding, the
+// symbol outline, function navigation and structural text objects.
+package tree
+
+import (
+	"sort"
+
+	"github.com/tobibamidele/minra/internal/syntax/languages"
+)
+
+// NodeKind is the kind of a parse tree node
+type NodeKind int
+
+const (
+	NodeFile     NodeKind = iota
+	NodeFunction          // a function or method declaration, or a closure
+	NodeType              // a type or class declaration
+	NodeBlock             // braces, or an indented block after a colon
+	NodeList              // parentheses or square brackets
+	NodeArgument          // one comma-separated element of a list
+)
+
+// Pos is a position in the document in buffer columns
+type Pos struct {
+	Line int
+	Col  int
+}
+
+// Before reports whether p comes before q
+func (p Pos) Before(q Pos) bool {
+	return p.Line < q.Line || (p.Line == q.Line && p.Col < q.Col)
+}
+
+// Node is a span of the document. End is exclusive. Declarations have a
+// Name, with its span, and usually a Body.
+type Node struct {
+	Kind      NodeKind
+	Name      string
+	NameStart Pos
+	NameEnd   Pos
+	Start     Pos
+	End       Pos
+	Body      *Node
+	Parent    *Node
+	Children  []*Node
+
+	headerIndent int   // indented blocks: the indent of the line opening them
+	open         byte  // bracket nodes: the opening bracket
+	commas       []Pos // list nodes: the separating commas
+}
+
+// Contains reports whether pos lies within the node
+func (n *Node) Contains(pos Pos) bool {
+	return !pos.Before(n.Start) && pos.Before(n.End)
+}
+
+// Depth returns how many declarations enclose the node
+func (n *Node) Depth() int {
+	depth := 0
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Kind == NodeFunction || p.Kind == NodeType {
+			depth++
+		}
+	}
+	return depth
+}
+
+// Arguments returns the argument children of a list node
+func (n *Node) Arguments() []*Node {
+	var args []*Node
+	for _, c := range n.Children {
+		if c.Kind == NodeArgument {
+			args = append(args, c)
+		}
+	}
+	return args
+}
+
+// Tree is the parse of a document at one version
+type Tree struct {
+	Root    *Node
+	Version int
+	names   [][]languages.Token // by line
+}
+
+// Tokens returns the highlighting the tree adds to a line: the names of
+// the functions and types declared on it
+func (t *Tree) Tokens(line int) []languages.Token {
+	if t == nil || line < 0 || line >= len(t.names) {
+		return nil
+	}
+	return t.names[line]
+}
+
+// Symbols returns the named declarations in document order
+func (t *Tree) Symbols() []*Node {
+	var symbols []*Node
+	t.walk(func(n *Node) {
+		if (n.Kind == NodeFunction || n.Kind == NodeType) && n.Name != "" {
+			symbols = append(symbols, n)
+		}
+	})
+	return symbols
+}
+
+// Functions returns the named functions in document order
+func (t *Tree) Functions() []*Node {
+	var funcs []*Node
+	for _, n := range t.Symbols() {
+		if n.Kind == NodeFunction {
+			funcs = append(funcs, n)
+		}
+	}
+	return funcs
+}
+
+// Range is a span of whole lines, inclusive
+type Range struct {
+	StartLine int
+	EndLine   int
+}
+
+// FoldRanges returns the spans that can be folded: declarations and blocks
+// covering more than one line, one per starting line, sorted
+func (t *Tree) FoldRanges() []Range {
+	var ranges []Range
+	t.walk(func(n *Node) {
+		if n.Kind == NodeFile || n.Kind == NodeArgument {
+			return
+		}
+		end := n.End.Line
+		if n.End.Col == 0 && end > n.Start.Line {
+			end--
+		}
+		if end > n.Start.Line {
+			ranges = append(ranges, Range{StartLine: n.Start.Line, EndLine: end})
+		}
+	})
+
+	// The walk is already in document order, so this sort is cheap
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].StartLine < ranges[j].StartLine })
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if last := len(merged) - 1; last >= 0 && merged[last].StartLine == r.StartLine {
+			merged[last].EndLine = max(merged[last].EndLine, r.EndLine)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// Enclosing returns the innermost node of kind containing pos, or nil
+func (t *Tree) Enclosing(kind NodeKind, pos Pos) *Node {
+	var found *Node
+	t.walk(func(n *Node) {
+		if n.Kind != kind || !n.Contains(pos) {
+			return
+		}
+		if found == nil || (!n.Start.Before(found.Start) && !found.End.Before(n.End)) {
+			found = n
+		}
+	})
+	return found
+}
+
+// walk visits every node, parents before children
+func (t *Tree) walk(visit func(n *Node)) {
+	if t == nil || t.Root == nil {
+		return
+	}
+	var rec func(n *Node)
+	rec = func(n *Node) {
+		visit(n)
+		for _, c := range n.Children {
+			rec(c)
+		}
+	}
+	rec(t.Root)
+}
@@ -73,6 +73,19 @@ func (m *Manager) ActiveTab() *Tab {
 	return m.tabs[m.activeIdx]
 }
 
+// ActivateBuffer makes the tab showing a buffer the active one. Returns
+// false if no tab shows it.
+func (m *Manager) ActivateBuffer(bufferID string) bool {
+	for i, tab := range m.tabs {
+		if tab.BufferID() == bufferID {
+			m.activeIdx = i
+			m.updateActiveStates()
+			return true
+		}
+	}
+	return false
+}
+
 // AllTabs returns all tabs
 func (m *Manager) AllTabs() []*Tab {
 	return m.tabs
@@ -4,6 +4,7 @@ import (
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/tobibamidele/minra/internal/ui"
 )
 
 // Render renders the tab bar
@@ -14,19 +15,19 @@ func (m *Manager) Render(width int) string {
 
 	var b strings.Builder
 
-	activeStyle := lipgloss.NewStyle().
-		Background(lipgloss.Color("62")).
-		Foreground(lipgloss.Color("230")).
+	activeStyle := ui.Highlight(lipgloss.NewStyle().
+		Background(ui.Colors.TabActive).
+		Foreground(ui.Colors.TabActiveForeground).
 		Padding(0, 2).
-		Bold(true)
+		Bold(true))
 
 	inactiveStyle := lipgloss.NewStyle().
-		Background(lipgloss.Color("237")).
-		Foreground(lipgloss.Color("250")).
+		Background(ui.Colors.TabInactive).
+		Foreground(ui.Colors.TabInactiveForeground).
 		Padding(0, 2)
 
 	modifiedIndicator := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("214")).
+		Foreground(ui.Colors.Warning).
 		Render("[+]")
 
 	usedWidth := 0
@@ -66,7 +67,7 @@ func (m *Manager) Render(width int) string {
 	// Fill remaining space
 	remaining := width - usedWidth
 	if remaining > 0 {
-		fillStyle := lipgloss.NewStyle().Background(lipgloss.Color("235"))
+		fillStyle := lipgloss.NewStyle().Background(ui.Colors.TabFill)
 		b.WriteString(fillStyle.Render(strings.Repeat(" ", remaining)))
 	}
 
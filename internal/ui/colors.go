@@ -2,21 +2,170 @@ package ui
 
 import "github.com/charmbracelet/lipgloss"
 
-// Color palette
-var (
-	ColorBackground = lipgloss.Color("#0b232e")
-	ColorSidebar    = lipgloss.Color("#252f3b")
-	ColorForeground = lipgloss.Color("250")
-	ColorAccent     = lipgloss.Color("205")
-	ColorSuccess    = lipgloss.Color("42")
-	ColorWarning    = lipgloss.Color("214")
-	ColorError      = lipgloss.Color("196")
-	ColorInfo       = lipgloss.Color("39")
-	ColorSelection  = lipgloss.Color("62")
-	ColorLineNumber = lipgloss.Color("240")
-	ColorComment    = lipgloss.Color("243")
-	ColorKeyword    = lipgloss.Color("205")
-	ColorType       = lipgloss.Color("117")
-	ColorString     = lipgloss.Color("150")
-	ColorNumber     = lipgloss.Color("174")
-)
+// Palette holds the colors of every part of the UI. The current theme's
+// palette, downsampled to the colors the terminal has, is in Colors.
+type Palette struct {
+	Background  lipgloss.Color
+	Foreground  lipgloss.Color
+	CurrentLine lipgloss.Color // background of the cursor line
+	NonText     lipgloss.Color // the ~ shown past the end of the buffer
+	Border      lipgloss.Color
+
+	Gutter           lipgloss.Color // line numbers
+	GutterForeground lipgloss.Color
+	GutterActive     lipgloss.Color // number of the cursor line
+
+	Selection              lipgloss.Color
+	SelectionForeground    lipgloss.Color
+	Match                  lipgloss.Color // search matches
+	MatchForeground        lipgloss.Color
+	CurrentMatch           lipgloss.Color
+	CurrentMatchForeground lipgloss.Color
+	Bracket                lipgloss.Color // the bracket pair at the cursor
+	BracketForeground      lipgloss.Color
+
+	Cursor                   lipgloss.Color
+	CursorForeground         lipgloss.Color
+	InactiveCursor           lipgloss.Color // outside insert mode
+	InactiveCursorForeground lipgloss.Color
+
+	StatusBar           lipgloss.Color
+	StatusBarForeground lipgloss.Color
+	StatusMode          lipgloss.Color // the mode and position segments
+
+	TabActive             lipgloss.Color
+	TabActiveForeground   lipgloss.Color
+	TabInactive           lipgloss.Color
+	TabInactiveForeground lipgloss.Color
+	TabFill               lipgloss.Color
+
+	Sidebar                   lipgloss.Color
+	SidebarForeground         lipgloss.Color
+	SidebarSelected           lipgloss.Color
+	SidebarSelectedForeground lipgloss.Color
+	SidebarDirectory          lipgloss.Color
+	SidebarTitle              lipgloss.Color
+
+	Widget           lipgloss.Color // palette, search and other popups
+	WidgetInput      lipgloss.Color
+	WidgetForeground lipgloss.Color
+	WidgetSelected   lipgloss.Color
+
+	Accent  lipgloss.Color
+	Success lipgloss.Color
+	Warning lipgloss.Color
+	Error   lipgloss.Color
+	Info    lipgloss.Color
+	Comment lipgloss.Color // dimmed text such as hints
+}
+
+// DefaultPalette returns the colors of the default dark theme
+func DefaultPalette() Palette {
+	return Palette{
+		Background:  "#0b232e",
+		Foreground:  "250",
+		CurrentLine: "236",
+		NonText:     "240",
+		Border:      "240",
+
+		Gutter:           "#232e33",
+		GutterForeground: "#ffffff",
+		GutterActive:     "220",
+
+		Selection:              "62",
+		SelectionForeground:    "230",
+		Match:                  "94",
+		MatchForeground:        "230",
+		CurrentMatch:           "214",
+		CurrentMatchForeground: "0",
+		Bracket:                "238",
+		BracketForeground:      "220",
+
+		Cursor:                   "230",
+		CursorForeground:         "0",
+		InactiveCursor:           "240",
+		InactiveCursorForeground: "230",
+
+		StatusBar:           "#252f3b",
+		StatusBarForeground: "230",
+		StatusMode:          "#9c9b9a",
+
+		TabActive:             "62",
+		TabActiveForeground:   "230",
+		TabInactive:           "237",
+		TabInactiveForeground: "250",
+		TabFill:               "235",
+
+		Sidebar:                   "#252f3b",
+		SidebarForeground:         "250",
+		SidebarSelected:           "240",
+		SidebarSelectedForeground: "230",
+		SidebarDirectory:          "39",
+		SidebarTitle:              "205",
+
+		Widget:           "235",
+		WidgetInput:      "236",
+		WidgetForeground: "230",
+		WidgetSelected:   "238",
+
+		Accent:  "205",
+		Success: "42",
+		Warning: "214",
+		Error:   "196",
+		Info:    "39",
+		Comment: "243",
+	}
+}
+
+// Fields returns the palette's colors by the names theme files use
+func (p *Palette) Fields() map[string]*lipgloss.Color {
+	return map[string]*lipgloss.Color{
+		"background":                  &p.Background,
+		"foreground":                  &p.Foreground,
+		"current_line":                &p.CurrentLine,
+		"non_text":                    &p.NonText,
+		"border":                      &p.Border,
+		"gutter":                      &p.Gutter,
+		"gutter_foreground":           &p.GutterForeground,
+		"gutter_active":               &p.GutterActive,
+		"selection":                   &p.Selection,
+		"selection_foreground":        &p.SelectionForeground,
+		"match":                       &p.Match,
+		"match_foreground":            &p.MatchForeground,
+		"current_match":               &p.CurrentMatch,
+		"current_match_foreground":    &p.CurrentMatchForeground,
+		"bracket":                     &p.Bracket,
+		"bracket_foreground":          &p.BracketForeground,
+		"cursor":                      &p.Cursor,
+		"cursor_foreground":           &p.CursorForeground,
+		"inactive_cursor":             &p.InactiveCursor,
+		"inactive_cursor_foreground":  &p.InactiveCursorForeground,
+		"status_bar":                  &p.StatusBar,
+		"status_bar_foreground":       &p.StatusBarForeground,
+		"status_mode":                 &p.StatusMode,
+		"tab_active":                  &p.TabActive,
+		"tab_active_foreground":       &p.TabActiveForeground,
+		"tab_inactive":                &p.TabInactive,
+		"tab_inactive_foreground":     &p.TabInactiveForeground,
+		"tab_fill":                    &p.TabFill,
+		"sidebar":                     &p.Sidebar,
+		"sidebar_foreground":          &p.SidebarForeground,
+		"sidebar_selected":            &p.SidebarSelected,
+		"sidebar_selected_foreground": &p.SidebarSelectedForeground,
+		"sidebar_directory":           &p.SidebarDirectory,
+		"sidebar_title":               &p.SidebarTitle,
+		"widget":                      &p.Widget,
+		"widget_input":                &p.WidgetInput,
+		"widget_foreground":           &p.WidgetForeground,
+		"widget_selected":             &p.WidgetSelected,
+		"accent":                      &p.Accent,
+		"success":                     &p.Success,
+		"warning":                     &p.Warning,
+		"error":                       &p.Error,
+		"info":                        &p.Info,
+		"comment":                     &p.Comment,
+	}
+}
+
+// Colors is the palette of the current theme. Change it with Apply.
+var Colors = DefaultPalette()
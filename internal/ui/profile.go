@@ -0,0 +1,206 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// ColorModes are the values of the color_mode setting. auto detects the
+// terminal's colors from the environment; mono uses no color at all,
+// only bold, underline and reverse video.
+var ColorModes = []string{"auto", "truecolor", "256", "16", "mono"}
+
+// ProfileFor returns the color profile a color_mode value asks for
+func ProfileFor(mode string, getenv func(string) string) (termenv.Profile, error) {
+	switch mode {
+	case "", "auto":
+		return DetectProfile(getenv), nil
+	case "truecolor", "24bit":
+		return termenv.TrueColor, nil
+	case "256":
+		return termenv.ANSI256, nil
+	case "16":
+		return termenv.ANSI, nil
+	case "mono":
+		return termenv.Ascii, nil
+	}
+	return termenv.Ascii, fmt.Errorf("unknown color mode %q (available: %s)", mode, strings.Join(ColorModes, ", "))
+}
+
+// DetectProfile works out how many colors the terminal shows from the
+// environment. NO_COLOR and dumb terminals get none; COLORTERM and the
+// terminals known for it get true color; a TERM ending in 256color gets
+// 256 colors and anything else the 16 every terminal has.
+func DetectProfile(getenv func(string) string) termenv.Profile {
+	if getenv("NO_COLOR") != "" {
+		return termenv.Ascii
+	}
+	term := strings.ToLower(getenv("TERM"))
+	if term == "dumb" {
+		return termenv.Ascii
+	}
+
+	switch strings.ToLower(getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return termenv.TrueColor
+	}
+	for _, t := range []string{"direct", "truecolor", "kitty", "alacritty", "wezterm", "ghostty", "foot"} {
+		if strings.Contains(term, t) {
+			return termenv.TrueColor
+		}
+	}
+	if getenv("WT_SESSION") != "" {
+		return termenv.TrueColor
+	}
+
+	if strings.Contains(term, "256color") {
+		return termenv.ANSI256
+	}
+	return termenv.ANSI
+}
+
+// profile is the color profile styles are built for
+var profile = termenv.TrueColor
+
+// Profile returns the current color profile
+func Profile() termenv.Profile {
+	return profile
+}
+
+// SetProfile renders with p from now on and rebuilds the palette and
+// shared styles for it
+func SetProfile(p termenv.Profile) {
+	profile = p
+	lipgloss.SetColorProfile(p)
+	Apply(source)
+}
+
+// Monochrome reports whether the terminal gets no colors
+func Monochrome() bool {
+	return profile == termenv.Ascii
+}
+
+// Highlight marks s as standing out from its surroundings. Colors do
+// that where there are any; in monochrome it is reverse video.
+func Highlight(s lipgloss.Style) lipgloss.Style {
+	if Monochrome() {
+		return s.Reverse(true)
+	}
+	return s
+}
+
+// Downsample returns the color of the current profile nearest to c: a
+// 256-color index, one of the 16 ANSI colors, or none in monochrome
+func Downsample(c lipgloss.Color) lipgloss.Color {
+	if c == "" || profile == termenv.TrueColor {
+		return c
+	}
+	if profile == termenv.Ascii {
+		return ""
+	}
+
+	rgb, index, ok := parseColor(string(c))
+	if !ok {
+		return c
+	}
+	switch {
+	case profile == termenv.ANSI256 && index >= 0:
+		return c
+	case profile == termenv.ANSI && index >= 0 && index < 16:
+		return c
+	case profile == termenv.ANSI256:
+		return lipgloss.Color(strconv.Itoa(nearest(rgb, 16, 256)))
+	default:
+		return lipgloss.Color(strconv.Itoa(nearest(rgb, 0, 16)))
+	}
+}
+
+// DownsampleStyle downsamples the colors of s. In monochrome only bold
+// and underline are kept.
+func DownsampleStyle(s lipgloss.Style) lipgloss.Style {
+	if Monochrome() {
+		return lipgloss.NewStyle().Bold(s.GetBold()).Underline(s.GetUnderline())
+	}
+	if fg, ok := s.GetForeground().(lipgloss.Color); ok {
+		s = s.Foreground(Downsample(fg))
+	}
+	if bg, ok := s.GetBackground().(lipgloss.Color); ok {
+		s = s.Background(Downsample(bg))
+	}
+	return s
+}
+
+// Downsample returns p with every color downsampled to the current
+// profile
+func (p Palette) Downsample() Palette {
+	for _, c := range p.Fields() {
+		*c = Downsample(*c)
+	}
+	return p
+}
+
+// rgb is a color as red, green and blue from 0 to 255
+type rgb [3]int
+
+// parseColor reads a #rgb or #rrggbb color or an ANSI color number.
+// index is the number, or -1 for hex colors.
+func parseColor(s string) (c rgb, index int, ok bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 0 || n > 255 {
+			return c, 0, false
+		}
+		return xterm[n], n, true
+	}
+	if !strings.HasPrefix(s, "#") {
+		return c, 0, false
+	}
+	hex := s[1:]
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return c, 0, false
+	}
+	return rgb{int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff)}, -1, true
+}
+
+// nearest returns the index in [from, to) of the xterm color closest to
+// c, by the "redmean" approximation of perceived distance
+func nearest(c rgb, from, to int) int {
+	best, bestDist := from, -1
+	for i := from; i < to; i++ {
+		p := xterm[i]
+		mean := (c[0] + p[0]) / 2
+		dr, dg, db := c[0]-p[0], c[1]-p[1], c[2]-p[2]
+		dist := (512+mean)*dr*dr>>8 + 4*dg*dg + (767-mean)*db*db>>8
+		if bestDist < 0 || dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
+
+// xterm is the default xterm palette: the 16 ANSI colors, the 6×6×6 color
+// cube and the 24 grays
+var xterm = func() [256]rgb {
+	p := [256]rgb{
+		{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+		{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+		{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+		{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+	}
+	levels := [6]int{0, 95, 135, 175, 215, 255}
+	for i := 0; i < 216; i++ {
+		p[16+i] = rgb{levels[i/36], levels[i/6%6], levels[i%6]}
+	}
+	for i := 0; i < 24; i++ {
+		v := 8 + 10*i
+		p[232+i] = rgb{v, v, v}
+	}
+	return p
+}()
@@ -4,17 +4,12 @@ import "github.com/charmbracelet/lipgloss"
 
 var (
 	// Border styles
-	BorderStyle = lipgloss.NewStyle().
-			Border(lipgloss.NormalBorder()).
-			BorderForeground(lipgloss.Color("240"))
+	BorderStyle lipgloss.Style
 
 	// Selection styles
-	SelectedStyle = lipgloss.NewStyle().
-			Background(lipgloss.Color("62")).
-			Foreground(lipgloss.Color("230"))
+	SelectedStyle lipgloss.Style
 
-	InactiveStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("240"))
+	InactiveStyle lipgloss.Style
 
 	// Text styles
 	BoldStyle = lipgloss.NewStyle().
@@ -24,17 +19,49 @@ var (
 			Italic(true)
 
 	// Status bar styles
-	StatusBarStyle = lipgloss.NewStyle().
-			Background(lipgloss.Color("240")).
-			Foreground(lipgloss.Color("230"))
+	StatusBarStyle lipgloss.Style
 
 	// Active cursor style
-	ActiveCursorStyle = lipgloss.NewStyle().
-				Background(lipgloss.Color("230")).
-				Foreground(lipgloss.Color("0"))
+	ActiveCursorStyle lipgloss.Style
 
 	// Inactive cursor style
-	InactiveCursorStyle = lipgloss.NewStyle().
-				Background(lipgloss.Color("240")).
-				Foreground(lipgloss.Color("230"))
+	InactiveCursorStyle lipgloss.Style
 )
+
+// source is the palette last applied, before downsampling
+var source Palette
+
+func init() {
+	Apply(Colors)
+}
+
+// Apply makes p the current palette and rebuilds the shared styles from
+// it. Colors the terminal lacks are replaced by the nearest it has.
+func Apply(p Palette) {
+	source = p
+	p = p.Downsample()
+	Colors = p
+
+	BorderStyle = lipgloss.NewStyle().
+		Border(lipgloss.NormalBorder()).
+		BorderForeground(p.Border)
+
+	SelectedStyle = Highlight(lipgloss.NewStyle().
+		Background(p.Selection).
+		Foreground(p.SelectionForeground))
+
+	InactiveStyle = lipgloss.NewStyle().
+		Foreground(p.NonText)
+
+	StatusBarStyle = Highlight(lipgloss.NewStyle().
+		Background(p.StatusBar).
+		Foreground(p.StatusBarForeground))
+
+	ActiveCursorStyle = Highlight(lipgloss.NewStyle().
+		Background(p.Cursor).
+		Foreground(p.CursorForeground))
+
+	InactiveCursorStyle = Highlight(lipgloss.NewStyle().
+		Background(p.InactiveCursor).
+		Foreground(p.InactiveCursorForeground))
+}
@@ -0,0 +1,72 @@
+package viewport
+
+import "sort"
+
+// Fold is a foldable block of lines, Start to End inclusive. Closed folds
+// show only their first line.
+type Fold struct {
+	Start  int
+	End    int
+	Closed bool
+}
+
+// SetFolds sets the folds of the buffer, sorted by Start. Render skips the
+// lines hidden by closed folds and marks where folds start in the gutter.
+func (v *Viewport) SetFolds(folds []Fold) {
+	v.foldStarts = make(map[int]bool, len(folds))
+	v.closed = v.closed[:0]
+	for _, f := range folds {
+		if _, seen := v.foldStarts[f.Start]; !seen || f.Closed {
+			v.foldStarts[f.Start] = f.Closed
+		}
+		if !f.Closed {
+			continue
+		}
+		// Only the outermost closed folds matter for what is hidden
+		if n := len(v.closed); n > 0 && f.Start <= v.closed[n-1].End {
+			v.closed[n-1].End = max(v.closed[n-1].End, f.End)
+			continue
+		}
+		v.closed = append(v.closed, f)
+	}
+}
+
+// closedAt returns the outermost closed fold containing line
+func (v *Viewport) closedAt(line int) (Fold, bool) {
+	i := sort.Search(len(v.closed), func(i int) bool { return v.closed[i].Start > line }) - 1
+	if i >= 0 && line <= v.closed[i].End {
+		return v.closed[i], true
+	}
+	return Fold{}, false
+}
+
+// Hidden reports whether line is inside a closed fold, below its first
+// line
+func (v *Viewport) Hidden(line int) bool {
+	f, ok := v.closedAt(line)
+	return ok && line > f.Start
+}
+
+// VisibleLine returns the line shown for line: the first line of the
+// closed fold it is in, or line itself
+func (v *Viewport) VisibleLine(line int) int {
+	if f, ok := v.closedAt(line); ok {
+		return f.Start
+	}
+	return line
+}
+
+// NextVisibleLine returns the first line shown after line, which is past
+// the end of the buffer at the bottom
+func (v *Viewport) NextVisibleLine(line int) int {
+	if f, ok := v.closedAt(line); ok {
+		return f.End + 1
+	}
+	return line + 1
+}
+
+// PrevVisibleLine returns the last line shown before line, or -1 at the
+// top
+func (v *Viewport) PrevVisibleLine(line int) int {
+	return v.VisibleLine(line - 1)
+}
@@ -0,0 +1,24 @@
+package viewport
+
+// Match is a search match highlighted on a line, as buffer columns
+// [Start, End)
+type Match struct {
+	Start   int
+	End     int
+	Current bool // the match the cursor is on
+}
+
+// Matcher finds the matches to highlight on a line. It is called for the
+// visible lines each time the viewport is drawn, so matches follow edits.
+type Matcher func(line int, text string) []Match
+
+// SetMatcher sets how search matches are found, or clears the highlighting
+// if m is nil
+func (v *Viewport) SetMatcher(m Matcher) {
+	v.matcher = m
+}
+
+// Matcher returns how search matches are found, nil if none are shown
+func (v *Viewport) Matcher() Matcher {
+	return v.matcher
+}
@@ -10,6 +10,14 @@ const (
 	ModeCommand
 	ModeRename
 	ModeSearch
+	ModeHistory
+	ModePalette
+	ModeReplace // confirming each replacement of a search
+	ModeGrep    // the workspace search panel
+	ModePreview // the preview of a replace across the workspace
+	ModeFinder  // the fuzzy file finder
+	ModeCreate  // asking for the path of a file to create or move
+	ModeDialog  // confirming an action, such as deleting a file
 )
 
 func (m Mode) String() string {
@@ -28,6 +36,22 @@ func (m Mode) String() string {
 		return "RENAME"
 	case ModeSearch:
 		return "SEARCH"
+	case ModeHistory:
+		return "HISTORY"
+	case ModePalette:
+		return "PALETTE"
+	case ModeReplace:
+		return "REPLACE"
+	case ModeGrep:
+		return "GREP"
+	case ModePreview:
+		return "PREVIEW"
+	case ModeFinder:
+		return "FINDER"
+	case ModeCreate:
+		return "CREATE"
+	case ModeDialog:
+		return "DIALOG"
 	default:
 		return "UNKNOWN"
 	}
@@ -3,45 +3,97 @@ package viewport
 import (
 	"fmt"
 	"strings"
-	"unicode/utf8"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/tobibamidele/minra/internal/cursor"
 	"github.com/tobibamidele/minra/internal/syntax"
+	"github.com/tobibamidele/minra/internal/syntax/languages"
 	"github.com/tobibamidele/minra/internal/syntax/matchers"
 	"github.com/tobibamidele/minra/internal/ui"
-	"github.com/tobibamidele/minra/pkg/utils"
 )
 
+// cellFlags are the highlights layered over a cell's syntax color
+type cellFlags uint8
+
+const (
+	cellMatch cellFlags = 1 << iota
+	cellCurrentMatch
+	cellSelected
+	cellBracket
+	cellCursor
+)
+
+// cell is one screen column of a line
+type cell struct {
+	ch    rune
+	col   int // buffer column of the character the cell shows
+	kind  languages.TokenKind
+	flags cellFlags
+}
+
+// cellLook is what decides a cell's style; runs of cells that look the
+// same are rendered together
+type cellLook struct {
+	kind    languages.TokenKind
+	flags   cellFlags
+	current bool // on the cursor line
+}
+
+// bracketPos is a bracket highlighted because the cursor is on its pair
+type bracketPos struct {
+	line, col int
+}
+
 func (v *Viewport) Render(highlighter *syntax.Highlighter, cur *cursor.Cursor, mode Mode) string {
 	var b strings.Builder
 
-	startLine := v.scrollY
-	endLine := v.scrollY + v.height
-	if endLine > v.buffer.LineCount() {
-		endLine = v.buffer.LineCount()
-	}
-
 	// --- Styles ---
-	currentLineStyle := lipgloss.NewStyle().Background(lipgloss.Color("236"))
-	lineNumStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Background(lipgloss.Color("#232e33"))
+	lineNumStyle := lipgloss.NewStyle().Foreground(ui.Colors.GutterForeground).Background(ui.Colors.Gutter)
 	activeLineNumStyle := lipgloss.NewStyle().
-		Background(lipgloss.Color("236")).
-		Foreground(lipgloss.Color("220")).
+		Background(ui.Colors.CurrentLine).
+		Foreground(ui.Colors.GutterActive).
 		Bold(true)
 
-	bracketStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("220")).
-		Background(lipgloss.Color("238")).
-		Bold(true)
+	hasCursor := mode == ModeInsert || mode == ModeNormal || mode == ModeVisual
+
+	// --- Bracket matching, possibly across lines ---
+	var brackets []bracketPos
+	if hasCursor {
+		if line, col, ok := matchers.FindMatchingBracketAcross(v.buffer, cur.Line(), cur.Col()); ok {
+			brackets = []bracketPos{{cur.Line(), cur.Col()}, {line, col}}
+		}
+	}
 
-	for lineNum := startLine; lineNum < endLine; lineNum++ {
+	foldStyle := lipgloss.NewStyle().Foreground(ui.Colors.NonText)
+
+	styles := make(map[cellLook]lipgloss.Style)
+	styleFor := func(look cellLook) lipgloss.Style {
+		if s, ok := styles[look]; ok {
+			return s
+		}
+		s := v.cellStyle(highlighter, look, mode)
+		styles[look] = s
+		return s
+	}
+
+	// Closed folds take one row, so the rows shown are counted rather than
+	// the lines
+	rows := 0
+	for lineNum := v.VisibleLine(v.scrollY); lineNum < v.buffer.LineCount() && rows < v.height; lineNum = v.NextVisibleLine(lineNum) {
+		rows++
 		rawLine := v.buffer.Line(lineNum)
-		isCursorLine := lineNum == cur.Line()
+		isCursorLine := lineNum == v.VisibleLine(cur.Line())
 
-		// --- Line numbers ---
+		// --- Line numbers, with ▾ on open folds and ▸ on closed ones ---
 		if v.lineNumbers {
-			lineNumStr := fmt.Sprintf("%4d ", lineNum+1)
+			marker := " "
+			if closed, ok := v.foldStarts[lineNum]; ok {
+				marker = "▾"
+				if closed {
+					marker = "▸"
+				}
+			}
+			lineNumStr := fmt.Sprintf("%4d%s", lineNum+1, marker)
 			if isCursorLine {
 				b.WriteString(activeLineNumStyle.Render(lineNumStr))
 			} else {
@@ -49,82 +101,94 @@ func (v *Viewport) Render(highlighter *syntax.Highlighter, cur *cursor.Cursor, m
 			}
 		}
 
-		// Expand tabs before syntax highlighting
-		displayLine := v.expandTabs(rawLine)
+		cells := v.lineCells(rawLine)
 
-		// --- Bracket matching on raw text ---
-		bracketA, bracketB := -1, -1
-		if isCursorLine && (mode == ModeInsert || mode == ModeNormal) {
-			runes := []rune(rawLine)
-			if cur.Col() >= 0 && cur.Col() < len(runes) && matchers.IsBracket(runes[cur.Col()]) {
-				match := matchers.FindMatchingBracket(rawLine, cur.Col())
-				if match != -1 {
-					bracketA, bracketB = cur.Col(), match
+		// --- Syntax ---
+		if highlighter != nil {
+			for _, t := range highlighter.Tokens(v.buffer, lineNum) {
+				for i := range cells {
+					if cells[i].col >= t.Start && cells[i].col < t.End {
+						cells[i].kind = t.Kind
+					}
 				}
 			}
 		}
 
-		// --- Apply syntax highlighting ---
-		if highlighter != nil {
-			displayLine = highlighter.Highlight(displayLine)
+		// --- Search matches ---
+		if v.matcher != nil {
+			for _, m := range v.matcher(lineNum, rawLine) {
+				flags := cellMatch
+				if m.Current {
+					flags |= cellCurrentMatch
+				}
+				markCells(cells, m.Start, m.End, flags)
+			}
 		}
 
-		// --- Highlight matching brackets (ANSI safe) ---
-		if bracketA != -1 && bracketB != -1 {
-			displayLine = applyBracketHighlight(displayLine, bracketA, bracketB, bracketStyle)
+		// --- Visual selection, which may cover the line break ---
+		if v.selection != nil {
+			if start, end, ok := v.selection.LineRange(lineNum, len(rawLine)); ok {
+				cells = padCells(cells, end, len(rawLine))
+				markCells(cells, start, end, cellSelected)
+			}
 		}
 
-		// --- Scroll horizontally (ANSI safe) ---
-		visibleLine := utils.SafeSliceANSI(displayLine, v.scrollX, v.scrollX+v.width)
-
-		// --- Draw cursor ---
-		if isCursorLine && (mode == ModeInsert || mode == ModeNormal) {
-			displayCursorPos := v.calculateDisplayCol(cur)
-			relativeCursorPos := displayCursorPos - v.scrollX
-			plain := utils.StripANSI(visibleLine)
-			runeCount := utf8.RuneCountInString(plain)
-			if relativeCursorPos > runeCount {
-				relativeCursorPos = runeCount
+		// --- Matching brackets ---
+		for _, p := range brackets {
+			if p.line == lineNum {
+				markCells(cells, p.col, p.col+1, cellBracket)
 			}
+		}
 
-			before := utils.SafeSliceANSI(visibleLine, 0, relativeCursorPos)
-			after := utils.SafeSliceANSI(visibleLine, relativeCursorPos+1, utils.VisibleWidth(visibleLine))
-
-			var cursorStyle lipgloss.Style
-			if mode == ModeInsert {
-				cursorStyle = ui.ActiveCursorStyle
-			} else {
-				cursorStyle = ui.InactiveCursorStyle
+		// --- Cursor, on the first cell of its character ---
+		if isCursorLine && hasCursor {
+			cells = padCells(cells, cur.Col()+1, len(rawLine))
+			for i := range cells {
+				if cells[i].col == cur.Col() {
+					cells[i].flags |= cellCursor
+					break
+				}
 			}
+		}
 
-			cursorChar := " "
-			if relativeCursorPos < runeCount {
-				runes := []rune(plain)
-				cursorChar = string(runes[relativeCursorPos])
+		// --- Scroll horizontally, then draw runs of same-looking cells ---
+		visible := cells[min(v.scrollX, len(cells)):min(v.scrollX+v.width, len(cells))]
+		for i := 0; i < len(visible); {
+			look := cellLook{kind: visible[i].kind, flags: visible[i].flags, current: isCursorLine}
+			var run strings.Builder
+			j := i
+			for ; j < len(visible) && visible[j].kind == look.kind && visible[j].flags == look.flags; j++ {
+				run.WriteRune(visible[j].ch)
 			}
+			b.WriteString(styleFor(look).Render(run.String()))
+			i = j
+		}
 
-			visibleLine = before + cursorStyle.Render(cursorChar) + after
+		// --- How many lines a closed fold hides ---
+		used := len(visible)
+		if f, ok := v.closedAt(lineNum); ok && f.Start == lineNum {
+			summary := fmt.Sprintf(" ··· %d lines", f.End-f.Start)
+			if room := v.Width() - used; room > 0 {
+				summary = string([]rune(summary)[:min(len([]rune(summary)), room)])
+				b.WriteString(foldStyle.Inherit(styleFor(cellLook{current: isCursorLine})).Render(summary))
+				used += len([]rune(summary))
+			}
 		}
 
-		if isCursorLine {
-			visibleLine = currentLineStyle.Width(v.Width()).Render(visibleLine)
-		} else { // Render lines with background
-			visibleLine = lipgloss.NewStyle().
-				Background(ui.ColorBackground).
-				Width(v.Width()).
-				Render(visibleLine)
+		// --- Fill the rest of the line with its background ---
+		if pad := v.Width() - used; pad > 0 {
+			b.WriteString(styleFor(cellLook{current: isCursorLine}).Render(strings.Repeat(" ", pad)))
 		}
 
-		b.WriteString(visibleLine)
 		b.WriteString("\n")
 	}
 
 	// --- Fill empty space ---
-	for i := endLine - startLine; i < v.height; i++ {
+	for i := rows; i < v.height; i++ {
 		if v.lineNumbers {
 			b.WriteString(lineNumStyle.Render("   ~ "))
 		} else {
-			b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render("~"))
+			b.WriteString(lipgloss.NewStyle().Foreground(ui.Colors.NonText).Background(ui.Colors.Background).Render("~"))
 		}
 		b.WriteString("\n")
 	}
@@ -132,45 +196,81 @@ func (v *Viewport) Render(highlighter *syntax.Highlighter, cur *cursor.Cursor, m
 	return b.String()
 }
 
-// --- Highlight helper ---
+// cellStyle composes the style of a cell: the line background, the syntax
+// color, then search matches, selection, brackets and the cursor on top.
+// Without colors, matches are underlined and the current match, the
+// selection and the cursor are in reverse video.
+func (v *Viewport) cellStyle(highlighter *syntax.Highlighter, look cellLook, mode Mode) lipgloss.Style {
+	if look.flags&cellCursor != 0 {
+		if mode == ModeInsert {
+			return ui.ActiveCursorStyle
+		}
+		return ui.InactiveCursorStyle
+	}
 
-func applyBracketHighlight(line string, idx1, idx2 int, style lipgloss.Style) string {
-	runes := []rune(utils.StripANSI(line))
-	if idx1 > idx2 {
-		idx1, idx2 = idx2, idx1
+	background := ui.Colors.Background
+	if look.current {
+		background = ui.Colors.CurrentLine
 	}
-	if idx1 < 0 || idx2 >= len(runes) {
-		return line
+	style := lipgloss.NewStyle().Background(background).Foreground(ui.Colors.Foreground)
+	if highlighter != nil && look.kind != languages.TokenText {
+		style = style.Inherit(highlighter.Style(look.kind))
 	}
 
-	// Convert visible indices to ANSI-safe slices
-	beforeFirst := utils.SafeSliceANSI(line, 0, idx1)
-	first := utils.SafeSliceANSI(line, idx1, idx1+1)
-	middle := utils.SafeSliceANSI(line, idx1+1, idx2)
-	second := utils.SafeSliceANSI(line, idx2, idx2+1)
-	after := utils.SafeSliceANSI(line, idx2+1, utils.VisibleWidth(line))
-
-	// Apply bracket style
-	first = style.Render(first)
-	second = style.Render(second)
-
-	return beforeFirst + first + middle + second + after
+	switch {
+	case look.flags&cellCurrentMatch != 0:
+		style = ui.Highlight(style.Foreground(ui.Colors.CurrentMatchForeground).Background(ui.Colors.CurrentMatch))
+	case look.flags&cellMatch != 0:
+		style = style.Foreground(ui.Colors.MatchForeground).Background(ui.Colors.Match)
+		if ui.Monochrome() {
+			style = style.Underline(true)
+		}
+	}
+	if look.flags&cellSelected != 0 {
+		style = ui.Highlight(style.Foreground(ui.Colors.SelectionForeground).Background(ui.Colors.Selection))
+	}
+	if look.flags&cellBracket != 0 {
+		style = style.Foreground(ui.Colors.BracketForeground).Background(ui.Colors.Bracket).Bold(true)
+		if ui.Monochrome() {
+			style = style.Underline(true)
+		}
+	}
+	return style
 }
 
-func (v *Viewport) expandTabs(line string) string {
-	var result strings.Builder
-	col := 0
-	for _, ch := range line {
+// lineCells lays out line as screen cells, expanding tabs
+func (v *Viewport) lineCells(line string) []cell {
+	cells := make([]cell, 0, len(line))
+	for col, ch := range line {
 		if ch == '\t' {
-			spacesToAdd := v.tabSize - (col % v.tabSize)
-			for i := 0; i < spacesToAdd; i++ {
-				result.WriteRune(' ')
+			for n := v.tabSize - (len(cells) % v.tabSize); n > 0; n-- {
+				cells = append(cells, cell{ch: ' ', col: col})
 			}
-			col += spacesToAdd
-		} else {
-			result.WriteRune(ch)
-			col++
+			continue
+		}
+		cells = append(cells, cell{ch: ch, col: col})
+	}
+	return cells
+}
+
+// padCells adds blank cells past the end of a line of lineLen bytes until
+// there is one for every column before end
+func padCells(cells []cell, end, lineLen int) []cell {
+	next := lineLen
+	if n := len(cells); n > 0 && cells[n-1].col >= next {
+		next = cells[n-1].col + 1
+	}
+	for ; next < end; next++ {
+		cells = append(cells, cell{ch: ' ', col: next})
+	}
+	return cells
+}
+
+// markCells sets flags on the cells showing columns [start, end)
+func markCells(cells []cell, start, end int, flags cellFlags) {
+	for i := range cells {
+		if cells[i].col >= start && cells[i].col < end {
+			cells[i].flags |= flags
 		}
 	}
-	return result.String()
 }
@@ -4,13 +4,15 @@ import "github.com/tobibamidele/minra/internal/cursor"
 
 // AdjustScroll adjusts scroll to keep cursor visible
 func (v *Viewport) AdjustScroll(cur *cursor.Cursor) {
-	// Vertical scroll
-	if cur.Line() < v.scrollY {
-		v.scrollY = cur.Line()
+	// Vertical scroll, counting a closed fold as one line
+	line := v.VisibleLine(cur.Line())
+	if line < v.scrollY {
+		v.scrollY = line
 	}
-	if cur.Line() >= v.scrollY+v.height {
-		v.scrollY = cur.Line() - v.height + 1
+	if top := v.topFor(line, v.height); v.scrollY < top {
+		v.scrollY = top
 	}
+	v.scrollY = v.VisibleLine(v.scrollY)
 
 	// Horizontal scroll
 	displayCol := v.calculateDisplayCol(cur)
@@ -22,46 +24,61 @@ func (v *Viewport) AdjustScroll(cur *cursor.Cursor) {
 	}
 }
 
+// topFor returns the top line that puts line rows lines down the screen,
+// or as far down as the top of the buffer allows
+func (v *Viewport) topFor(line, rows int) int {
+	top := line
+	for i := 1; i < rows && top > 0; i++ {
+		top = v.PrevVisibleLine(top)
+	}
+	return top
+}
+
 // ScrollUp scrolls up by lines
 func (v *Viewport) ScrollUp(lines int) {
-	v.scrollY -= lines
-	if v.scrollY < 0 {
-		v.scrollY = 0
+	for ; lines > 0 && v.scrollY > 0; lines-- {
+		v.scrollY = v.PrevVisibleLine(v.scrollY)
 	}
 }
 
 // ScrollDown scrolls down by lines
 func (v *Viewport) ScrollDown(lines int) {
-	v.scrollY += lines
-	maxScroll := v.buffer.LineCount() - v.height
-	if maxScroll < 0 {
-		maxScroll = 0
-	}
-	if v.scrollY > maxScroll {
-		v.scrollY = maxScroll
+	maxScroll := v.topFor(v.VisibleLine(v.buffer.LineCount()-1), v.height)
+	for ; lines > 0 && v.scrollY < maxScroll; lines-- {
+		v.scrollY = v.NextVisibleLine(v.scrollY)
 	}
 }
 
 // CenterCursor centers cursor in viewport
 func (v *Viewport) CenterCursor(cur *cursor.Cursor) {
-	v.scrollY = cur.Line() - v.height/2
-	if v.scrollY < 0 {
-		v.scrollY = 0
-	}
+	v.scrollY = v.topFor(v.VisibleLine(cur.Line()), v.height/2+1)
 }
 
 // calculateDisplayCol calculates display column accounting for tabs
 func (v *Viewport) calculateDisplayCol(cur *cursor.Cursor) int {
-	line := v.buffer.Line(cur.Line())
+	return v.displayCol(v.buffer.Line(cur.Line()), cur.Col())
+}
+
+// displayCol converts a buffer column on line to a display column
+func (v *Viewport) displayCol(line string, col int) int {
 	displayCol := 0
 
-	for i := 0; i < cur.Col() && i < len(line); i++ {
-		if line[i] == '\t' {
+	for i, ch := range line {
+		if i >= col {
+			break
+		}
+		if ch == '\t' {
 			displayCol += v.tabSize - (displayCol % v.tabSize)
 		} else {
 			displayCol++
 		}
 	}
 
+	// Columns past the end of the line (block selections, the line break)
+	// take one cell each
+	if col > len(line) {
+		displayCol += col - len(line)
+	}
+
 	return displayCol
 }
@@ -0,0 +1,66 @@
+package viewport
+
+import "github.com/tobibamidele/minra/internal/clipboard"
+
+// Selection is a visual mode selection between an anchor and the cursor.
+// Columns are buffer columns.
+type Selection struct {
+	Mode       clipboard.Mode
+	AnchorLine int
+	AnchorCol  int
+	CursorLine int
+	CursorCol  int
+}
+
+// Bounds returns the selection's start and end in document order. For
+// block selections the columns are the left and right edges.
+func (s *Selection) Bounds() (startLine, startCol, endLine, endCol int) {
+	startLine, startCol = s.AnchorLine, s.AnchorCol
+	endLine, endCol = s.CursorLine, s.CursorCol
+
+	if s.Mode == clipboard.ModeBlock {
+		return min(startLine, endLine), min(startCol, endCol), max(startLine, endLine), max(startCol, endCol)
+	}
+	if startLine > endLine || (startLine == endLine && startCol > endCol) {
+		startLine, endLine = endLine, startLine
+		startCol, endCol = endCol, startCol
+	}
+	return startLine, startCol, endLine, endCol
+}
+
+// LineRange returns the columns [start, end) selected on line, whose text
+// has lineLen bytes. end may exceed lineLen when the selection covers the
+// line break or extends past a short line in block mode.
+func (s *Selection) LineRange(line, lineLen int) (start, end int, ok bool) {
+	startLine, startCol, endLine, endCol := s.Bounds()
+	if line < startLine || line > endLine {
+		return 0, 0, false
+	}
+
+	switch s.Mode {
+	case clipboard.ModeLine:
+		return 0, lineLen + 1, true
+	case clipboard.ModeBlock:
+		return startCol, endCol + 1, true
+	}
+
+	start, end = 0, lineLen+1
+	if line == startLine {
+		start = startCol
+	}
+	if line == endLine {
+		end = endCol + 1
+	}
+	return start, end, true
+}
+
+// SetSelection sets the selection highlighted by Render, or clears it if
+// sel is nil
+func (v *Viewport) SetSelection(sel *Selection) {
+	v.selection = sel
+}
+
+// Selection returns the current selection, or nil
+func (v *Viewport) Selection() *Selection {
+	return v.selection
+}
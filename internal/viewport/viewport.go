@@ -16,6 +16,10 @@ type Viewport struct {
 	scrollY     int
 	lineNumbers bool
 	tabSize     int
+	selection   *Selection
+	matcher     Matcher
+	foldStarts  map[int]bool // fold start lines, true if closed
+	closed      []Fold       // outermost closed folds, sorted
 }
 
 // New creates a new viewport
@@ -36,6 +40,8 @@ func (v *Viewport) SetBuffer(buf *buffer.Buffer) {
 	v.buffer = buf
 	v.scrollX = 0
 	v.scrollY = 0
+	v.selection = nil
+	v.SetFolds(nil)
 }
 
 // SetSize sets viewport size
@@ -79,6 +85,11 @@ func (v *Viewport) ToggleLineNumbers() {
 	v.lineNumbers = !v.lineNumbers
 }
 
+// SetLineNumbers shows or hides line numbers
+func (v *Viewport) SetLineNumbers(show bool) {
+	v.lineNumbers = show
+}
+
 // LineNumbers returns if line numbers are shown
 func (v *Viewport) LineNumbers() bool {
 	return v.lineNumbers
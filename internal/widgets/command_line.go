@@ -0,0 +1,251 @@
+package widgets
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tobibamidele/minra/internal/ui"
+)
+
+// maxCommandHistory is the number of command lines remembered
+const maxCommandHistory = 100
+
+// CommandLineWidget is the : prompt drawn in place of the status message
+type CommandLineWidget struct {
+	visible   bool
+	input     string
+	cursorPos int
+
+	history    []string
+	historyIdx int    // len(history) when not browsing
+	draft      string // input typed before browsing history
+
+	completions   []string
+	completionIdx int
+}
+
+// NewCommandLineWidget creates a new command line widget
+func NewCommandLineWidget() *CommandLineWidget {
+	return &CommandLineWidget{}
+}
+
+// Show opens the prompt with input prefilled
+func (w *CommandLineWidget) Show(input string) {
+	w.visible = true
+	w.input = input
+	w.cursorPos = len(input)
+	w.historyIdx = len(w.history)
+	w.draft = ""
+	w.resetCompletion()
+}
+
+func (w *CommandLineWidget) Hide() {
+	w.visible = false
+	w.input = ""
+	w.cursorPos = 0
+	w.resetCompletion()
+}
+
+func (w *CommandLineWidget) IsVisible() bool {
+	return w.visible
+}
+
+func (w *CommandLineWidget) GetInput() string {
+	return w.input
+}
+
+// SetInput replaces the input and moves the cursor to its end
+func (w *CommandLineWidget) SetInput(input string) {
+	w.input = input
+	w.cursorPos = len(input)
+}
+
+func (w *CommandLineWidget) InsertRune(r rune) {
+	w.resetCompletion()
+	s := string(r)
+	w.input = w.input[:w.cursorPos] + s + w.input[w.cursorPos:]
+	w.cursorPos += len(s)
+}
+
+func (w *CommandLineWidget) DeleteRune() {
+	w.resetCompletion()
+	if w.cursorPos > 0 {
+		w.input = w.input[:w.cursorPos-1] + w.input[w.cursorPos:]
+		w.cursorPos--
+	}
+}
+
+// DeleteWord deletes the word before the cursor, like ctrl+w in a shell
+func (w *CommandLineWidget) DeleteWord() {
+	w.resetCompletion()
+	start := strings.TrimRight(w.input[:w.cursorPos], " ")
+	start = start[:strings.LastIndexAny(start, " /")+1]
+	w.input = start + w.input[w.cursorPos:]
+	w.cursorPos = len(start)
+}
+
+func (w *CommandLineWidget) MoveCursorLeft() {
+	if w.cursorPos > 0 {
+		w.cursorPos--
+	}
+}
+
+func (w *CommandLineWidget) MoveCursorRight() {
+	if w.cursorPos < len(w.input) {
+		w.cursorPos++
+	}
+}
+
+func (w *CommandLineWidget) MoveCursorToStart() {
+	w.cursorPos = 0
+}
+
+func (w *CommandLineWidget) MoveCursorToEnd() {
+	w.cursorPos = len(w.input)
+}
+
+// AddHistory remembers a command line, moving repeats to the end
+func (w *CommandLineWidget) AddHistory(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	for i, h := range w.history {
+		if h == line {
+			w.history = append(w.history[:i], w.history[i+1:]...)
+			break
+		}
+	}
+	w.history = append(w.history, line)
+	if len(w.history) > maxCommandHistory {
+		w.history = w.history[len(w.history)-maxCommandHistory:]
+	}
+	w.historyIdx = len(w.history)
+}
+
+// History returns the remembered command lines, oldest first
+func (w *CommandLineWidget) History() []string {
+	return w.history
+}
+
+// HistoryPrev replaces the input with the previous history entry that
+// starts with what was typed before browsing
+func (w *CommandLineWidget) HistoryPrev() {
+	w.resetCompletion()
+	if w.historyIdx == len(w.history) {
+		w.draft = w.input
+	}
+	for i := w.historyIdx - 1; i >= 0; i-- {
+		if strings.HasPrefix(w.history[i], w.draft) {
+			w.historyIdx = i
+			w.SetInput(w.history[i])
+			return
+		}
+	}
+}
+
+// HistoryNext moves forward through history, back to the typed input
+// after the newest entry
+func (w *CommandLineWidget) HistoryNext() {
+	w.resetCompletion()
+	for i := w.historyIdx + 1; i < len(w.history); i++ {
+		if strings.HasPrefix(w.history[i], w.draft) {
+			w.historyIdx = i
+			w.SetInput(w.history[i])
+			return
+		}
+	}
+	if w.historyIdx < len(w.history) {
+		w.historyIdx = len(w.history)
+		w.SetInput(w.draft)
+	}
+}
+
+// Complete replaces the input with the next completion. candidates is
+// asked for the full command lines completing the input the first time
+// tab is pressed; pressing it again cycles through them.
+func (w *CommandLineWidget) Complete(candidates func(input string) []string, backward bool) {
+	if w.completions == nil {
+		w.completions = candidates(w.input)
+		if len(w.completions) == 0 {
+			w.completions = nil
+			return
+		}
+		w.completionIdx = -1
+		if backward {
+			w.completionIdx = 0
+		}
+	}
+
+	n := len(w.completions)
+	if backward {
+		w.completionIdx = (w.completionIdx - 1 + n) % n
+	} else {
+		w.completionIdx = (w.completionIdx + 1) % n
+	}
+	w.SetInput(w.completions[w.completionIdx])
+}
+
+// Completions returns the candidates being cycled through and the index
+// of the selected one
+func (w *CommandLineWidget) Completions() ([]string, int) {
+	return w.completions, w.completionIdx
+}
+
+func (w *CommandLineWidget) resetCompletion() {
+	w.completions = nil
+	w.completionIdx = 0
+}
+
+// Render draws the prompt with a block cursor
+func (w *CommandLineWidget) Render() string {
+	if !w.visible {
+		return ""
+	}
+
+	cursorStyle := lipgloss.NewStyle().Reverse(true)
+	under := " "
+	after := ""
+	if w.cursorPos < len(w.input) {
+		under = w.input[w.cursorPos : w.cursorPos+1]
+		after = w.input[w.cursorPos+1:]
+	}
+	return ":" + w.input[:w.cursorPos] + cursorStyle.Render(under) + after
+}
+
+// RenderCompletions draws the completion candidates on one line with the
+// selected one highlighted, trimmed to width
+func (w *CommandLineWidget) RenderCompletions(labels []string, width int) string {
+	selectedStyle := ui.Highlight(lipgloss.NewStyle().
+		Foreground(ui.Colors.Widget).
+		Background(ui.Colors.Warning).
+		Bold(true))
+	normalStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.WidgetForeground).
+		Background(ui.Colors.WidgetInput)
+
+	// Scroll so the selected candidate is visible
+	start, used := 0, 0
+	for i := 0; i <= w.completionIdx && i < len(labels); i++ {
+		used += len(labels[i]) + 2
+		for used > width && start < i {
+			used -= len(labels[start]) + 2
+			start++
+		}
+	}
+
+	var line strings.Builder
+	used = 0
+	for i := start; i < len(labels); i++ {
+		if used+len(labels[i])+2 > width {
+			break
+		}
+		style := normalStyle
+		if i == w.completionIdx {
+			style = selectedStyle
+		}
+		line.WriteString(style.Render(" " + labels[i] + " "))
+		used += len(labels[i]) + 2
+	}
+
+	return normalStyle.Width(width).Render(line.String())
+}
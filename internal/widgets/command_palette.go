@@ -1 +1,203 @@
 package widgets
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tobibamidele/minra/internal/ui"
+	"github.com/tobibamidele/minra/pkg/utils"
+)
+
+// paletteRows is the number of matches shown at once
+const paletteRows = 12
+
+// PaletteItem is one action listed in the command palette
+type PaletteItem struct {
+	ID     string
+	Title  string
+	Key    string // current keybinding, empty if none
+	Recent int    // 1 for the most recently used action, 0 if never used
+}
+
+type paletteMatch struct {
+	item      PaletteItem
+	score     int
+	positions []int
+}
+
+// CommandPaletteWidget fuzzy-searches the editor's actions
+type CommandPaletteWidget struct {
+	visible   bool
+	input     string
+	cursorPos int
+	items     []PaletteItem
+	matches   []paletteMatch
+	selected  int
+	offset    int
+	width     int
+}
+
+// NewCommandPaletteWidget creates a new command palette
+func NewCommandPaletteWidget() *CommandPaletteWidget {
+	return &CommandPaletteWidget{
+		visible: false,
+		width:   64,
+	}
+}
+
+// Show opens the palette listing items
+func (w *CommandPaletteWidget) Show(items []PaletteItem) {
+	w.visible = true
+	w.input = ""
+	w.cursorPos = 0
+	w.items = items
+	w.filter()
+}
+
+func (w *CommandPaletteWidget) Hide() {
+	w.visible = false
+	w.input = ""
+	w.cursorPos = 0
+	w.items = nil
+	w.matches = nil
+}
+
+func (w *CommandPaletteWidget) IsVisible() bool {
+	return w.visible
+}
+
+func (w *CommandPaletteWidget) GetInput() string {
+	return w.input
+}
+
+func (w *CommandPaletteWidget) InsertRune(r rune) {
+	s := string(r)
+	w.input = w.input[:w.cursorPos] + s + w.input[w.cursorPos:]
+	w.cursorPos += len(s)
+	w.filter()
+}
+
+func (w *CommandPaletteWidget) DeleteRune() {
+	if w.cursorPos > 0 {
+		_, size := utf8.DecodeLastRuneInString(w.input[:w.cursorPos])
+		w.input = w.input[:w.cursorPos-size] + w.input[w.cursorPos:]
+		w.cursorPos -= size
+		w.filter()
+	}
+}
+
+func (w *CommandPaletteWidget) MoveUp() {
+	if w.selected > 0 {
+		w.selected--
+	}
+	if w.selected < w.offset {
+		w.offset = w.selected
+	}
+}
+
+func (w *CommandPaletteWidget) MoveDown() {
+	if w.selected < len(w.matches)-1 {
+		w.selected++
+	}
+	if w.selected >= w.offset+paletteRows {
+		w.offset = w.selected - paletteRows + 1
+	}
+}
+
+// Selected returns the highlighted item, or nil if nothing matches
+func (w *CommandPaletteWidget) Selected() *PaletteItem {
+	if w.selected < 0 || w.selected >= len(w.matches) {
+		return nil
+	}
+	return &w.matches[w.selected].item
+}
+
+// filter ranks the items against the input. Recently used items come
+// first, most recent on top, then the rest by match score.
+func (w *CommandPaletteWidget) filter() {
+	w.matches = w.matches[:0]
+	for _, item := range w.items {
+		score, positions, ok := utils.FuzzyMatch(w.input, item.Title)
+		if !ok {
+			continue
+		}
+		w.matches = append(w.matches, paletteMatch{item: item, score: score, positions: positions})
+	}
+
+	sort.SliceStable(w.matches, func(i, j int) bool {
+		a, b := w.matches[i], w.matches[j]
+		if (a.item.Recent > 0) != (b.item.Recent > 0) {
+			return a.item.Recent > 0
+		}
+		if a.item.Recent != b.item.Recent {
+			return a.item.Recent < b.item.Recent
+		}
+		return a.score > b.score
+	})
+
+	w.selected = 0
+	w.offset = 0
+}
+
+func (w *CommandPaletteWidget) Render() string {
+	if !w.visible {
+		return ""
+	}
+
+	var content strings.Builder
+	styleWidth := w.width - 4
+
+	inputStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.WidgetForeground).
+		Background(ui.Colors.WidgetInput).
+		Padding(0, 1).
+		Width(styleWidth)
+
+	content.WriteString(inputStyle.Render("> " + w.input))
+	content.WriteString("\n")
+
+	matchStyle := lipgloss.NewStyle().Foreground(ui.Colors.Warning).Bold(true)
+	keyStyle := lipgloss.NewStyle().Foreground(ui.Colors.Comment)
+	recentStyle := lipgloss.NewStyle().Foreground(ui.Colors.Comment).Italic(true)
+	selectedStyle := ui.Highlight(lipgloss.NewStyle().Background(ui.Colors.WidgetSelected).Width(styleWidth))
+	rowStyle := lipgloss.NewStyle().Width(styleWidth)
+
+	if len(w.matches) == 0 {
+		content.WriteString("\n")
+		content.WriteString(keyStyle.Render("No matches"))
+	}
+
+	end := min(w.offset+paletteRows, len(w.matches))
+	for i := w.offset; i < end; i++ {
+		m := w.matches[i]
+
+		title := utils.HighlightPositions(m.item.Title, m.positions, func(s string) string {
+			return matchStyle.Render(s)
+		})
+		right := keyStyle.Render(m.item.Key)
+		if m.item.Recent > 0 && m.item.Key == "" {
+			right = recentStyle.Render("recently used")
+		}
+
+		gap := max(styleWidth-lipgloss.Width(title)-lipgloss.Width(right)-2, 1)
+		row := " " + title + strings.Repeat(" ", gap) + right
+
+		content.WriteString("\n")
+		if i == w.selected {
+			content.WriteString(selectedStyle.Render(row))
+		} else {
+			content.WriteString(rowStyle.Render(row))
+		}
+	}
+
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ui.Colors.Warning).
+		Padding(0, 1).
+		Width(w.width).
+		Background(ui.Colors.Widget)
+
+	return boxStyle.Render(content.String())
+}
@@ -2,14 +2,18 @@ package widgets
 
 import (
 	"strings"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/tobibamidele/minra/internal/ui"
 )
 
-// CreateWidget handles file creation
+// CreateWidget asks for the path of a file to create, or to move a file
+// to. A path ending in "/" creates a directory.
 type CreateWidget struct {
 	visible   bool
+	title     string
+	verb      string // what Enter does, as in "create"
 	input     string
 	cursorPos int
 	width     int
@@ -19,16 +23,29 @@ type CreateWidget struct {
 func NewCreateWidget() *CreateWidget {
 	return &CreateWidget{
 		visible: false,
-		width:   40,
+		width:   64,
 	}
 }
 
-func (w *CreateWidget) Show() {
+// Show asks for a new file in the directory dir, shown relative to the
+// workspace
+func (w *CreateWidget) Show(dir string) {
 	w.visible = true
+	w.title = "New File in " + dir
+	w.verb = "create"
 	w.input = ""
 	w.cursorPos = 0
 }
 
+// ShowMove asks where to move the file at path, prefilled with it
+func (w *CreateWidget) ShowMove(path string) {
+	w.visible = true
+	w.title = "Move " + path
+	w.verb = "move"
+	w.input = path
+	w.cursorPos = len(path)
+}
+
 func (w *CreateWidget) Hide() {
 	w.visible = false
 	w.input = ""
@@ -44,30 +61,30 @@ func (w *CreateWidget) GetInput() string {
 }
 
 func (w *CreateWidget) InsertRune(r rune) {
-	before := w.input[:w.cursorPos]
-	after := w.input[w.cursorPos:]
-	w.input = before + string(r) + after
-	w.cursorPos++
+	s := string(r)
+	w.input = w.input[:w.cursorPos] + s + w.input[w.cursorPos:]
+	w.cursorPos += len(s)
 }
 
 func (w *CreateWidget) DeleteRune() {
 	if w.cursorPos > 0 {
-		before := w.input[:w.cursorPos-1]
-		after := w.input[w.cursorPos:]
-		w.input = before + after
-		w.cursorPos--
+		_, size := utf8.DecodeLastRuneInString(w.input[:w.cursorPos])
+		w.input = w.input[:w.cursorPos-size] + w.input[w.cursorPos:]
+		w.cursorPos -= size
 	}
 }
 
 func (w *CreateWidget) MoveCursorLeft() {
 	if w.cursorPos > 0 {
-		w.cursorPos--
+		_, size := utf8.DecodeLastRuneInString(w.input[:w.cursorPos])
+		w.cursorPos -= size
 	}
 }
 
 func (w *CreateWidget) MoveCursorRight() {
 	if w.cursorPos < len(w.input) {
-		w.cursorPos++
+		_, size := utf8.DecodeRuneInString(w.input[w.cursorPos:])
+		w.cursorPos += size
 	}
 }
 
@@ -88,37 +105,44 @@ func (w *CreateWidget) Render() string {
 	styleWidth := w.width - 4
 
 	titleStyle := lipgloss.NewStyle().
-		Foreground(ui.ColorAccent).
+		Foreground(ui.Colors.Accent).
 		Bold(true).
 		Align(lipgloss.Center).
 		Width(styleWidth)
 
-	content.WriteString(titleStyle.Render("Create File"))
+	content.WriteString(titleStyle.Render(w.title))
 	content.WriteString("\n\n")
 
 	inputStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("230")).
-		Background(lipgloss.Color("236")).
+		Foreground(ui.Colors.WidgetForeground).
+		Background(ui.Colors.WidgetInput).
 		Padding(0, 1).
 		Width(styleWidth)
 
-	content.WriteString(inputStyle.Render(w.input))
+	input := w.input[:w.cursorPos] + ui.ActiveCursorStyle.Render(" ")
+	if w.cursorPos < len(w.input) {
+		_, size := utf8.DecodeRuneInString(w.input[w.cursorPos:])
+		input = w.input[:w.cursorPos] +
+			ui.ActiveCursorStyle.Render(w.input[w.cursorPos:w.cursorPos+size]) +
+			w.input[w.cursorPos+size:]
+	}
+	content.WriteString(inputStyle.Render(input))
 	content.WriteString("\n\n")
 
 	helpStyle := lipgloss.NewStyle().
-		Foreground(ui.ColorComment).
+		Foreground(ui.Colors.Comment).
 		Italic(true).
 		Align(lipgloss.Center).
 		Width(styleWidth)
 
-	content.WriteString(helpStyle.Render("Enter: create | Esc: cancel"))
+	content.WriteString(helpStyle.Render("End with / for a folder | Enter: " + w.verb + " | Esc: cancel"))
 
 	boxStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(ui.ColorAccent).
+		BorderForeground(ui.Colors.Accent).
 		Padding(1, 2).
 		Width(w.width).
-		Background(ui.ColorBackground)
+		Background(ui.Colors.Background)
 
 	return boxStyle.Render(content.String())
 }
@@ -1 +1,80 @@
 package widgets
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tobibamidele/minra/internal/ui"
+)
+
+// DialogWidget asks to confirm an action, such as deleting a file
+type DialogWidget struct {
+	visible bool
+	title   string
+	message string
+	width   int
+}
+
+// NewDialogWidget creates a new confirmation dialog
+func NewDialogWidget() *DialogWidget {
+	return &DialogWidget{
+		visible: false,
+		width:   48,
+	}
+}
+
+// Show asks the question in message under title
+func (w *DialogWidget) Show(title, message string) {
+	w.visible = true
+	w.title = title
+	w.message = message
+}
+
+func (w *DialogWidget) Hide() {
+	w.visible = false
+	w.title = ""
+	w.message = ""
+}
+
+func (w *DialogWidget) IsVisible() bool {
+	return w.visible
+}
+
+func (w *DialogWidget) Render() string {
+	if !w.visible {
+		return ""
+	}
+
+	var content strings.Builder
+	styleWidth := w.width - 4
+
+	titleStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.Error).
+		Bold(true).
+		Align(lipgloss.Center).
+		Width(styleWidth)
+	content.WriteString(titleStyle.Render(w.title))
+	content.WriteString("\n\n")
+
+	messageStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.WidgetForeground).
+		Width(styleWidth)
+	content.WriteString(messageStyle.Render(w.message))
+	content.WriteString("\n\n")
+
+	helpStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.Comment).
+		Italic(true).
+		Align(lipgloss.Center).
+		Width(styleWidth)
+	content.WriteString(helpStyle.Render("y: yes | n/Esc: no"))
+
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ui.Colors.Error).
+		Padding(1, 2).
+		Width(w.width).
+		Background(ui.Colors.Widget)
+
+	return boxStyle.Render(content.String())
+}
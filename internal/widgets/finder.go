@@ -0,0 +1,390 @@
+package widgets
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tobibamidele/minra/internal/ui"
+	"github.com/tobibamidele/minra/pkg/utils"
+)
+
+// finderRecentBonus is the score the most recently opened file gets on
+// top of its match. The bonus shrinks down the history, so a much better
+// match still beats a file opened long ago.
+const finderRecentBonus = 64
+
+type finderMatch struct {
+	path      string
+	score     int
+	recent    int // 1 for the most recently opened file, 0 if not opened
+	positions []int
+}
+
+// FinderWidget fuzzy-finds a file of the workspace by its path, with a
+// preview of the selected file
+type FinderWidget struct {
+	visible   bool
+	input     string
+	cursorPos int
+	status    string
+
+	paths    []string
+	recent   map[string]int // rank of recently opened paths, 1 for the latest
+	matches  []finderMatch
+	selected int
+	offset   int
+	width    int
+	height   int // rows of results shown
+
+	previewPath  string
+	previewLines []string
+	previewNote  string // shown instead of lines, as for binary files
+}
+
+// NewFinderWidget creates a new file finder
+func NewFinderWidget() *FinderWidget {
+	return &FinderWidget{
+		visible: false,
+		width:   100,
+		height:  16,
+	}
+}
+
+// Show opens the finder with no files, ranking those in recent by their
+// rank in it
+func (w *FinderWidget) Show(recent map[string]int) {
+	w.visible = true
+	w.input = ""
+	w.cursorPos = 0
+	w.status = ""
+	w.paths = nil
+	w.recent = recent
+	w.matches = nil
+	w.selected = 0
+	w.offset = 0
+	w.SetPreview("", nil, "")
+}
+
+func (w *FinderWidget) Hide() {
+	w.visible = false
+	w.paths = nil
+	w.matches = nil
+}
+
+func (w *FinderWidget) IsVisible() bool {
+	return w.visible
+}
+
+// SetSize fits the finder in width columns, showing height rows of files
+func (w *FinderWidget) SetSize(width, height int) {
+	w.width = max(width, 40)
+	w.height = max(height, 3)
+	w.adjustOffset()
+}
+
+func (w *FinderWidget) GetInput() string {
+	return w.input
+}
+
+func (w *FinderWidget) InsertRune(r rune) {
+	s := string(r)
+	w.input = w.input[:w.cursorPos] + s + w.input[w.cursorPos:]
+	w.cursorPos += len(s)
+	w.filter(true)
+}
+
+func (w *FinderWidget) DeleteRune() {
+	if w.cursorPos > 0 {
+		_, size := utf8.DecodeLastRuneInString(w.input[:w.cursorPos])
+		w.input = w.input[:w.cursorPos-size] + w.input[w.cursorPos:]
+		w.cursorPos -= size
+		w.filter(false)
+	}
+}
+
+// SetStatus sets the progress line, such as "Indexing…"
+func (w *FinderWidget) SetStatus(status string) {
+	w.status = status
+}
+
+// Add adds files found by the index, keeping the selected file selected
+func (w *FinderWidget) Add(paths []string) {
+	selected := w.Selected()
+	w.paths = append(w.paths, paths...)
+	var batch []finderMatch
+	for _, path := range paths {
+		if m, ok := w.match(path); ok {
+			batch = append(batch, m)
+		}
+	}
+	w.sortMatches(batch)
+	w.merge(batch)
+
+	w.selected = 0
+	for i, m := range w.matches {
+		if m.path == selected {
+			w.selected = i
+			break
+		}
+	}
+	w.adjustOffset()
+}
+
+// Counts returns the number of matching files and of files indexed
+func (w *FinderWidget) Counts() (matches, files int) {
+	return len(w.matches), len(w.paths)
+}
+
+// match scores path against the input
+func (w *FinderWidget) match(path string) (finderMatch, bool) {
+	score, positions, ok := utils.FuzzyMatchPath(w.input, path)
+	if !ok {
+		return finderMatch{}, false
+	}
+	recent := w.recent[path]
+	if recent > 0 {
+		score += finderRecentBonus / recent
+	}
+	return finderMatch{path: path, score: score, recent: recent, positions: positions}, true
+}
+
+// filter ranks the files against the input. With narrow the input only
+// gained characters, so just the files that matched before can match it.
+func (w *FinderWidget) filter(narrow bool) {
+	if narrow {
+		kept := w.matches[:0]
+		for _, old := range w.matches {
+			if m, ok := w.match(old.path); ok {
+				kept = append(kept, m)
+			}
+		}
+		w.matches = kept
+	} else {
+		w.matches = w.matches[:0]
+		for _, path := range w.paths {
+			if m, ok := w.match(path); ok {
+				w.matches = append(w.matches, m)
+			}
+		}
+	}
+	w.sortMatches(w.matches)
+	w.selected = 0
+	w.offset = 0
+}
+
+// merge merges a sorted batch of matches into the sorted matches, filling
+// them in from the back
+func (w *FinderWidget) merge(batch []finderMatch) {
+	i := len(w.matches) - 1
+	w.matches = append(w.matches, batch...)
+	for j, k := len(batch)-1, len(w.matches)-1; j >= 0; k-- {
+		if i >= 0 && w.less(batch[j], w.matches[i]) {
+			w.matches[k] = w.matches[i]
+			i--
+		} else {
+			w.matches[k] = batch[j]
+			j--
+		}
+	}
+}
+
+// sortMatches orders matches, as less does
+func (w *FinderWidget) sortMatches(matches []finderMatch) {
+	sort.Slice(matches, func(i, j int) bool { return w.less(matches[i], matches[j]) })
+}
+
+// less orders the matches. Without a query recently opened files come
+// first, most recent on top, then the rest by path; with one the best
+// scores come first, recency counting towards them.
+func (w *FinderWidget) less(a, b finderMatch) bool {
+	if w.input == "" {
+		if (a.recent > 0) != (b.recent > 0) {
+			return a.recent > 0
+		}
+		if a.recent != b.recent {
+			return a.recent < b.recent
+		}
+		return a.path < b.path
+	}
+	if a.score != b.score {
+		return a.score > b.score
+	}
+	return a.path < b.path
+}
+
+func (w *FinderWidget) MoveUp() {
+	if w.selected > 0 {
+		w.selected--
+	}
+	w.adjustOffset()
+}
+
+func (w *FinderWidget) MoveDown() {
+	if w.selected < len(w.matches)-1 {
+		w.selected++
+	}
+	w.adjustOffset()
+}
+
+// PageUp and PageDown move the selection a page of rows
+func (w *FinderWidget) PageUp() {
+	w.selected = max(w.selected-w.height, 0)
+	w.adjustOffset()
+}
+
+func (w *FinderWidget) PageDown() {
+	w.selected = max(min(w.selected+w.height, len(w.matches)-1), 0)
+	w.adjustOffset()
+}
+
+func (w *FinderWidget) adjustOffset() {
+	if w.selected < w.offset {
+		w.offset = w.selected
+	}
+	if w.selected >= w.offset+w.height {
+		w.offset = w.selected - w.height + 1
+	}
+}
+
+// Selected returns the path of the selected file, or "" if nothing
+// matches
+func (w *FinderWidget) Selected() string {
+	if w.selected >= len(w.matches) {
+		return ""
+	}
+	return w.matches[w.selected].path
+}
+
+// PreviewPath returns the file the preview shows
+func (w *FinderWidget) PreviewPath() string {
+	return w.previewPath
+}
+
+// SetPreview shows the first lines of the file at path, or note in their
+// place when the file cannot be shown
+func (w *FinderWidget) SetPreview(path string, lines []string, note string) {
+	w.previewPath = path
+	w.previewLines = lines
+	w.previewNote = note
+}
+
+func (w *FinderWidget) Render() string {
+	if !w.visible {
+		return ""
+	}
+
+	var content strings.Builder
+	styleWidth := w.width - 4
+	listWidth := styleWidth * 2 / 5
+	previewWidth := styleWidth - listWidth - 1
+
+	inputStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.WidgetForeground).
+		Background(ui.Colors.WidgetInput).
+		Padding(0, 1).
+		Width(styleWidth)
+	content.WriteString(inputStyle.Render("> " + w.input))
+	content.WriteString("\n")
+
+	matches, files := w.Counts()
+	status := fmt.Sprintf("%d/%d", matches, files)
+	if w.status != "" {
+		status = w.status + " " + status
+	}
+	statusStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.Comment).
+		Align(lipgloss.Right).
+		Width(styleWidth)
+	content.WriteString(statusStyle.Render(status))
+	content.WriteString("\n")
+
+	matchStyle := lipgloss.NewStyle().Foreground(ui.Colors.Warning).Bold(true)
+	dirStyle := lipgloss.NewStyle().Foreground(ui.Colors.Comment)
+	selectedStyle := ui.Highlight(lipgloss.NewStyle().Background(ui.Colors.WidgetSelected).Width(listWidth))
+	rowStyle := lipgloss.NewStyle().Width(listWidth)
+
+	list := make([]string, w.height)
+	for r := range list {
+		i := w.offset + r
+		if i >= len(w.matches) {
+			list[r] = rowStyle.Render("")
+			continue
+		}
+		m := w.matches[i]
+
+		// Show the end of long paths, where the file name is
+		path, positions := m.path, m.positions
+		if cut := len(path) - (listWidth - 3); cut > 0 {
+			for cut < len(path) && !utf8.RuneStart(path[cut]) {
+				cut++
+			}
+			path = "…" + path[cut:]
+			shifted := positions[:0:0]
+			for _, p := range positions {
+				if p >= cut {
+					shifted = append(shifted, p-cut+len("…"))
+				}
+			}
+			positions = shifted
+		}
+
+		dir := strings.LastIndexByte(path, '/') + 1
+		text := utils.HighlightPositions(path, positions, func(s string) string {
+			return matchStyle.Render(s)
+		})
+		if len(positions) == 0 && dir > 0 {
+			text = dirStyle.Render(path[:dir]) + path[dir:]
+		}
+
+		if i == w.selected {
+			list[r] = selectedStyle.Render(" " + text)
+		} else {
+			list[r] = rowStyle.Render(" " + text)
+		}
+	}
+	if len(w.matches) == 0 {
+		list[0] = rowStyle.Render(dirStyle.Render(" No matches"))
+	}
+
+	numberStyle := lipgloss.NewStyle().Foreground(ui.Colors.GutterForeground)
+	previewStyle := lipgloss.NewStyle().Foreground(ui.Colors.WidgetForeground).Width(previewWidth)
+	noteStyle := lipgloss.NewStyle().Foreground(ui.Colors.Comment).Italic(true).Width(previewWidth)
+	preview := make([]string, w.height)
+	for r := range preview {
+		switch {
+		case w.previewNote != "" && r == 0:
+			preview[r] = noteStyle.Render(w.previewNote)
+		case w.previewNote == "" && r < len(w.previewLines):
+			preview[r] = previewStyle.Render(numberStyle.Render(fmt.Sprintf("%4d ", r+1)) +
+				clipLine(w.previewLines[r], 0, previewWidth-5))
+		default:
+			preview[r] = previewStyle.Render("")
+		}
+	}
+
+	separator := lipgloss.NewStyle().
+		Foreground(ui.Colors.Border).
+		Render(strings.TrimSuffix(strings.Repeat("│\n", w.height), "\n"))
+	content.WriteString(lipgloss.JoinHorizontal(lipgloss.Top,
+		strings.Join(list, "\n"), separator, strings.Join(preview, "\n")))
+
+	helpStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.Comment).
+		Italic(true).
+		Align(lipgloss.Center).
+		Width(styleWidth)
+	content.WriteString("\n")
+	content.WriteString(helpStyle.Render("↑/↓: select | Enter: open | Esc: close"))
+
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ui.Colors.Warning).
+		Padding(0, 1).
+		Width(w.width).
+		Background(ui.Colors.Widget)
+
+	return boxStyle.Render(content.String())
+}
@@ -0,0 +1,471 @@
+package widgets
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tobibamidele/minra/internal/search"
+	"github.com/tobibamidele/minra/internal/ui"
+)
+
+// grepRowKind is what a row of the results list shows
+type grepRowKind int
+
+const (
+	grepHeader  grepRowKind = iota // the file's path
+	grepMatch                      // a line with matches
+	grepContext                    // a line around a match
+	grepGap                        // lines left out between two shown
+)
+
+// grepRow is one row of the results list
+type grepRow struct {
+	kind  grepRowKind
+	file  int      // index in files
+	match int      // index in the file's matches of the first on the line
+	line  int      // line number in the file
+	text  string   // the line
+	spans [][2]int // byte ranges of the matches on the line
+}
+
+// GrepWidget is the panel of a workspace search: a query, the search
+// options and the matches found so far, grouped by file
+type GrepWidget struct {
+	visible   bool
+	input     string
+	cursorPos int
+	options   search.Options
+	status    string
+	err       string
+
+	replacing    bool // the replace field is shown
+	replaceFocus bool // typing goes to the replace field
+	replacement  string
+	replacePos   int
+
+	files    []search.FileResult // sorted by path
+	matches  int
+	rows     []grepRow
+	selected int // index in rows of a matching line
+	offset   int
+	width    int
+	height   int // rows of results shown
+}
+
+// NewGrepWidget creates a new workspace search panel
+func NewGrepWidget() *GrepWidget {
+	return &GrepWidget{
+		visible: false,
+		width:   80,
+		height:  16,
+	}
+}
+
+func (w *GrepWidget) Show() {
+	w.visible = true
+}
+
+func (w *GrepWidget) Hide() {
+	w.visible = false
+}
+
+func (w *GrepWidget) IsVisible() bool {
+	return w.visible
+}
+
+// SetSize fits the panel in width columns, showing height rows of results
+func (w *GrepWidget) SetSize(width, height int) {
+	w.width = max(width, 40)
+	w.height = max(height, 3)
+	w.adjustOffset()
+}
+
+func (w *GrepWidget) GetInput() string {
+	return w.input
+}
+
+// SetInput replaces the query
+func (w *GrepWidget) SetInput(input string) {
+	w.input = input
+	w.cursorPos = len(input)
+}
+
+// SetReplacing shows or hides the replace field
+func (w *GrepWidget) SetReplacing(on bool) {
+	w.replacing = on
+	w.replaceFocus = w.replaceFocus && on
+	w.adjustOffset()
+}
+
+// IsReplacing reports whether the replace field is shown
+func (w *GrepWidget) IsReplacing() bool {
+	return w.replacing
+}
+
+// FocusReplace sends typing to the replace field, or back to the query
+func (w *GrepWidget) FocusReplace(focus bool) {
+	w.replaceFocus = focus && w.replacing
+}
+
+// ReplaceFocused reports whether typing goes to the replace field
+func (w *GrepWidget) ReplaceFocused() bool {
+	return w.replaceFocus
+}
+
+// GetReplacement returns the text of the replace field
+func (w *GrepWidget) GetReplacement() string {
+	return w.replacement
+}
+
+// field returns the text and cursor of the focused field
+func (w *GrepWidget) field() (*string, *int) {
+	if w.replaceFocus {
+		return &w.replacement, &w.replacePos
+	}
+	return &w.input, &w.cursorPos
+}
+
+func (w *GrepWidget) InsertRune(r rune) {
+	input, pos := w.field()
+	s := string(r)
+	*input = (*input)[:*pos] + s + (*input)[*pos:]
+	*pos += len(s)
+}
+
+func (w *GrepWidget) DeleteRune() {
+	input, pos := w.field()
+	if *pos > 0 {
+		_, size := utf8.DecodeLastRuneInString((*input)[:*pos])
+		*input = (*input)[:*pos-size] + (*input)[*pos:]
+		*pos -= size
+	}
+}
+
+// SetOptions sets the search options the toggles show
+func (w *GrepWidget) SetOptions(opts search.Options) {
+	w.options = opts
+}
+
+// SetStatus sets the progress line, such as "Searching…"
+func (w *GrepWidget) SetStatus(status string) {
+	w.status = status
+}
+
+// SetError shows why the query cannot be searched for, or clears it
+func (w *GrepWidget) SetError(err string) {
+	w.err = err
+}
+
+// Clear drops the results of the previous query
+func (w *GrepWidget) Clear() {
+	w.files = nil
+	w.rows = nil
+	w.matches = 0
+	w.selected = 0
+	w.offset = 0
+}
+
+// Add adds the matches of a file, keeping files sorted by path
+func (w *GrepWidget) Add(file search.FileResult) {
+	// Keep the same line selected as rows move under it
+	path, line := "", -1
+	if w.selected < len(w.rows) {
+		row := w.rows[w.selected]
+		path, line = w.files[row.file].Path, row.line
+	}
+
+	i := sort.Search(len(w.files), func(i int) bool { return w.files[i].Rel >= file.Rel })
+	w.files = append(w.files, search.FileResult{})
+	copy(w.files[i+1:], w.files[i:])
+	w.files[i] = file
+	w.matches += len(file.Matches)
+
+	w.rebuild()
+	w.selected = w.firstMatchRow(0, 1)
+	for r, row := range w.rows {
+		if row.kind == grepMatch && row.line == line && w.files[row.file].Path == path {
+			w.selected = r
+			break
+		}
+	}
+	w.adjustOffset()
+}
+
+// Counts returns the number of matches and of files they are in
+func (w *GrepWidget) Counts() (matches, files int) {
+	return w.matches, len(w.files)
+}
+
+// rebuild lays out the rows: each file's matching lines with their
+// context, merged where they overlap
+func (w *GrepWidget) rebuild() {
+	w.rows = w.rows[:0]
+	for f, file := range w.files {
+		w.rows = append(w.rows, grepRow{kind: grepHeader, file: f})
+
+		lines := make(map[int]*grepRow)
+		context := func(first int, text []string) {
+			for i, t := range text {
+				if lines[first+i] == nil {
+					lines[first+i] = &grepRow{kind: grepContext, file: f, line: first + i, text: t}
+				}
+			}
+		}
+		for m, match := range file.Matches {
+			context(match.Line-len(match.Before), match.Before)
+			context(match.EndLine+1, match.After)
+
+			row := lines[match.Line]
+			if row == nil || row.kind != grepMatch {
+				row = &grepRow{kind: grepMatch, file: f, match: m, line: match.Line, text: match.Text}
+				lines[match.Line] = row
+			}
+			end := match.EndColumn
+			if match.EndLine != match.Line {
+				end = len(match.Text)
+			}
+			row.spans = append(row.spans, [2]int{match.Column, end})
+		}
+
+		numbers := make([]int, 0, len(lines))
+		for n := range lines {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+		for i, n := range numbers {
+			if i > 0 && n > numbers[i-1]+1 {
+				w.rows = append(w.rows, grepRow{kind: grepGap, file: f})
+			}
+			w.rows = append(w.rows, *lines[n])
+		}
+	}
+}
+
+// firstMatchRow returns the first matching line from row from in
+// direction step, or from itself if there is none that way
+func (w *GrepWidget) firstMatchRow(from, step int) int {
+	for r := from; r >= 0 && r < len(w.rows); r += step {
+		if w.rows[r].kind == grepMatch {
+			return r
+		}
+	}
+	return from
+}
+
+// MoveUp selects the previous matching line
+func (w *GrepWidget) MoveUp() {
+	w.selected = w.firstMatchRow(max(w.selected-1, 0), -1)
+	w.selected = w.firstMatchRow(w.selected, 1)
+	w.adjustOffset()
+}
+
+// MoveDown selects the next matching line
+func (w *GrepWidget) MoveDown() {
+	if next := w.firstMatchRow(w.selected+1, 1); next < len(w.rows) && w.rows[next].kind == grepMatch {
+		w.selected = next
+	}
+	w.adjustOffset()
+}
+
+// PageUp and PageDown move the selection a page of rows
+func (w *GrepWidget) PageUp() {
+	for i := 0; i < w.visibleRows(); i++ {
+		w.MoveUp()
+	}
+}
+
+func (w *GrepWidget) PageDown() {
+	for i := 0; i < w.visibleRows(); i++ {
+		w.MoveDown()
+	}
+}
+
+// visibleRows is the number of result rows shown. The replace field
+// takes one.
+func (w *GrepWidget) visibleRows() int {
+	if w.replacing {
+		return w.height - 1
+	}
+	return w.height
+}
+
+func (w *GrepWidget) adjustOffset() {
+	if w.selected < w.offset {
+		w.offset = w.selected
+		// Show the file header of the first match
+		if w.offset > 0 && w.rows[w.offset-1].kind == grepHeader {
+			w.offset--
+		}
+	}
+	if rows := w.visibleRows(); w.selected >= w.offset+rows {
+		w.offset = w.selected - rows + 1
+	}
+}
+
+// Selected returns the file and match of the selected line, or nil if
+// there are no results
+func (w *GrepWidget) Selected() (*search.FileResult, *search.FileMatch) {
+	if w.selected >= len(w.rows) || w.rows[w.selected].kind != grepMatch {
+		return nil, nil
+	}
+	row := w.rows[w.selected]
+	file := &w.files[row.file]
+	return file, &file.Matches[row.match]
+}
+
+func (w *GrepWidget) Render() string {
+	if !w.visible {
+		return ""
+	}
+
+	var content strings.Builder
+	styleWidth := w.width - 4
+
+	titleStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.Info).
+		Bold(true).
+		Align(lipgloss.Center).
+		Width(styleWidth)
+	title := "Find in Files"
+	if w.replacing {
+		title = "Replace in Files"
+	}
+	content.WriteString(titleStyle.Render(title))
+	content.WriteString("\n")
+
+	inputStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.WidgetForeground).
+		Background(ui.Colors.WidgetInput).
+		Padding(0, 1).
+		Width(styleWidth)
+	focusedStyle := inputStyle.Foreground(ui.Colors.Info)
+
+	style := inputStyle
+	if w.replacing && !w.replaceFocus {
+		style = focusedStyle
+	}
+	content.WriteString(style.Render("> " + w.input))
+	content.WriteString("\n")
+
+	rows := w.visibleRows()
+	if w.replacing {
+		style = inputStyle
+		if w.replaceFocus {
+			style = focusedStyle
+		}
+		content.WriteString(style.Render("→ " + w.replacement))
+		content.WriteString("\n")
+	}
+
+	toggles := renderToggles(w.options, w.replacing)
+	statusStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.Comment).
+		Align(lipgloss.Right).
+		Width(max(styleWidth-lipgloss.Width(toggles), 0))
+	status := statusStyle.Render(w.status)
+	if w.err != "" {
+		status = statusStyle.Foreground(ui.Colors.Error).Render(w.err)
+	}
+	content.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, toggles, status))
+	content.WriteString("\n")
+
+	headerStyle := lipgloss.NewStyle().Foreground(ui.Colors.Accent).Bold(true)
+	countStyle := lipgloss.NewStyle().Foreground(ui.Colors.Comment)
+	numberStyle := lipgloss.NewStyle().Foreground(ui.Colors.GutterForeground)
+	contextStyle := lipgloss.NewStyle().Foreground(ui.Colors.Comment)
+	matchStyle := ui.Highlight(lipgloss.NewStyle().
+		Foreground(ui.Colors.MatchForeground).
+		Background(ui.Colors.Match))
+	selectedStyle := ui.Highlight(lipgloss.NewStyle().Background(ui.Colors.WidgetSelected).Width(styleWidth))
+	rowStyle := lipgloss.NewStyle().Width(styleWidth)
+
+	end := min(w.offset+rows, len(w.rows))
+	for r := w.offset; r < end; r++ {
+		row := w.rows[r]
+		var text string
+		switch row.kind {
+		case grepHeader:
+			file := w.files[row.file]
+			text = headerStyle.Render(file.Rel) + countStyle.Render(fmt.Sprintf(" (%d)", len(file.Matches)))
+		case grepGap:
+			text = contextStyle.Render("    …")
+		case grepContext:
+			text = numberStyle.Render(fmt.Sprintf("%5d ", row.line+1)) +
+				contextStyle.Render(clipLine(row.text, 0, styleWidth-6))
+		default:
+			text = numberStyle.Render(fmt.Sprintf("%5d:", row.line+1)) +
+				highlightSpans(row.text, row.spans, styleWidth-6, matchStyle)
+		}
+
+		content.WriteString("\n")
+		if r == w.selected && row.kind == grepMatch {
+			content.WriteString(selectedStyle.Render(text))
+		} else {
+			content.WriteString(rowStyle.Render(text))
+		}
+	}
+	for r := end - w.offset; r < rows; r++ {
+		content.WriteString("\n")
+	}
+
+	helpStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.Comment).
+		Italic(true).
+		Align(lipgloss.Center).
+		Width(styleWidth)
+	content.WriteString("\n")
+	help := "↑/↓: select | Enter: open | Tab: replace | Esc: close"
+	if w.replacing {
+		help = "↑/↓: select | Enter: open | Tab: switch field | ctrl+r: preview | Esc: close"
+	}
+	content.WriteString(helpStyle.Render(help))
+
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ui.Colors.Info).
+		Padding(0, 1).
+		Width(w.width).
+		Background(ui.Colors.Widget)
+
+	return boxStyle.Render(content.String())
+}
+
+// clipLine returns up to width bytes of line from start, with tabs as
+// spaces so offsets into the line still hold. Cuts fall between runes.
+func clipLine(line string, start, width int) string {
+	line = strings.ReplaceAll(line, "\t", " ")
+	start = min(start, len(line))
+	for start < len(line) && !utf8.RuneStart(line[start]) {
+		start++
+	}
+	end := min(start+width, len(line))
+	for end < len(line) && end > start && !utf8.RuneStart(line[end]) {
+		end--
+	}
+	return line[start:end]
+}
+
+// highlightSpans renders line in width columns with the byte ranges in
+// spans highlighted, scrolled so the first one is visible
+func highlightSpans(line string, spans [][2]int, width int, style lipgloss.Style) string {
+	start := 0
+	if len(spans) > 0 && spans[0][1] > width {
+		start = max(spans[0][0]-width/3, 0)
+	}
+	text := clipLine(line, start, width)
+
+	var b strings.Builder
+	pos := 0
+	for _, span := range spans {
+		from := min(max(span[0]-start, pos), len(text))
+		to := min(max(span[1]-start, from), len(text))
+		b.WriteString(text[pos:from])
+		b.WriteString(style.Render(text[from:to]))
+		pos = to
+	}
+	b.WriteString(text[pos:])
+	return b.String()
+}
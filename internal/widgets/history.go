@@ -0,0 +1,210 @@
+package widgets
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tobibamidele/minra/internal/ui"
+)
+
+// HistoryEntry is one undo state listed in the history browser
+type HistoryEntry struct {
+	Seq      int
+	Time     time.Time
+	Current  bool     // the buffer is in this state
+	OnBranch bool     // the state is an ancestor of the current one
+	Diff     []string // changes that led to this state
+}
+
+// HistoryWidget lists undo states so any of them can be restored
+type HistoryWidget struct {
+	visible  bool
+	entries  []HistoryEntry
+	selected int
+	scroll   int
+	width    int
+	height   int
+}
+
+// NewHistoryWidget creates a new history widget
+func NewHistoryWidget() *HistoryWidget {
+	return &HistoryWidget{
+		visible: false,
+		width:   60,
+		height:  10,
+	}
+}
+
+// Show shows the widget with entries, selecting the current state
+func (w *HistoryWidget) Show(entries []HistoryEntry) {
+	w.visible = true
+	w.entries = entries
+	w.selected = 0
+	w.scroll = 0
+	for i, entry := range entries {
+		if entry.Current {
+			w.selected = i
+		}
+	}
+	w.adjustScroll()
+}
+
+// Hide hides the widget
+func (w *HistoryWidget) Hide() {
+	w.visible = false
+	w.entries = nil
+}
+
+// IsVisible returns whether the widget is visible
+func (w *HistoryWidget) IsVisible() bool {
+	return w.visible
+}
+
+// MoveUp selects the newer state
+func (w *HistoryWidget) MoveUp() {
+	if w.selected > 0 {
+		w.selected--
+		w.adjustScroll()
+	}
+}
+
+// MoveDown selects the older state
+func (w *HistoryWidget) MoveDown() {
+	if w.selected < len(w.entries)-1 {
+		w.selected++
+		w.adjustScroll()
+	}
+}
+
+// Selected returns the selected entry, or nil if there are none
+func (w *HistoryWidget) Selected() *HistoryEntry {
+	if w.selected < 0 || w.selected >= len(w.entries) {
+		return nil
+	}
+	return &w.entries[w.selected]
+}
+
+func (w *HistoryWidget) adjustScroll() {
+	if w.selected < w.scroll {
+		w.scroll = w.selected
+	}
+	if w.selected >= w.scroll+w.height {
+		w.scroll = w.selected - w.height + 1
+	}
+}
+
+// Render renders the history list and a diff preview of the selected state
+func (w *HistoryWidget) Render() string {
+	if !w.visible {
+		return ""
+	}
+
+	var content strings.Builder
+	styleWidth := w.width - 4
+
+	titleStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.Info).
+		Bold(true).
+		Align(lipgloss.Center).
+		Width(styleWidth)
+
+	content.WriteString(titleStyle.Render("Undo History"))
+	content.WriteString("\n\n")
+
+	rowStyle := lipgloss.NewStyle().Width(styleWidth)
+	branchStyle := rowStyle.Foreground(ui.Colors.Comment)
+	selectedStyle := rowStyle.Inherit(ui.SelectedStyle)
+
+	end := min(w.scroll+w.height, len(w.entries))
+	for i := w.scroll; i < end; i++ {
+		entry := w.entries[i]
+
+		marker := "  "
+		if entry.Current {
+			marker = "● "
+		}
+		label := "original"
+		if entry.Seq > 0 {
+			label = fmt.Sprintf("change %d", entry.Seq)
+		}
+		row := fmt.Sprintf("%s%-12s %s  %s", marker, label, entry.Time.Format("15:04:05"), timeAgo(entry.Time))
+
+		switch {
+		case i == w.selected:
+			row = selectedStyle.Render(row)
+		case !entry.OnBranch:
+			row = branchStyle.Render(row)
+		default:
+			row = rowStyle.Render(row)
+		}
+		content.WriteString(row + "\n")
+	}
+
+	// Diff preview of the selected state
+	content.WriteString("\n")
+	removedStyle := lipgloss.NewStyle().Foreground(ui.Colors.Error)
+	addedStyle := lipgloss.NewStyle().Foreground(ui.Colors.Success)
+	hunkStyle := lipgloss.NewStyle().Foreground(ui.Colors.Info)
+	if entry := w.Selected(); entry != nil {
+		diff := entry.Diff
+		if len(diff) > w.height {
+			diff = append(diff[:w.height:w.height], "...")
+		}
+		for _, line := range diff {
+			line = truncate(line, styleWidth)
+			switch {
+			case strings.HasPrefix(line, "@@"):
+				line = hunkStyle.Render(line)
+			case strings.HasPrefix(line, "-"):
+				line = removedStyle.Render(line)
+			case strings.HasPrefix(line, "+"):
+				line = addedStyle.Render(line)
+			}
+			content.WriteString(line + "\n")
+		}
+	}
+
+	helpStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.Comment).
+		Italic(true).
+		Align(lipgloss.Center).
+		Width(styleWidth)
+
+	content.WriteString("\n")
+	content.WriteString(helpStyle.Render("j/k: select | Enter: restore | Esc: close"))
+
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ui.Colors.Info).
+		Padding(1, 2).
+		Width(w.width).
+		Background(ui.Colors.Widget)
+
+	return boxStyle.Render(content.String())
+}
+
+// timeAgo formats how long ago t was
+func timeAgo(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds ago", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
+
+// truncate shortens s to at most width runes
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-1]) + "…"
+}
@@ -0,0 +1,361 @@
+package widgets
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/tobibamidele/minra/internal/search"
+	"github.com/tobibamidele/minra/internal/ui"
+)
+
+// patchRowKind is what a row of the preview shows
+type patchRowKind int
+
+const (
+	patchFile patchRowKind = iota // the file's path
+	patchHunk                     // a hunk header, which can be toggled
+	patchLine                     // a line of the diff
+)
+
+// patchRow is one row of the preview
+type patchRow struct {
+	kind   patchRowKind
+	file   int // index in patches
+	hunk   int // index in the file's hunks
+	header string
+	line   search.DiffLine
+}
+
+// PatchWidget previews a replace across the workspace as a unified diff
+// of each file, with every hunk turned on or off on its own
+type PatchWidget struct {
+	visible     bool
+	query       string
+	replacement string
+	status      string
+	err         string
+
+	patches  []*search.FilePatch // sorted by path
+	rows     []patchRow
+	selected int // index in rows of a hunk header
+	offset   int
+	width    int
+	height   int // rows of the diff shown
+}
+
+// NewPatchWidget creates a new replace preview
+func NewPatchWidget() *PatchWidget {
+	return &PatchWidget{
+		visible: false,
+		width:   80,
+		height:  16,
+	}
+}
+
+// Show opens the preview of replacing query with replacement, empty
+// until patches are added
+func (w *PatchWidget) Show(query, replacement string) {
+	w.visible = true
+	w.query = query
+	w.replacement = replacement
+	w.status = ""
+	w.err = ""
+	w.patches = nil
+	w.rows = nil
+	w.selected = 0
+	w.offset = 0
+}
+
+func (w *PatchWidget) Hide() {
+	w.visible = false
+}
+
+func (w *PatchWidget) IsVisible() bool {
+	return w.visible
+}
+
+// SetSize fits the preview in width columns, showing height rows of diff
+func (w *PatchWidget) SetSize(width, height int) {
+	w.width = max(width, 40)
+	w.height = max(height, 3)
+	w.adjustOffset()
+}
+
+// SetStatus sets the progress line, such as "Searching…"
+func (w *PatchWidget) SetStatus(status string) {
+	w.status = status
+}
+
+// SetError shows why the replace cannot be made, or clears it
+func (w *PatchWidget) SetError(err string) {
+	w.err = err
+}
+
+// Add adds the patch of a file, keeping files sorted by path
+func (w *PatchWidget) Add(p *search.FilePatch) {
+	file, hunk := w.selectedHunk()
+
+	i := sort.Search(len(w.patches), func(i int) bool { return w.patches[i].Rel >= p.Rel })
+	w.patches = append(w.patches, nil)
+	copy(w.patches[i+1:], w.patches[i:])
+	w.patches[i] = p
+
+	// Keep the same hunk selected as rows move under it
+	w.rebuild()
+	w.selected = 0
+	for r, row := range w.rows {
+		if row.kind == patchHunk && w.patches[row.file] == file && row.hunk == hunk {
+			w.selected = r
+		}
+	}
+	w.selected = w.firstHunkRow(w.selected, 1)
+	w.adjustOffset()
+}
+
+// Patches returns the patches of every file, with the hunks as toggled
+func (w *PatchWidget) Patches() []*search.FilePatch {
+	return w.patches
+}
+
+// Counts returns the number of replacements turned on, of all
+// replacements and of the files they are in
+func (w *PatchWidget) Counts() (enabled, total, files int) {
+	for _, p := range w.patches {
+		e, t := p.Matches()
+		enabled += e
+		total += t
+	}
+	return enabled, total, len(w.patches)
+}
+
+// selectedHunk returns the patch and hunk index of the selected hunk
+func (w *PatchWidget) selectedHunk() (*search.FilePatch, int) {
+	if w.selected < 0 || w.selected >= len(w.rows) || w.rows[w.selected].kind != patchHunk {
+		return nil, 0
+	}
+	row := w.rows[w.selected]
+	return w.patches[row.file], row.hunk
+}
+
+// ToggleHunk turns the selected hunk on or off
+func (w *PatchWidget) ToggleHunk() {
+	p, h := w.selectedHunk()
+	if p == nil {
+		return
+	}
+	p.Hunks[h].Enabled = !p.Hunks[h].Enabled
+	w.rebuild()
+}
+
+// ToggleFile turns every hunk of the selected hunk's file off, or on if
+// they all are off
+func (w *PatchWidget) ToggleFile() {
+	p, _ := w.selectedHunk()
+	if p == nil {
+		return
+	}
+	on := true
+	for _, h := range p.Hunks {
+		if h.Enabled {
+			on = false
+		}
+	}
+	for i := range p.Hunks {
+		p.Hunks[i].Enabled = on
+	}
+	w.rebuild()
+}
+
+// rebuild lays out the rows, each file's hunks under its path. Headers
+// are rebuilt too, as toggling a hunk moves the lines of those after it.
+func (w *PatchWidget) rebuild() {
+	w.rows = w.rows[:0]
+	for f, p := range w.patches {
+		w.rows = append(w.rows, patchRow{kind: patchFile, file: f})
+		for h := range p.Hunks {
+			header, lines := p.Diff(h)
+			w.rows = append(w.rows, patchRow{kind: patchHunk, file: f, hunk: h, header: header})
+			for _, line := range lines {
+				w.rows = append(w.rows, patchRow{kind: patchLine, file: f, hunk: h, line: line})
+			}
+		}
+	}
+}
+
+// firstHunkRow returns the first hunk header from row from in direction
+// step, or from itself if there is none that way
+func (w *PatchWidget) firstHunkRow(from, step int) int {
+	for r := from; r >= 0 && r < len(w.rows); r += step {
+		if w.rows[r].kind == patchHunk {
+			return r
+		}
+	}
+	return from
+}
+
+// MoveUp selects the previous hunk
+func (w *PatchWidget) MoveUp() {
+	w.selected = w.firstHunkRow(max(w.selected-1, 0), -1)
+	w.selected = w.firstHunkRow(w.selected, 1)
+	w.adjustOffset()
+}
+
+// MoveDown selects the next hunk
+func (w *PatchWidget) MoveDown() {
+	if next := w.firstHunkRow(w.selected+1, 1); next < len(w.rows) && w.rows[next].kind == patchHunk {
+		w.selected = next
+	}
+	w.adjustOffset()
+}
+
+// PageUp and PageDown scroll a page of rows, selecting the first hunk
+// in view
+func (w *PatchWidget) PageUp() {
+	w.offset = max(w.offset-w.height, 0)
+	w.selected = w.firstHunkRow(w.offset, 1)
+	w.adjustOffset()
+}
+
+func (w *PatchWidget) PageDown() {
+	if w.offset+w.height < len(w.rows) {
+		w.offset += w.height
+	}
+	w.selected = w.firstHunkRow(w.offset, 1)
+	if w.selected >= len(w.rows) || w.rows[w.selected].kind != patchHunk {
+		w.selected = w.firstHunkRow(len(w.rows)-1, -1)
+	}
+	w.adjustOffset()
+}
+
+// adjustOffset scrolls the selected hunk into view, showing as much of
+// it as fits
+func (w *PatchWidget) adjustOffset() {
+	if len(w.rows) == 0 {
+		// Nothing has been found yet
+		w.selected, w.offset = 0, 0
+		return
+	}
+	if w.selected < w.offset {
+		w.offset = w.selected
+		// Show the path of the first file
+		if w.offset > 0 && w.rows[w.offset-1].kind == patchFile {
+			w.offset--
+		}
+	}
+	end := w.selected + 1
+	for end < len(w.rows) && w.rows[end].kind == patchLine {
+		end++
+	}
+	if end > w.offset+w.height {
+		w.offset = min(end-w.height, w.selected)
+	}
+}
+
+func (w *PatchWidget) Render() string {
+	if !w.visible {
+		return ""
+	}
+
+	var content strings.Builder
+	styleWidth := w.width - 4
+
+	titleStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.Info).
+		Bold(true).
+		Align(lipgloss.Center).
+		Width(styleWidth)
+	content.WriteString(titleStyle.Render("Replace in Files: Preview"))
+	content.WriteString("\n")
+
+	summaryStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.WidgetForeground).
+		Background(ui.Colors.WidgetInput).
+		Padding(0, 1).
+		Width(styleWidth)
+	content.WriteString(summaryStyle.Render(clipLine(w.query+" → "+w.replacement, 0, styleWidth-2)))
+	content.WriteString("\n")
+
+	statusStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.Comment).
+		Align(lipgloss.Right).
+		Width(styleWidth)
+	if w.err != "" {
+		content.WriteString(statusStyle.Foreground(ui.Colors.Error).Render(w.err))
+	} else {
+		content.WriteString(statusStyle.Render(w.status))
+	}
+	content.WriteString("\n")
+
+	headerStyle := lipgloss.NewStyle().Foreground(ui.Colors.Accent).Bold(true)
+	countStyle := lipgloss.NewStyle().Foreground(ui.Colors.Comment)
+	hunkStyle := lipgloss.NewStyle().Foreground(ui.Colors.Info)
+	offStyle := lipgloss.NewStyle().Foreground(ui.Colors.Comment).Strikethrough(true)
+	contextStyle := lipgloss.NewStyle().Foreground(ui.Colors.Comment)
+	removedStyle := lipgloss.NewStyle().Foreground(ui.Colors.Error)
+	addedStyle := lipgloss.NewStyle().Foreground(ui.Colors.Success)
+	selectedStyle := ui.Highlight(lipgloss.NewStyle().Background(ui.Colors.WidgetSelected).Width(styleWidth))
+	rowStyle := lipgloss.NewStyle().Width(styleWidth)
+
+	end := min(w.offset+w.height, len(w.rows))
+	for r := w.offset; r < end; r++ {
+		row := w.rows[r]
+		var text string
+		switch row.kind {
+		case patchFile:
+			p := w.patches[row.file]
+			enabled, total := p.Matches()
+			text = headerStyle.Render(p.Rel) + countStyle.Render(fmt.Sprintf(" (%d/%d)", enabled, total))
+		case patchHunk:
+			box := "[x] "
+			style := hunkStyle
+			if !w.patches[row.file].Hunks[row.hunk].Enabled {
+				box = "[ ] "
+				style = contextStyle
+			}
+			text = style.Render(box + row.header)
+		default:
+			enabled := w.patches[row.file].Hunks[row.hunk].Enabled
+			line := clipLine(row.line.Text, 0, styleWidth-2)
+			switch {
+			case row.line.Op == search.DiffRemoved && enabled:
+				text = removedStyle.Render("-" + line)
+			case row.line.Op == search.DiffAdded && enabled:
+				text = addedStyle.Render("+" + line)
+			case row.line.Op == search.DiffAdded:
+				text = offStyle.Render("+" + line)
+			default:
+				// Removed lines of a hunk that is off stay
+				text = contextStyle.Render(" " + line)
+			}
+		}
+
+		content.WriteString("\n")
+		if r == w.selected && row.kind == patchHunk {
+			content.WriteString(selectedStyle.Render(text))
+		} else {
+			content.WriteString(rowStyle.Render(text))
+		}
+	}
+	for r := end - w.offset; r < w.height; r++ {
+		content.WriteString("\n")
+	}
+
+	helpStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.Comment).
+		Italic(true).
+		Align(lipgloss.Center).
+		Width(styleWidth)
+	content.WriteString("\n")
+	content.WriteString(helpStyle.Render("↑/↓: hunk | Space: toggle | f: toggle file | Enter: apply | Esc: back"))
+
+	boxStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(ui.Colors.Info).
+		Padding(0, 1).
+		Width(w.width).
+		Background(ui.Colors.Widget)
+
+	return boxStyle.Render(content.String())
+}
@@ -148,7 +148,7 @@ func (w *RenameWidget) Render(width int) string {
 
 	// Title
 	titleStyle := lipgloss.NewStyle().
-		// Foreground(ui.ColorAccent).
+		// Foreground(ui.Colors.Accent).
 		Bold(true).
 		Align(lipgloss.Center).
 		Width(styleWidth)
@@ -156,14 +156,14 @@ func (w *RenameWidget) Render(width int) string {
 	// Outer box style
 	boxStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(ui.ColorAccent).
+		BorderForeground(ui.Colors.Accent).
 		Width(width - 2).
-		Background(lipgloss.Color("235"))
+		Background(ui.Colors.Widget)
 
 	// Input field
 	inputStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("230")).
-		Background(lipgloss.Color("236")).
+		Foreground(ui.Colors.WidgetForeground).
+		Background(ui.Colors.WidgetInput).
 		Padding(0, 1).
 		Width(styleWidth)
 
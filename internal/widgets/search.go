@@ -1,9 +1,12 @@
 package widgets
 
 import (
+	"fmt"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/tobibamidele/minra/internal/search"
 	"github.com/tobibamidele/minra/internal/ui"
 )
 
@@ -13,13 +16,22 @@ type SearchWidget struct {
 	input     string
 	cursorPos int
 	width     int
+	options   search.Options
+	err       string // why the query is not a valid pattern
+	current   int    // the match the cursor is on, counting from 1
+	total     int    // matches of the query, -1 before there is one
+
+	replacing    bool // the replace field is shown
+	replaceFocus bool // typing goes to the replace field
+	replacement  string
+	replacePos   int
 }
 
 // NewSearchWidget creates a new search widget
 func NewSearchWidget() *SearchWidget {
 	return &SearchWidget{
 		visible: false,
-		width:   50,
+		width:   56,
 	}
 }
 
@@ -27,6 +39,11 @@ func (w *SearchWidget) Show() {
 	w.visible = true
 	w.input = ""
 	w.cursorPos = 0
+	w.total = -1
+	w.replacing = false
+	w.replaceFocus = false
+	w.replacement = ""
+	w.replacePos = 0
 }
 
 func (w *SearchWidget) Hide() {
@@ -35,6 +52,32 @@ func (w *SearchWidget) Hide() {
 	w.cursorPos = 0
 }
 
+// SetReplacing shows or hides the replace field
+func (w *SearchWidget) SetReplacing(on bool) {
+	w.replacing = on
+	w.replaceFocus = w.replaceFocus && on
+}
+
+// IsReplacing reports whether the replace field is shown
+func (w *SearchWidget) IsReplacing() bool {
+	return w.replacing
+}
+
+// FocusReplace sends typing to the replace field, or back to the query
+func (w *SearchWidget) FocusReplace(focus bool) {
+	w.replaceFocus = focus && w.replacing
+}
+
+// ReplaceFocused reports whether typing goes to the replace field
+func (w *SearchWidget) ReplaceFocused() bool {
+	return w.replaceFocus
+}
+
+// GetReplacement returns the text of the replace field
+func (w *SearchWidget) GetReplacement() string {
+	return w.replacement
+}
+
 func (w *SearchWidget) IsVisible() bool {
 	return w.visible
 }
@@ -43,19 +86,47 @@ func (w *SearchWidget) GetInput() string {
 	return w.input
 }
 
+// SetOptions sets the search options the toggles show
+func (w *SearchWidget) SetOptions(opts search.Options) {
+	w.options = opts
+}
+
+// SetCount sets the match counter, as in "3/17". A total below zero
+// hides it.
+func (w *SearchWidget) SetCount(current, total int) {
+	w.current = current
+	w.total = total
+}
+
+// SetError shows why the query cannot be searched for, or clears it
+func (w *SearchWidget) SetError(err string) {
+	w.err = err
+}
+
+// field returns the text and cursor of the focused field
+func (w *SearchWidget) field() (*string, *int) {
+	if w.replaceFocus {
+		return &w.replacement, &w.replacePos
+	}
+	return &w.input, &w.cursorPos
+}
+
 func (w *SearchWidget) InsertRune(r rune) {
-	before := w.input[:w.cursorPos]
-	after := w.input[w.cursorPos:]
-	w.input = before + string(r) + after
-	w.cursorPos++
+	input, pos := w.field()
+	before := (*input)[:*pos]
+	after := (*input)[*pos:]
+	*input = before + string(r) + after
+	*pos += len(string(r))
 }
 
 func (w *SearchWidget) DeleteRune() {
-	if w.cursorPos > 0 {
-		before := w.input[:w.cursorPos-1]
-		after := w.input[w.cursorPos:]
-		w.input = before + after
-		w.cursorPos--
+	input, pos := w.field()
+	if *pos > 0 {
+		_, size := utf8.DecodeLastRuneInString((*input)[:*pos])
+		before := (*input)[:*pos-size]
+		after := (*input)[*pos:]
+		*input = before + after
+		*pos -= size
 	}
 }
 
@@ -68,39 +139,128 @@ func (w *SearchWidget) Render() string {
 	styleWidth := w.width - 4
 
 	titleStyle := lipgloss.NewStyle().
-		Foreground(ui.ColorWarning).
+		Foreground(ui.Colors.Warning).
 		Bold(true).
 		Align(lipgloss.Center).
 		Width(styleWidth)
 
-	content.WriteString(titleStyle.Render("Search"))
+	title := "Search"
+	if w.replacing {
+		title = "Search and Replace"
+	}
+	content.WriteString(titleStyle.Render(title))
 	content.WriteString("\n\n")
 
 	inputStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("230")).
-		Background(lipgloss.Color("236")).
+		Foreground(ui.Colors.WidgetForeground).
+		Background(ui.Colors.WidgetInput).
 		Padding(0, 1).
 		Width(styleWidth)
 
-	content.WriteString(inputStyle.Render(w.input))
-	content.WriteString("\n\n")
+	focusedStyle := inputStyle.Foreground(ui.Colors.Warning)
+
+	style := inputStyle
+	if w.replacing && !w.replaceFocus {
+		style = focusedStyle
+	}
+	content.WriteString(style.Render(w.input))
+	content.WriteString("\n")
+
+	if w.replacing {
+		style = inputStyle
+		if w.replaceFocus {
+			style = focusedStyle
+		}
+		content.WriteString(style.Render(w.replacement))
+		content.WriteString("\n")
+	}
+
+	toggles := renderToggles(w.options, w.replacing)
+	counter := w.renderCount(styleWidth - lipgloss.Width(toggles))
+	content.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, toggles, counter))
+	content.WriteString("\n")
+
+	if w.err != "" {
+		errStyle := lipgloss.NewStyle().
+			Foreground(ui.Colors.Error).
+			Width(styleWidth)
+		content.WriteString(errStyle.Render(w.err))
+	}
+	content.WriteString("\n")
 
 	helpStyle := lipgloss.NewStyle().
-		Foreground(ui.ColorComment).
+		Foreground(ui.Colors.Comment).
 		Italic(true).
 		Align(lipgloss.Center).
 		Width(styleWidth)
 
-	content.WriteString(helpStyle.Render("Enter: search | Esc: cancel"))
+	help := "Enter: search | Tab: replace | Esc: cancel"
+	if w.replacing {
+		help = "Enter: replace | Tab: switch field | Esc: cancel"
+	}
+	content.WriteString(helpStyle.Render(help))
 
 	boxStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
-		BorderForeground(ui.ColorWarning).
+		BorderForeground(ui.Colors.Warning).
 		Padding(1, 2).
 		Width(w.width).
-		Background(lipgloss.Color("235"))
+		Background(ui.Colors.Widget)
 
 	box := boxStyle.Render(content.String())
 
 	return box
 }
+
+// renderToggles draws the search options, lit when on, each with the key
+// after alt that toggles it. Preserve case only matters when replacing.
+func renderToggles(opts search.Options, replacing bool) string {
+	type toggle struct {
+		label string
+		on    bool
+	}
+	toggles := []toggle{
+		{"Aa c", opts.CaseSensitive},
+		{"Smart s", opts.SmartCase},
+		{"Word w", opts.WholeWord},
+		{".* r", opts.Regex},
+		{"\\n m", opts.Multiline},
+	}
+	if replacing {
+		toggles = append(toggles, toggle{"AB k", opts.PreserveCase})
+	}
+
+	onStyle := ui.Highlight(lipgloss.NewStyle().
+		Foreground(ui.Colors.Widget).
+		Background(ui.Colors.Warning).
+		Padding(0, 1))
+	offStyle := lipgloss.NewStyle().
+		Foreground(ui.Colors.Comment).
+		Padding(0, 1)
+
+	parts := make([]string, len(toggles))
+	for i, t := range toggles {
+		if t.on {
+			parts[i] = onStyle.Render(t.label)
+		} else {
+			parts[i] = offStyle.Render(t.label)
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// renderCount draws the match counter right aligned in width
+func (w *SearchWidget) renderCount(width int) string {
+	style := lipgloss.NewStyle().
+		Foreground(ui.Colors.Comment).
+		Align(lipgloss.Right).
+		Width(max(width, 0))
+
+	switch {
+	case w.total < 0:
+		return style.Render("")
+	case w.total == 0:
+		return style.Foreground(ui.Colors.Error).Render("No results")
+	}
+	return style.Render(fmt.Sprintf("%d/%d", w.current, w.total))
+}
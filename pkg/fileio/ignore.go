@@ -0,0 +1,165 @@
+package fileio
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFiles are the files whose patterns Walk honors, in the syntax of
+// .gitignore. .ignore is read by ripgrep and others for files that git
+// should track but searches should skip.
+var IgnoreFiles = []string{".gitignore", ".ignore"}
+
+// Ignore holds the patterns of one ignore file. Paths are matched relative
+// to the directory the file is in, with forward slashes.
+type Ignore struct {
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	pattern *regexp.Regexp
+	negate  bool // ! pattern, which brings back a path an earlier one ignored
+	dirOnly bool // pattern/ only matches directories
+}
+
+// ParseIgnore reads the patterns of an ignore file. Lines that are not
+// valid patterns are skipped.
+func ParseIgnore(content string) *Ignore {
+	ig := &Ignore{}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+			line = strings.TrimRight(line, " ")
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		var rule ignoreRule
+		if line[0] == '!' {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// A slash anywhere but the end anchors the pattern to the
+		// directory of the ignore file; otherwise it matches at any depth
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		expr := globToRegexp(line)
+		if !anchored {
+			expr = "(?:.*/)?" + expr
+		}
+		re, err := regexp.Compile("^" + expr + "$")
+		if err != nil {
+			continue
+		}
+		rule.pattern = re
+		ig.rules = append(ig.rules, rule)
+	}
+	return ig
+}
+
+// Match reports whether rel is ignored by these patterns. matched is false
+// if no pattern mentions rel, so that an ignore file higher up decides.
+func (ig *Ignore) Match(rel string, isDir bool) (ignored, matched bool) {
+	for _, rule := range ig.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.pattern.MatchString(rel) {
+			ignored, matched = !rule.negate, true
+		}
+	}
+	return ignored, matched
+}
+
+// globToRegexp translates a gitignore glob: * and ? stay within a path
+// segment, ** spans any number of them and [...] is a character class
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// ignoreStack is the ignore files of a directory and those above it
+type ignoreStack []ignoreLevel
+
+type ignoreLevel struct {
+	dir    string // relative to the walk's root, "" for the root
+	ignore *Ignore
+}
+
+// ignored reports whether rel is ignored. Deeper ignore files override
+// those above them, as in git.
+func (s ignoreStack) ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, level := range s {
+		sub := rel
+		if level.dir != "" {
+			sub = strings.TrimPrefix(rel, level.dir+"/")
+		}
+		if ig, ok := level.ignore.Match(sub, isDir); ok {
+			ignored = ig
+		}
+	}
+	return ignored
+}
+
+// push returns the stack with the ignore files of dir added
+func (s ignoreStack) push(root, dir string) ignoreStack {
+	var patterns []string
+	for _, name := range IgnoreFiles {
+		if content, err := ReadFile(filepath.Join(root, filepath.FromSlash(dir), name)); err == nil {
+			patterns = append(patterns, content)
+		}
+	}
+	if len(patterns) == 0 {
+		return s
+	}
+	level := ignoreLevel{dir: dir, ignore: ParseIgnore(strings.Join(patterns, "\n"))}
+	return append(s[:len(s):len(s)], level)
+}
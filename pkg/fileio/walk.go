@@ -0,0 +1,61 @@
+package fileio
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Walk calls fn for every file under root, in lexical order, except those
+// the ignore files in IgnoreFiles exclude. Version control directories are
+// always skipped. rel is the file's path relative to root. Walk stops
+// when ctx is done, returning its error, or when fn returns an error.
+// Directories that cannot be read are skipped.
+func Walk(ctx context.Context, root string, fn func(path, rel string, info fs.FileInfo) error) error {
+	return walkDir(ctx, root, "", ignoreStack(nil).push(root, ""), fn)
+}
+
+// skipDirs are never walked into
+var skipDirs = map[string]bool{".git": true, ".hg": true, ".svn": true}
+
+func walkDir(ctx context.Context, root, dir string, ignores ignoreStack, fn func(path, rel string, info fs.FileInfo) error) error {
+	entries, err := os.ReadDir(filepath.Join(root, dir))
+	if err != nil {
+		return nil
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel := entry.Name()
+		if dir != "" {
+			rel = dir + "/" + entry.Name()
+		}
+		if entry.IsDir() {
+			if skipDirs[entry.Name()] || ignores.ignored(rel, true) {
+				continue
+			}
+			if err := walkDir(ctx, root, rel, ignores.push(root, rel), fn); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !entry.Type().IsRegular() || ignores.ignored(rel, false) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if err := fn(filepath.Join(root, filepath.FromSlash(rel)), rel, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
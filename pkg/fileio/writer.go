@@ -1,8 +1,13 @@
 package fileio
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // WriteFile writes content to a file
@@ -43,3 +48,104 @@ func CreateFile(fp string) error {
 
 	return nil
 }
+
+// WriteFileAtomic replaces the content of a file in one step: it writes a
+// temporary file next to it and renames that over the file, so a failed
+// write leaves the file as it was. The file keeps its permissions.
+func WriteFileAtomic(path string, content string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// CreateDirectory creates a directory along with any parents it needs
+func CreateDirectory(path string) error {
+	return os.MkdirAll(path, 0755)
+}
+
+// DeleteDirectory deletes a directory and everything in it
+func DeleteDirectory(path string) error {
+	return os.RemoveAll(path)
+}
+
+// Copy copies a file, or a directory and everything in it, to dst, which
+// must not exist yet. Permissions are kept.
+func Copy(src, dst string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%s already exists", filepath.Base(dst))
+	}
+	if rel, err := filepath.Rel(src, dst); err == nil && info.IsDir() && !strings.HasPrefix(rel, "..") {
+		return errors.New("cannot copy a directory into itself")
+	}
+	return copyPath(src, dst, info)
+}
+
+func copyPath(src, dst string, info fs.FileInfo) error {
+	switch {
+	case info.IsDir():
+		if err := os.Mkdir(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			child, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			if err := copyPath(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name()), child); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
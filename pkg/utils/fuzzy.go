@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Fuzzy match scoring, loosely after fzf: every matched character scores,
+// runs of consecutive matches and matches at word boundaries score extra,
+// and gaps between matches cost a little.
+const (
+	fuzzyMatchScore    = 16
+	fuzzyConsecutive   = 12
+	fuzzyBoundaryBonus = 10
+	fuzzyFirstBonus    = 8
+	fuzzyGapPenalty    = 1
+	fuzzyCaseBonus     = 1
+
+	// Path matching on top of that: matches at the start of a path
+	// segment and matches in the file name score extra, and long paths
+	// cost a little so shallow files win ties
+	fuzzySegmentBonus  = 6
+	fuzzyBasenameBonus = 24
+	fuzzyPathPenalty   = 1 // per 8 bytes of path
+)
+
+// FuzzyMatch reports whether the characters of pattern appear in text in
+// order, ignoring case. It returns a score, higher for better matches, and
+// the byte offsets in text of the matched characters. An empty pattern
+// matches everything with a score of zero.
+func FuzzyMatch(pattern, text string) (int, []int, bool) {
+	if pattern == "" {
+		return 0, nil, true
+	}
+
+	pat := []rune(pattern)
+	runes := []rune(text)
+	offsets := make([]int, 0, len(runes))
+	for i := range text {
+		offsets = append(offsets, i)
+	}
+
+	// Find the leftmost match, then walk back from its end to tighten it,
+	// which prefers a compact run over scattered characters
+	pi := 0
+	end := -1
+	for i, r := range runes {
+		if equalFold(r, pat[pi]) {
+			pi++
+			if pi == len(pat) {
+				end = i
+				break
+			}
+		}
+	}
+	if end == -1 {
+		return 0, nil, false
+	}
+
+	start := end
+	pi = len(pat) - 1
+	for i := end; i >= 0; i-- {
+		if equalFold(runes[i], pat[pi]) {
+			pi--
+			if pi < 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	// Score the tightened match going forward again
+	score := 0
+	positions := make([]int, 0, len(pat))
+	pi = 0
+	prev := -2
+	for i := start; i <= end && pi < len(pat); i++ {
+		if !equalFold(runes[i], pat[pi]) {
+			continue
+		}
+
+		score += fuzzyMatchScore
+		if runes[i] == pat[pi] {
+			score += fuzzyCaseBonus
+		}
+		switch {
+		case i == 0:
+			score += fuzzyBoundaryBonus + fuzzyFirstBonus
+		case isBoundary(runes[i-1], runes[i]):
+			score += fuzzyBoundaryBonus
+		}
+		if prev == i-1 {
+			score += fuzzyConsecutive
+		} else if prev >= 0 {
+			score -= (i - prev - 1) * fuzzyGapPenalty
+		}
+
+		positions = append(positions, offsets[i])
+		prev = i
+		pi++
+	}
+
+	// Leading unmatched text costs a little so earlier matches win ties
+	score -= start * fuzzyGapPenalty / 2
+	return score, positions, true
+}
+
+// FuzzyMatchPath is FuzzyMatch for file paths separated by slashes. A
+// pattern that matches within the file name alone is matched there, as
+// "main" should find cmd/minra/main.go before maintenance/index.go.
+func FuzzyMatchPath(pattern, path string) (int, []int, bool) {
+	score, positions, ok := FuzzyMatch(pattern, path)
+	if !ok {
+		return 0, nil, false
+	}
+
+	dir := strings.LastIndexByte(path, '/') + 1
+	if dir > 0 {
+		if s, p, ok := FuzzyMatch(pattern, path[dir:]); ok && s+fuzzyBasenameBonus > score {
+			score = s + fuzzyBasenameBonus
+			for i := range p {
+				p[i] += dir
+			}
+			positions = p
+		}
+	}
+
+	for _, p := range positions {
+		if p == 0 || path[p-1] == '/' {
+			score += fuzzySegmentBonus
+		}
+	}
+	score -= len(path) / 8 * fuzzyPathPenalty
+	return score, positions, true
+}
+
+func equalFold(a, b rune) bool {
+	return a == b || unicode.ToLower(a) == unicode.ToLower(b)
+}
+
+// isBoundary reports whether cur starts a word: after a separator, or an
+// upper-case letter after a lower-case one
+func isBoundary(prev, cur rune) bool {
+	switch prev {
+	case ' ', '_', '-', '.', '/', '\\', ':':
+		return true
+	}
+	return unicode.IsLower(prev) && unicode.IsUpper(cur)
+}
+
+// HighlightPositions wraps the characters of s at the given byte offsets
+// with style, for drawing fuzzy matches
+func HighlightPositions(s string, positions []int, style func(string) string) string {
+	if len(positions) == 0 {
+		return s
+	}
+
+	matched := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		matched[p] = true
+	}
+
+	var out []byte
+	for i := 0; i < len(s); {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		if matched[i] {
+			out = append(out, style(s[i:i+size])...)
+		} else {
+			out = append(out, s[i:i+size]...)
+		}
+		i += size
+	}
+	return string(out)
+}